@@ -11,6 +11,7 @@ import (
 	"github.com/mnizarzr/dot-test/db"
 	_ "github.com/mnizarzr/dot-test/docs"
 	"github.com/mnizarzr/dot-test/middleware"
+	"github.com/mnizarzr/dot-test/utils"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -58,6 +59,9 @@ func Setup() {
 	if err != nil {
 		panic(fmt.Sprintf("Error loading cfg: %v", err))
 	}
+	if err := cfg.ValidateSecrets(); err != nil {
+		panic(fmt.Sprintf("Error loading cfg: %v", err))
+	}
 
 	database, err := db.NewPostgresGormDb(cfg.PgUri)
 	if err != nil {
@@ -69,17 +73,25 @@ func Setup() {
 		panic(fmt.Sprintf("Error connecting to Redis: %v", err))
 	}
 
+	jwtKeys, err := utils.NewJWTKeys(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Error loading JWT keys: %v", err))
+	}
+
 	r := gin.Default()
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	if err := middleware.SetupTrustedProxies(r, cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Error loading trusted proxies: %v", err))
+	}
 	r.Use(middleware.SetupCORS())
 
 	// Basic routes
 	r.GET("/", Home)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	BuildHandler(cfg, r, database, redis)
+	BuildHandler(cfg, r, database, redis, jwtKeys)
 
 	err = r.Run(":8080")
 	if err != nil {
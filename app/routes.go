@@ -1,28 +1,55 @@
 package app
 
 import (
+	"net"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/hibiken/asynq"
 	"github.com/mnizarzr/dot-test/config"
 	"github.com/mnizarzr/dot-test/db"
 	"github.com/mnizarzr/dot-test/middleware"
+	"github.com/mnizarzr/dot-test/modules/apikey"
+	"github.com/mnizarzr/dot-test/modules/audit"
 	"github.com/mnizarzr/dot-test/modules/auth"
 	"github.com/mnizarzr/dot-test/modules/project"
+	"github.com/mnizarzr/dot-test/modules/role"
+	"github.com/mnizarzr/dot-test/modules/session"
 	"github.com/mnizarzr/dot-test/modules/task"
 	"github.com/mnizarzr/dot-test/modules/user"
+	"github.com/mnizarzr/dot-test/policy"
+	"github.com/mnizarzr/dot-test/utils"
 	"gorm.io/gorm"
 )
 
 // Dependencies holds all the application dependencies
 type Dependencies struct {
-	Config    *config.Config
-	DB        *gorm.DB
-	Redis     *db.RedisClient
-	JobClient *asynq.Client
+	Config      *config.Config
+	DB          *gorm.DB
+	Redis       *db.RedisClient
+	JobClient   *asynq.Client
+	APIKeys     apikey.Service
+	Sessions    session.Service
+	JWTKeys     *utils.JWTKeys
+	Permissions *policy.Engine
+
+	RateLimitAllowlist []*net.IPNet
 }
 
+// Rate limit policies per route group. Authenticated groups are limited per
+// user, the others per IP address.
+var (
+	authRateLimit   = middleware.RateLimitPolicy{Name: "auth", Limit: 20, Window: time.Minute}
+	publicRateLimit = middleware.RateLimitPolicy{Name: "public", Limit: 30, Window: time.Minute}
+	apiRateLimit    = middleware.RateLimitPolicy{Name: "api", Limit: 300, Window: time.Minute}
+	auditRateLimit  = middleware.RateLimitPolicy{Name: "audit", Limit: 60, Window: time.Minute}
+
+	// Requests rejected with 401 per IP, across all authenticated groups
+	authFailureRateLimit = middleware.RateLimitPolicy{Name: "auth_failure", Limit: 30, Window: time.Minute}
+)
+
 // BuildHandler creates and configures all route handlers with dependency injection
-func BuildHandler(config *config.Config, router *gin.Engine, database *gorm.DB, redisClient *db.RedisClient) {
+func BuildHandler(config *config.Config, router *gin.Engine, database *gorm.DB, redisClient *db.RedisClient, jwtKeys *utils.JWTKeys) {
 	redisOpt := asynq.RedisClientOpt{
 		Addr:     config.RedisAddress,
 		Password: config.RedisPassword,
@@ -30,70 +57,149 @@ func BuildHandler(config *config.Config, router *gin.Engine, database *gorm.DB,
 	jobClient := asynq.NewClient(redisOpt)
 
 	deps := &Dependencies{
-		Config:    config,
-		DB:        database,
-		Redis:     redisClient,
-		JobClient: jobClient,
+		Config:      config,
+		DB:          database,
+		Redis:       redisClient,
+		JobClient:   jobClient,
+		APIKeys:     apikey.NewService(apikey.NewRepository(database), user.NewRepository(database, redisClient)),
+		Sessions:    session.NewService(session.NewRepository(database), user.NewRepository(database, redisClient), redisClient),
+		JWTKeys:     jwtKeys,
+		Permissions: policy.NewEngine(role.NewRepository(database)),
+
+		RateLimitAllowlist: middleware.ParseAllowlist(config.RateLimitAllowlist),
 	}
 
+	// Errors passed to c.Error are rendered as problem details
+	router.Use(middleware.ErrorHandler())
+
+	setupWellKnownRoutes(router, deps)
 	setupRoutesV1(router, deps)
 }
 
+// rateLimit returns the rate limit middleware for a policy, or a no-op when
+// rate limiting is disabled
+func (deps *Dependencies) rateLimit(policy middleware.RateLimitPolicy) gin.HandlerFunc {
+	if !deps.Config.RateLimitEnabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.RateLimit(deps.Redis, deps.RateLimitAllowlist, policy)
+}
+
+// authFailureLimit returns the middleware that throttles IPs sending requests
+// that fail authentication, or a no-op when rate limiting is disabled. It goes
+// before the JWT middleware of every authenticated group.
+func (deps *Dependencies) authFailureLimit() gin.HandlerFunc {
+	if !deps.Config.RateLimitEnabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.AuthFailureLimit(deps.Redis, deps.RateLimitAllowlist, authFailureRateLimit)
+}
+
+// idempotency returns the middleware that replays POST responses to retries
+// with the same Idempotency-Key. Routes that return new API keys do not use
+// it, since replaying would keep the plaintext keys in Redis.
+func (deps *Dependencies) idempotency() gin.HandlerFunc {
+	return middleware.Idempotency(deps.Redis, time.Duration(deps.Config.IdempotencyKeyTTL)*time.Second)
+}
+
+// setupWellKnownRoutes configures the unversioned discovery routes
+func setupWellKnownRoutes(router *gin.Engine, deps *Dependencies) {
+	userRepo := user.NewRepository(deps.DB, deps.Redis)
+	authRepo := auth.NewRepository(deps.DB)
+	authService := auth.NewService(userRepo, authRepo, deps.Sessions, deps.Redis, deps.JobClient, deps.JWTKeys, deps.Permissions, deps.Config)
+	authHandler := auth.NewHandler(authService)
+
+	router.GET("/.well-known/jwks.json", deps.rateLimit(publicRateLimit), authHandler.JWKS)
+}
+
 // setupRoutesV1 configures all application routes
 func setupRoutesV1(router *gin.Engine, deps *Dependencies) {
 	api := router.Group("/api/v1")
 
+	api.Use(middleware.RequestID())
+	api.Use(middleware.Language(user.NewRepository(deps.DB, deps.Redis)))
+
 	//  middleware to inject user information for GORM hooks
 	api.Use(middleware.AuditResourceContext())
+	api.Use(middleware.AuditSecurityEvents(deps.DB, deps.Config))
 
 	setupAuthRoutes(api, deps)
 
 	setupUserRoutes(api, deps)
 	setupProjectRoutes(api, deps)
 	setupTaskRoutes(api, deps)
+	setupAuditRoutes(api, deps)
+	setupAPIKeyRoutes(api, deps)
+	setupSessionRoutes(api, deps)
+	setupRoleRoutes(api, deps)
 }
 
 // setupAuthRoutes configures auth module routes with dependency injection
 func setupAuthRoutes(api *gin.RouterGroup, deps *Dependencies) {
 	userRepo := user.NewRepository(deps.DB, deps.Redis)
-	authService := auth.NewService(userRepo, deps.JobClient, deps.Config.JWTSecret)
+	authRepo := auth.NewRepository(deps.DB)
+	authService := auth.NewService(userRepo, authRepo, deps.Sessions, deps.Redis, deps.JobClient, deps.JWTKeys, deps.Permissions, deps.Config)
 	authHandler := auth.NewHandler(authService)
 
 	authGroup := api.Group("/auth")
 	{
-		authGroup.Use(middleware.AuditMiddleware(deps.DB))
+		authGroup.Use(deps.rateLimit(authRateLimit), middleware.AuditMiddleware(deps.DB))
 		// Registration allows both authenticated (admin) and unauthenticated users
-		authGroup.POST("/register", middleware.OptionalJWTAuth(deps.Config.JWTSecret), authHandler.Register)
+		authGroup.POST("/register", middleware.OptionalJWTAuth(deps.JWTKeys, deps.Sessions), authHandler.Register)
 		authGroup.POST("/login", authHandler.Login)
+		authGroup.POST("/unlock", middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), middleware.RequirePermission(deps.Permissions, policy.UserUnlock), authHandler.Unlock)
+
+		// Single sign-on through the OpenID Connect provider
+		authGroup.GET("/oidc/login", authHandler.OIDCLogin)
+		authGroup.GET("/oidc/callback", authHandler.OIDCCallback)
+
+		// Credential management needs a logged in user, so API keys are not accepted here.
+		// Enrollment accepts either an access token or the MFA token of a login that requires MFA
+		authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+		authGroup.POST("/mfa/enroll", middleware.OptionalJWTAuth(deps.JWTKeys, deps.Sessions), authHandler.EnrollMFA)
+		authGroup.POST("/mfa/confirm", middleware.OptionalJWTAuth(deps.JWTKeys, deps.Sessions), authHandler.ConfirmMFA)
+		authGroup.POST("/mfa/disable", middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), authHandler.DisableMFA)
+		authGroup.POST("/mfa/recovery-codes", middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), authHandler.RegenerateRecoveryCodes)
 	}
 }
 
 // setupUserRoutes configures user module routes with dependency injection
 func setupUserRoutes(api *gin.RouterGroup, deps *Dependencies) {
 	userRepo := user.NewRepository(deps.DB, deps.Redis)
-	userService := user.NewService(userRepo)
+	userService := user.NewService(userRepo, deps.Config.JWTSecret)
 	userHandler := user.NewHandler(userService)
 
+	// Unsubscribe links are authenticated by their signed token. Opening one only
+	// asks for confirmation, so link scanners cannot unsubscribe anyone
+	api.GET("/unsubscribe", deps.rateLimit(publicRateLimit), userHandler.ConfirmUnsubscribe)
+	api.POST("/unsubscribe", deps.rateLimit(publicRateLimit), userHandler.Unsubscribe)
+
 	userGroup := api.Group("/user")
-	userGroup.Use(middleware.JWTAuth(deps.Config.JWTSecret))
+	userGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, deps.APIKeys), middleware.RequireScope("users"), deps.rateLimit(apiRateLimit), middleware.AuditReadAccess(deps.DB, deps.Config, "users"))
 	{
 		userGroup.GET("/me", userHandler.GetProfile)
+		userGroup.GET("/me/preferences", userHandler.GetPreferences)
+		userGroup.PUT("/me/preferences", userHandler.UpdatePreferences)
 	}
 }
 
 // setupProjectRoutes configures project module routes with dependency injection
 func setupProjectRoutes(api *gin.RouterGroup, deps *Dependencies) {
+	auditService := audit.NewService(audit.NewRepository(deps.DB))
+
 	projectRepo := project.NewRepository(deps.DB)
-	projectService := project.NewService(projectRepo)
+	projectService := project.NewService(projectRepo, auditService, deps.Permissions)
 	projectHandler := project.NewHandler(projectService)
 
 	projectGroup := api.Group("/projects")
-	projectGroup.Use(middleware.JWTAuth(deps.Config.JWTSecret))
+	projectGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, deps.APIKeys), middleware.RequireScope("projects"), deps.rateLimit(apiRateLimit), middleware.AuditReadAccess(deps.DB, deps.Config, "projects"), deps.idempotency())
 	{
-		projectGroup.POST("", projectHandler.CreateProject)
+		projectGroup.POST("", middleware.RequirePermission(deps.Permissions, policy.ProjectCreate), projectHandler.CreateProject)
 		projectGroup.GET("", projectHandler.GetAllProjects)
 		projectGroup.GET("/:id", projectHandler.GetProject)
+		projectGroup.GET("/:id/history", projectHandler.GetProjectHistory)
 		projectGroup.PUT("/:id", projectHandler.UpdateProject)
+		projectGroup.PATCH("/:id", projectHandler.PatchProject)
 		projectGroup.DELETE("/:id", projectHandler.DeleteProject)
 	}
 }
@@ -101,23 +207,103 @@ func setupProjectRoutes(api *gin.RouterGroup, deps *Dependencies) {
 // setupTaskRoutes configures task module routes with dependency injection
 func setupTaskRoutes(api *gin.RouterGroup, deps *Dependencies) {
 	userRepo := user.NewRepository(deps.DB, deps.Redis)
-	userService := user.NewService(userRepo)
+	userService := user.NewService(userRepo, deps.Config.JWTSecret)
+
+	auditService := audit.NewService(audit.NewRepository(deps.DB))
 
 	projectRepo := project.NewRepository(deps.DB)
-	projectService := project.NewService(projectRepo)
+	projectService := project.NewService(projectRepo, auditService, deps.Permissions)
 
 	taskRepo := task.NewRepository(deps.DB)
-	taskService := task.NewService(taskRepo, projectService, userService)
+	taskService := task.NewService(taskRepo, projectService, userService, auditService, deps.JobClient, deps.Permissions)
 	taskHandler := task.NewHandler(taskService)
 
 	taskGroup := api.Group("/tasks")
-	taskGroup.Use(middleware.JWTAuth(deps.Config.JWTSecret))
+	taskGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, deps.APIKeys), middleware.RequireScope("tasks"), deps.rateLimit(apiRateLimit), middleware.AuditReadAccess(deps.DB, deps.Config, "tasks"), deps.idempotency())
 	{
-		taskGroup.POST("", taskHandler.CreateTask)
+		taskGroup.POST("", middleware.RequirePermission(deps.Permissions, policy.TaskCreate), taskHandler.CreateTask)
 		taskGroup.GET("", taskHandler.GetTasksWithFilters)
 		taskGroup.GET("/:id", taskHandler.GetTask)
+		taskGroup.GET("/:id/history", taskHandler.GetTaskHistory)
 		taskGroup.PUT("/:id", taskHandler.UpdateTask)
+		taskGroup.PATCH("/:id", taskHandler.PatchTask)
 		taskGroup.PUT("/:id/assign", taskHandler.AssignTask)
 		taskGroup.DELETE("/:id", taskHandler.DeleteTask)
 	}
 }
+
+// setupAuditRoutes configures audit log routes with dependency injection
+func setupAuditRoutes(api *gin.RouterGroup, deps *Dependencies) {
+	auditRepo := audit.NewRepository(deps.DB)
+	auditService := audit.NewService(auditRepo)
+	auditHandler := audit.NewHandler(auditService)
+
+	auditGroup := api.Group("/audit-logs")
+	auditGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, deps.APIKeys), middleware.RequireScope("audit"), middleware.RequirePermission(deps.Permissions, policy.AuditRead), deps.rateLimit(auditRateLimit), middleware.AuditReadAccess(deps.DB, deps.Config, "audit_logs"))
+	{
+		auditGroup.GET("", auditHandler.GetAuditLogs)
+		auditGroup.GET("/:id", auditHandler.GetAuditLog)
+	}
+}
+
+// setupAPIKeyRoutes configures personal access token and service account routes with dependency injection
+func setupAPIKeyRoutes(api *gin.RouterGroup, deps *Dependencies) {
+	apiKeyHandler := apikey.NewHandler(deps.APIKeys)
+
+	// Key management needs a logged in user, so API keys can never be used to manage API keys
+	tokenGroup := api.Group("/user/me/tokens")
+	tokenGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), deps.rateLimit(apiRateLimit))
+	{
+		tokenGroup.POST("", apiKeyHandler.CreatePersonalToken)
+		tokenGroup.GET("", apiKeyHandler.ListPersonalTokens)
+		tokenGroup.DELETE("/:id", apiKeyHandler.RevokePersonalToken)
+	}
+
+	serviceAccountGroup := api.Group("/service-accounts")
+	serviceAccountGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), middleware.RequirePermission(deps.Permissions, policy.ServiceAccountManage), deps.rateLimit(apiRateLimit))
+	{
+		serviceAccountGroup.POST("", apiKeyHandler.CreateServiceAccount)
+		serviceAccountGroup.GET("", apiKeyHandler.ListServiceAccounts)
+		serviceAccountGroup.POST("/:id/keys", apiKeyHandler.CreateServiceKey)
+		serviceAccountGroup.GET("/:id/keys", apiKeyHandler.ListServiceKeys)
+		serviceAccountGroup.DELETE("/:id/keys/:keyId", apiKeyHandler.RevokeServiceKey)
+	}
+}
+
+// setupSessionRoutes configures session management routes with dependency injection
+func setupSessionRoutes(api *gin.RouterGroup, deps *Dependencies) {
+	sessionHandler := session.NewHandler(deps.Sessions)
+
+	// Sessions belong to logins, so API keys cannot list or revoke them
+	sessionGroup := api.Group("/user/me/sessions")
+	sessionGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), deps.rateLimit(apiRateLimit))
+	{
+		sessionGroup.GET("", sessionHandler.ListSessions)
+		sessionGroup.DELETE("/:id", sessionHandler.RevokeSession)
+	}
+
+	userSessionGroup := api.Group("/users/:id/sessions")
+	userSessionGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), middleware.RequirePermission(deps.Permissions, policy.SessionRevoke), deps.rateLimit(apiRateLimit))
+	{
+		userSessionGroup.DELETE("", sessionHandler.RevokeUserSessions)
+	}
+}
+
+// setupRoleRoutes configures role and permission routes with dependency injection
+func setupRoleRoutes(api *gin.RouterGroup, deps *Dependencies) {
+	roleService := role.NewService(role.NewRepository(deps.DB), deps.Permissions)
+	roleHandler := role.NewHandler(roleService)
+
+	api.GET("/user/me/permissions", middleware.JWTAuth(deps.JWTKeys, deps.Sessions, deps.APIKeys), middleware.RequireScope("users"), deps.rateLimit(apiRateLimit), roleHandler.GetMyPermissions)
+
+	// Like API keys, roles can only be managed by a logged in user
+	roleGroup := api.Group("/roles")
+	roleGroup.Use(deps.authFailureLimit(), middleware.JWTAuth(deps.JWTKeys, deps.Sessions, nil), middleware.RequirePermission(deps.Permissions, policy.RoleManage), deps.rateLimit(apiRateLimit), deps.idempotency())
+	{
+		roleGroup.GET("", roleHandler.ListRoles)
+		roleGroup.GET("/permissions", roleHandler.ListPermissions)
+		roleGroup.POST("", roleHandler.CreateRole)
+		roleGroup.PUT("/:name", roleHandler.UpdateRole)
+		roleGroup.DELETE("/:name", roleHandler.DeleteRole)
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/task"
+	emailtemplate "github.com/mnizarzr/dot-test/template"
+	"github.com/mnizarzr/dot-test/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	previewLocale string
+	previewFormat string
+)
+
+var emailCmd = &cobra.Command{
+	Use:   "email",
+	Short: "Work with email templates",
+}
+
+var emailPreviewCmd = &cobra.Command{
+	Use:   "preview <template>",
+	Short: "Render an email template with sample data",
+	Long:  `Render an email template with sample data and print the subject and bodies. Run without arguments to list the available templates.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		previewEmail(args)
+	},
+}
+
+func init() {
+	emailPreviewCmd.Flags().StringVar(&previewLocale, "locale", entity.DefaultLanguage, "locale to render the template in")
+	emailPreviewCmd.Flags().StringVar(&previewFormat, "format", "all", "output format: html, text or all")
+	emailCmd.AddCommand(emailPreviewCmd)
+}
+
+func previewEmail(args []string) {
+	renderer, err := utils.NewTemplateRenderer(emailtemplate.Email(), entity.DefaultLanguage)
+	if err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+
+	if len(args) == 0 {
+		fmt.Println("Templates:", strings.Join(renderer.Templates(), ", "))
+		fmt.Println("Locales:  ", strings.Join(renderer.Locales(), ", "))
+		return
+	}
+
+	// The preview works without a .env file, it only needs the app name and URL
+	appName, appUrl := "DTT", "http://localhost:8080"
+	if cfg, err := config.LoadConfig("."); err == nil {
+		appName, appUrl = cfg.AppName, cfg.AppUrl
+	}
+
+	data, ok := sampleEmailData(args[0], appName, appUrl+"/api/v1/unsubscribe?token=sample")
+	if !ok {
+		log.Fatalf("Unknown email template %q, available: %s", args[0], strings.Join(renderer.Templates(), ", "))
+	}
+
+	rendered, err := renderer.Render(args[0], previewLocale, data)
+	if err != nil {
+		log.Fatalf("Failed to render email template: %v", err)
+	}
+
+	switch previewFormat {
+	case "html":
+		fmt.Print(rendered.HTMLBody)
+	case "text":
+		fmt.Print(rendered.TextBody)
+	case "all":
+		fmt.Printf("Subject: %s\n\n%s\n%s", rendered.Subject, rendered.TextBody, rendered.HTMLBody)
+	default:
+		log.Fatalf("Unknown format %q, expected html, text or all", previewFormat)
+	}
+}
+
+// sampleEmailData returns representative data for a template
+func sampleEmailData(name, appName, unsubscribeURL string) (interface{}, bool) {
+	dueDate := time.Now().AddDate(0, 0, 1)
+	overdueDate := time.Now().AddDate(0, 0, -2)
+
+	taskData := utils.EmailData{
+		Name:           "John Doe",
+		Email:          "john.doe@example.com",
+		AppName:        appName,
+		TaskTitle:      "Prepare release notes",
+		ProjectName:    "Website Redesign",
+		DueDate:        &dueDate,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	switch name {
+	case "welcome":
+		return utils.EmailData{
+			Name:     "John Doe",
+			Email:    "john.doe@example.com",
+			AppName:  appName,
+			Role:     "user",
+			Password: "SecurePass123",
+		}, true
+	case "account_locked":
+		return utils.EmailData{
+			Name:           "John Doe",
+			Email:          "john.doe@example.com",
+			AppName:        appName,
+			IPAddress:      "203.0.113.7",
+			LockoutMinutes: 15,
+		}, true
+	case "task_assigned", "task_due_soon":
+		return taskData, true
+	case "task_overdue":
+		taskData.DueDate = &overdueDate
+		return taskData, true
+	case "digest":
+		return utils.DigestEmailData{
+			Name:    "John Doe",
+			AppName: appName,
+			Period:  entity.DigestCadenceDaily,
+			AssignedTasks: []utils.DigestTask{
+				{Title: "Prepare release notes", ProjectName: "Website Redesign", Status: task.StatusInProgress, Priority: task.PriorityHigh, DueDate: &dueDate},
+				{Title: "Update onboarding guide", ProjectName: "Documentation", Status: task.StatusPending, Priority: task.PriorityMedium},
+			},
+			DueSoonTasks: []utils.DigestTask{
+				{Title: "Prepare release notes", ProjectName: "Website Redesign", DueDate: &dueDate},
+			},
+			OverdueTasks: []utils.DigestTask{
+				{Title: "Fix login redirect", ProjectName: "Website Redesign", DueDate: &overdueDate},
+			},
+			Projects: []utils.DigestProject{
+				{Name: "Website Redesign", Total: 12, Completed: 9, Percent: 75},
+			},
+			UnsubscribeURL: unsubscribeURL,
+		}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/utils"
+	"github.com/spf13/cobra"
+)
+
+var rotateKeep bool
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage JWT signing keys",
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Create a new JWT signing key",
+	Long:  `Create a new JWT_ALGORITHM private key in JWT_KEYS_DIR. Running servers start signing with it within a minute and keep accepting tokens signed with older keys. Keys replaced longer ago than the access token lifetime are removed unless --keep is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rotateJWTKeys()
+	},
+}
+
+func init() {
+	keysRotateCmd.Flags().BoolVar(&rotateKeep, "keep", false, "keep all older keys")
+	keysCmd.AddCommand(keysRotateCmd)
+}
+
+func rotateJWTKeys() {
+	cfg, err := config.LoadConfig(".")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	now := time.Now()
+
+	id, err := utils.GenerateJWTKey(cfg.JWTKeysDir, cfg.JWTAlgorithm, now)
+	if err != nil {
+		log.Fatalf("Failed to create signing key: %v", err)
+	}
+	fmt.Printf("✅ Created %s signing key %s in %s\n", cfg.JWTAlgorithm, id, cfg.JWTKeysDir)
+
+	if rotateKeep {
+		return
+	}
+
+	// Servers may keep signing with the old key until they reload the directory
+	retain := utils.AccessTokenTTL + time.Hour
+	removed, err := utils.PruneJWTKeys(cfg.JWTKeysDir, cfg.JWTAlgorithm, retain, now)
+	for _, oldID := range removed {
+		fmt.Printf("🗑  Removed expired signing key %s\n", oldID)
+	}
+	if err != nil {
+		log.Fatalf("Failed to remove old signing keys: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/db"
 	"github.com/mnizarzr/dot-test/jobs"
 	"github.com/spf13/cobra"
 )
@@ -17,8 +18,19 @@ var queueCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal("Error loading config:", err)
 		}
+		if err := config.ValidateSecrets(); err != nil {
+			log.Fatal("Error loading config:", err)
+		}
 
-		jobManager := jobs.NewJobManager(config)
+		database, err := db.NewPostgresGormDb(config.PgUri)
+		if err != nil {
+			log.Fatal("Error connecting to database:", err)
+		}
+
+		jobManager, err := jobs.NewJobManager(config, database)
+		if err != nil {
+			log.Fatal("Error creating job manager:", err)
+		}
 
 		log.Println("Starting job queue worker...")
 		if err := jobManager.Start(); err != nil {
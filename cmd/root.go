@@ -22,4 +22,7 @@ func init() {
 	rootCmd.AddCommand(queueCmd)
 	rootCmd.AddCommand(createAdminCmd)
 	rootCmd.AddCommand(seedCmd)
+	rootCmd.AddCommand(emailCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(keysCmd)
 }
@@ -1,43 +1,151 @@
 package common
 
-import "errors"
+import "net/http"
 
-var ErrForbidden = errors.New("forbidden")
+var ErrForbidden = NewAppError(http.StatusForbidden, "forbidden", "forbidden")
+
+// Request errors
+var (
+	ErrInvalidRequestBody    = NewAppError(http.StatusBadRequest, "invalid_request_body", "request body or parameters are malformed")
+	ErrInvalidFieldType      = NewAppError(http.StatusBadRequest, "invalid_field_type", "{field} must be {type}")
+	ErrInvalidID             = NewAppError(http.StatusBadRequest, "invalid_id", "invalid ID format")
+	ErrValidationFailed      = NewAppError(http.StatusUnprocessableEntity, "validation_failed", "validation failed")
+	ErrTooManyRequests       = NewAppError(http.StatusTooManyRequests, "rate_limited", "too many requests, try again later")
+	ErrInvalidIfMatch        = NewAppError(http.StatusBadRequest, "invalid_if_match", "If-Match must be the ETag of the resource")
+	ErrPreconditionRequired  = NewAppError(http.StatusPreconditionRequired, "precondition_required", "send the resource's ETag in If-Match or its version in the request body")
+	ErrPreconditionFailed    = NewAppError(http.StatusPreconditionFailed, "precondition_failed", "the resource was changed by another request, fetch it again and retry")
+	ErrUnsupportedPatchType  = NewAppError(http.StatusUnsupportedMediaType, "unsupported_patch_type", "PATCH requests must be application/merge-patch+json or application/json-patch+json")
+	ErrInvalidPatch          = NewAppError(http.StatusBadRequest, "invalid_patch", "operation {index} of the patch is invalid")
+	ErrPatchTestFailed       = NewAppError(http.StatusConflict, "patch_test_failed", "test operation {index} of the patch failed")
+	ErrInvalidIdempotencyKey = NewAppError(http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be 1-255 printable ASCII characters")
+	ErrIdempotencyKeyInUse   = NewAppError(http.StatusConflict, "idempotency_key_in_use", "a request with this Idempotency-Key is still being processed, retry later")
+	ErrIdempotencyKeyReused  = NewAppError(http.StatusUnprocessableEntity, "idempotency_key_reused", "this Idempotency-Key was already used for a different request")
+	ErrInternal              = NewAppError(http.StatusInternalServerError, "internal_error", "internal server error")
+)
+
+// Authentication errors
+var (
+	ErrAuthenticationRequired  = NewAppError(http.StatusUnauthorized, "authentication_required", "authentication required")
+	ErrInvalidAuthHeader       = NewAppError(http.StatusUnauthorized, "invalid_authorization_header", "invalid authorization header format")
+	ErrInvalidToken            = NewAppError(http.StatusUnauthorized, "invalid_token", "invalid or expired token")
+	ErrMissingScope            = NewAppError(http.StatusForbidden, "missing_scope", "API key is missing the {scope} scope")
+	ErrMissingPermission       = NewAppError(http.StatusForbidden, "missing_permission", "missing the {permission} permission")
+	ErrFailedToCheckPermission = NewAppError(http.StatusInternalServerError, "permission_check_failed", "failed to check permissions")
+)
 
 // User-related errors
 var (
-	ErrEmailAlreadyRegistered = errors.New("email already registered")
-	ErrInvalidEmailFormat     = errors.New("invalid email format")
-	ErrInvalidNameFormat      = errors.New("name must be between 2-100 characters and contain only letters, spaces, hyphens, and apostrophes")
-	ErrInvalidPasswordFormat  = errors.New("password must be at least 8 characters long and contain at least one uppercase letter, one lowercase letter, and one digit")
-	ErrUserNotFound           = errors.New("user not found")
-	ErrFailedToCreateUser     = errors.New("failed to create user")
-	ErrFailedToRetrieveUser   = errors.New("failed to retrieve user")
-	ErrFailedToCheckEmail     = errors.New("failed to check email existence")
-	ErrFailedToHashPassword   = errors.New("failed to hash password")
-	ErrInvalidCredentials     = errors.New("invalid email or password")
-	ErrFailedToGenerateToken  = errors.New("failed to generate authentication token")
+	ErrEmailAlreadyRegistered = NewAppError(http.StatusConflict, "email_already_registered", "email already registered")
+	ErrInvalidEmailFormat     = NewAppError(http.StatusBadRequest, "invalid_email", "invalid email format")
+	ErrInvalidNameFormat      = NewAppError(http.StatusBadRequest, "invalid_name", "name must be between 2-100 characters and contain only letters, spaces, hyphens, and apostrophes")
+	ErrInvalidPasswordFormat  = NewAppError(http.StatusBadRequest, "invalid_password", "password must be at least 8 characters long and contain at least one uppercase letter, one lowercase letter, and one digit")
+	ErrInvalidRole            = NewAppError(http.StatusBadRequest, "invalid_role", "invalid role: {role}")
+	ErrCannotAssignRole       = NewAppError(http.StatusForbidden, "cannot_assign_role", "insufficient permissions to assign role")
+	ErrUserNotFound           = NewAppError(http.StatusNotFound, "user_not_found", "user not found")
+	ErrFailedToCreateUser     = NewAppError(http.StatusInternalServerError, "user_create_failed", "failed to create user")
+	ErrFailedToRetrieveUser   = NewAppError(http.StatusInternalServerError, "user_retrieve_failed", "failed to retrieve user")
+	ErrFailedToUpdateUser     = NewAppError(http.StatusInternalServerError, "user_update_failed", "failed to update user")
+	ErrFailedToCheckEmail     = NewAppError(http.StatusInternalServerError, "email_check_failed", "failed to check email existence")
+	ErrFailedToHashPassword   = NewAppError(http.StatusInternalServerError, "password_hash_failed", "failed to hash password")
+	ErrInvalidCredentials     = NewAppError(http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrFailedToGenerateToken  = NewAppError(http.StatusInternalServerError, "token_generation_failed", "failed to generate authentication token")
+)
+
+// Login lockout errors
+var (
+	ErrTooManyLoginAttempts  = NewAppError(http.StatusTooManyRequests, "too_many_login_attempts", "too many failed login attempts, try again later")
+	ErrFailedToUnlockAccount = NewAppError(http.StatusInternalServerError, "account_unlock_failed", "failed to unlock account")
+)
+
+// MFA errors
+var (
+	ErrInvalidMFAToken         = NewAppError(http.StatusUnauthorized, "invalid_mfa_token", "invalid or expired MFA token")
+	ErrInvalidMFACode          = NewAppError(http.StatusUnauthorized, "invalid_mfa_code", "invalid MFA code")
+	ErrMFANotEnabled           = NewAppError(http.StatusBadRequest, "mfa_not_enabled", "MFA is not enabled")
+	ErrMFAAlreadyEnabled       = NewAppError(http.StatusConflict, "mfa_already_enabled", "MFA is already enabled")
+	ErrMFAEnrollmentNotStarted = NewAppError(http.StatusBadRequest, "mfa_enrollment_not_started", "MFA enrollment has not been started")
+	ErrMFARequiredByPolicy     = NewAppError(http.StatusForbidden, "mfa_required", "MFA is required for this role")
+	ErrFailedToRetrieveMFA     = NewAppError(http.StatusInternalServerError, "mfa_retrieve_failed", "failed to retrieve MFA settings")
+	ErrFailedToUpdateMFA       = NewAppError(http.StatusInternalServerError, "mfa_update_failed", "failed to update MFA settings")
+)
+
+// Session errors
+var (
+	ErrSessionNotFound          = NewAppError(http.StatusNotFound, "session_not_found", "session not found")
+	ErrSessionRevoked           = NewAppError(http.StatusUnauthorized, "session_revoked", "session has been revoked or expired")
+	ErrFailedToCreateSession    = NewAppError(http.StatusInternalServerError, "session_create_failed", "failed to create session")
+	ErrFailedToRetrieveSessions = NewAppError(http.StatusInternalServerError, "session_retrieve_failed", "failed to retrieve sessions")
+	ErrFailedToRevokeSession    = NewAppError(http.StatusInternalServerError, "session_revoke_failed", "failed to revoke session")
+)
+
+// Single sign-on errors
+var (
+	ErrOIDCNotConfigured    = NewAppError(http.StatusNotFound, "sso_not_configured", "single sign-on is not configured")
+	ErrOIDCUnavailable      = NewAppError(http.StatusBadGateway, "sso_provider_unavailable", "identity provider is unavailable")
+	ErrInvalidOIDCState     = NewAppError(http.StatusBadRequest, "invalid_sso_state", "invalid or expired single sign-on state, please start again")
+	ErrOIDCLoginFailed      = NewAppError(http.StatusUnauthorized, "sso_login_failed", "single sign-on login failed")
+	ErrOIDCEmailNotVerified = NewAppError(http.StatusForbidden, "sso_email_not_verified", "identity provider did not verify the email address")
+)
+
+// Notification preference errors
+var (
+	ErrFailedToRetrievePreferences = NewAppError(http.StatusInternalServerError, "preferences_retrieve_failed", "failed to retrieve notification preferences")
+	ErrFailedToUpdatePreferences   = NewAppError(http.StatusInternalServerError, "preferences_update_failed", "failed to update notification preferences")
+	ErrInvalidUnsubscribeToken     = NewAppError(http.StatusBadRequest, "invalid_unsubscribe_token", "invalid unsubscribe token")
 )
 
 // Project-related errors
 var (
-	ErrProjectNotFound          = errors.New("project not found")
-	ErrProjectNameAlreadyExists = errors.New("project name already exists")
-	ErrFailedToCreateProject    = errors.New("failed to create project")
-	ErrFailedToRetrieveProject  = errors.New("failed to retrieve project")
-	ErrFailedToRetrieveProjects = errors.New("failed to retrieve projects")
-	ErrFailedToUpdateProject    = errors.New("failed to update project")
-	ErrFailedToDeleteProject    = errors.New("failed to delete project")
-	ErrFailedToCheckProject     = errors.New("failed to check project existence")
+	ErrProjectNotFound          = NewAppError(http.StatusNotFound, "project_not_found", "project not found")
+	ErrProjectNameAlreadyExists = NewAppError(http.StatusConflict, "project_name_taken", "project name already exists")
+	ErrFailedToCreateProject    = NewAppError(http.StatusInternalServerError, "project_create_failed", "failed to create project")
+	ErrFailedToRetrieveProject  = NewAppError(http.StatusInternalServerError, "project_retrieve_failed", "failed to retrieve project")
+	ErrFailedToRetrieveProjects = NewAppError(http.StatusInternalServerError, "project_list_failed", "failed to retrieve projects")
+	ErrFailedToUpdateProject    = NewAppError(http.StatusInternalServerError, "project_update_failed", "failed to update project")
+	ErrFailedToDeleteProject    = NewAppError(http.StatusInternalServerError, "project_delete_failed", "failed to delete project")
+	ErrFailedToCheckProject     = NewAppError(http.StatusInternalServerError, "project_check_failed", "failed to check project existence")
 )
 
 // Task-related errors
 var (
-	ErrTaskNotFound          = errors.New("task not found")
-	ErrFailedToCreateTask    = errors.New("failed to create task")
-	ErrFailedToRetrieveTask  = errors.New("failed to retrieve task")
-	ErrFailedToRetrieveTasks = errors.New("failed to retrieve tasks")
-	ErrFailedToUpdateTask    = errors.New("failed to update task")
-	ErrFailedToDeleteTask    = errors.New("failed to delete task")
-	ErrCannotAssignTask      = errors.New("insufficient permissions to assign tasks")
+	ErrTaskNotFound          = NewAppError(http.StatusNotFound, "task_not_found", "task not found")
+	ErrFailedToCreateTask    = NewAppError(http.StatusInternalServerError, "task_create_failed", "failed to create task")
+	ErrFailedToRetrieveTask  = NewAppError(http.StatusInternalServerError, "task_retrieve_failed", "failed to retrieve task")
+	ErrFailedToRetrieveTasks = NewAppError(http.StatusInternalServerError, "task_list_failed", "failed to retrieve tasks")
+	ErrFailedToUpdateTask    = NewAppError(http.StatusInternalServerError, "task_update_failed", "failed to update task")
+	ErrFailedToDeleteTask    = NewAppError(http.StatusInternalServerError, "task_delete_failed", "failed to delete task")
+	ErrCannotAssignTask      = NewAppError(http.StatusForbidden, "cannot_assign_task", "insufficient permissions to assign tasks")
+)
+
+// API key errors
+var (
+	ErrInvalidAPIKey                   = NewAppError(http.StatusUnauthorized, "invalid_api_key", "invalid, expired or revoked API key")
+	ErrAPIKeyNotFound                  = NewAppError(http.StatusNotFound, "api_key_not_found", "API key not found")
+	ErrFailedToCreateAPIKey            = NewAppError(http.StatusInternalServerError, "api_key_create_failed", "failed to create API key")
+	ErrFailedToRetrieveAPIKey          = NewAppError(http.StatusInternalServerError, "api_key_retrieve_failed", "failed to retrieve API keys")
+	ErrFailedToRevokeAPIKey            = NewAppError(http.StatusInternalServerError, "api_key_revoke_failed", "failed to revoke API key")
+	ErrServiceAccountNotFound          = NewAppError(http.StatusNotFound, "service_account_not_found", "service account not found")
+	ErrFailedToCreateServiceAccount    = NewAppError(http.StatusInternalServerError, "service_account_create_failed", "failed to create service account")
+	ErrFailedToRetrieveServiceAccounts = NewAppError(http.StatusInternalServerError, "service_account_retrieve_failed", "failed to retrieve service accounts")
+)
+
+// Audit log errors
+var (
+	ErrAuditLogNotFound          = NewAppError(http.StatusNotFound, "audit_log_not_found", "audit log not found")
+	ErrFailedToRetrieveAuditLogs = NewAppError(http.StatusInternalServerError, "audit_log_retrieve_failed", "failed to retrieve audit logs")
+	ErrInvalidCursor             = NewAppError(http.StatusBadRequest, "invalid_cursor", "invalid pagination cursor")
+)
+
+// Role errors
+var (
+	ErrRoleNotFound         = NewAppError(http.StatusNotFound, "role_not_found", "role not found")
+	ErrRoleAlreadyExists    = NewAppError(http.StatusConflict, "role_already_exists", "role already exists")
+	ErrRoleInUse            = NewAppError(http.StatusConflict, "role_in_use", "role is still assigned to users")
+	ErrBuiltInRole          = NewAppError(http.StatusBadRequest, "built_in_role", "built-in roles cannot be changed")
+	ErrInvalidRoleName      = NewAppError(http.StatusBadRequest, "invalid_role_name", "role name must be 2-20 lowercase letters, digits, hyphens or underscores, starting with a letter")
+	ErrUnknownPermission    = NewAppError(http.StatusBadRequest, "unknown_permission", "unknown permission")
+	ErrFailedToCreateRole   = NewAppError(http.StatusInternalServerError, "role_create_failed", "failed to create role")
+	ErrFailedToRetrieveRole = NewAppError(http.StatusInternalServerError, "role_retrieve_failed", "failed to retrieve roles")
+	ErrFailedToUpdateRole   = NewAppError(http.StatusInternalServerError, "role_update_failed", "failed to update role")
+	ErrFailedToDeleteRole   = NewAppError(http.StatusInternalServerError, "role_delete_failed", "failed to delete role")
 )
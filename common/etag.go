@@ -0,0 +1,59 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag formats the entity tag of a resource version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// NotModified sets the ETag header of a resource and answers 304 Not Modified
+// when the client's If-None-Match already names that version. Handlers return
+// without writing a body when it reports true.
+func NotModified(c *gin.Context, version int) bool {
+	etag := ETag(version)
+	c.Header("ETag", etag)
+
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch returns the version a conditional update expects: the one in the
+// If-Match header, or else the version field of the request body. A nil
+// version without an error means If-Match: *, which matches any version.
+// Updates without either are rejected, so clients cannot overwrite changes
+// they have not seen.
+func IfMatch(c *gin.Context, bodyVersion *int) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if bodyVersion == nil {
+			return nil, ErrPreconditionRequired
+		}
+		return bodyVersion, nil
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, ErrInvalidIfMatch
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return nil, ErrInvalidIfMatch
+	}
+	return &version, nil
+}
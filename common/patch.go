@@ -0,0 +1,260 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Media types of PATCH request bodies
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// Patch is a change to the JSON document of a resource, either a JSON Merge
+// Patch (RFC 7396) or a JSON Patch (RFC 6902)
+type Patch interface {
+	apply(doc map[string]interface{}) (map[string]interface{}, error)
+}
+
+// ParsePatch reads the patch in a PATCH request body, picking the format by
+// the Content-Type header
+func ParsePatch(c *gin.Context) (Patch, error) {
+	contentType := c.ContentType()
+	if contentType != MergePatchContentType && contentType != JSONPatchContentType {
+		return nil, ErrUnsupportedPatchType
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, ErrInvalidRequestBody
+	}
+
+	if contentType == JSONPatchContentType {
+		var ops jsonPatch
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, err
+		}
+		return ops, nil
+	}
+
+	// Resources are objects, so their merge patches are too
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, err
+	}
+	if patch == nil {
+		return nil, ErrInvalidRequestBody
+	}
+	return mergePatch(patch), nil
+}
+
+// ApplyPatch applies a patch to target, a struct holding the fields of a
+// resource that clients can change, and validates the result against the
+// binding rules of target. A field the patch removes or sets to null is
+// reset to its zero value. It returns the JSON names of the fields whose
+// value changed.
+func ApplyPatch(patch Patch, target interface{}) ([]string, error) {
+	data, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode twice, since patches change the document in place
+	var original, doc map[string]interface{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	doc, err = patch.apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for field := range doc {
+		if _, ok := original[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		details := make([]FieldError, 0, len(unknown))
+		for _, field := range unknown {
+			details = append(details, newFieldError(field, "read_only", "read_only", map[string]string{"field": field}))
+		}
+		return nil, ErrValidationFailed.WithDetails(details)
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(patched, target); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(target); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for field, value := range original {
+		if !reflect.DeepEqual(value, doc[field]) {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// mergePatch is a JSON Merge Patch: an object whose members replace those
+// of the document, with null removing a member
+type mergePatch map[string]interface{}
+
+func (p mergePatch) apply(doc map[string]interface{}) (map[string]interface{}, error) {
+	return mergeObjects(doc, p), nil
+}
+
+// mergeObjects merges a patch object into a document object as RFC 7396
+// describes
+func mergeObjects(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	for name, value := range patch {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			current, _ := doc[name].(map[string]interface{})
+			doc[name] = mergeObjects(current, object)
+			continue
+		}
+		doc[name] = value
+	}
+	return doc
+}
+
+// jsonPatch is a JSON Patch: a list of operations applied in order
+type jsonPatch []patchOperation
+
+// patchOperation is one JSON Patch operation. Value stays nil when the
+// operation has none, so an explicit null can be told apart.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (p jsonPatch) apply(doc map[string]interface{}) (map[string]interface{}, error) {
+	for i, op := range p {
+		if err := op.apply(doc); err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return nil, ErrPatchTestFailed.WithParam("index", strconv.Itoa(i))
+			}
+			return nil, ErrInvalidPatch.WithParam("index", strconv.Itoa(i))
+		}
+	}
+	return doc, nil
+}
+
+func (op patchOperation) apply(doc map[string]interface{}) error {
+	parent, name, err := pointerParent(doc, op.Path)
+	if err != nil {
+		return err
+	}
+	current, exists := parent[name]
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return ErrInvalidPatch
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return err
+		}
+		if op.Op == "test" {
+			if !exists || !reflect.DeepEqual(current, value) {
+				return ErrPatchTestFailed
+			}
+			return nil
+		}
+		if op.Op == "replace" && !exists {
+			return ErrInvalidPatch
+		}
+		parent[name] = value
+
+	case "remove":
+		if !exists {
+			return ErrInvalidPatch
+		}
+		delete(parent, name)
+
+	case "move", "copy":
+		// An object cannot be moved into one of its own members
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return ErrInvalidPatch
+		}
+		fromParent, fromName, err := pointerParent(doc, op.From)
+		if err != nil {
+			return err
+		}
+		value, ok := fromParent[fromName]
+		if !ok {
+			return ErrInvalidPatch
+		}
+		if op.Op == "move" {
+			delete(fromParent, fromName)
+		} else {
+			// Copies must not share nested objects with the original
+			data, _ := json.Marshal(value)
+			_ = json.Unmarshal(data, &value)
+		}
+		parent[name] = value
+
+	default:
+		return ErrInvalidPatch
+	}
+	return nil
+}
+
+// pointerParent resolves a JSON Pointer (RFC 6901) to the object holding its
+// last member and that member's name. Resource documents are objects, so
+// pointers into arrays or to the whole document are rejected.
+func pointerParent(doc map[string]interface{}, pointer string) (map[string]interface{}, string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, "", ErrInvalidPatch
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+
+	parent := doc
+	for _, token := range tokens[:len(tokens)-1] {
+		child, ok := parent[token].(map[string]interface{})
+		if !ok {
+			return nil, "", ErrInvalidPatch
+		}
+		parent = child
+	}
+	return parent, tokens[len(tokens)-1], nil
+}
@@ -0,0 +1,318 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// patchTarget is a resource document with required, optional and nested
+// fields
+type patchTarget struct {
+	Title    string            `json:"title" binding:"required,max=20"`
+	Note     string            `json:"note"`
+	DueDate  *string           `json:"due_date"`
+	Labels   map[string]string `json:"labels"`
+	Settings *patchSettings    `json:"settings"`
+	Version  int               `json:"version"`
+}
+
+type patchSettings struct {
+	Color  string `json:"color"`
+	Weight int    `json:"weight"`
+}
+
+func newPatchTarget() patchTarget {
+	due := "2026-03-02"
+	return patchTarget{
+		Title:    "Write report",
+		Note:     "draft",
+		DueDate:  &due,
+		Labels:   map[string]string{"a/b": "slash", "c~d": "tilde"},
+		Settings: &patchSettings{Color: "red", Weight: 2},
+		Version:  3,
+	}
+}
+
+func parseTestPatch(t *testing.T, contentType, body string) Patch {
+	t.Helper()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+
+	patch, err := ParsePatch(c)
+	if err != nil {
+		t.Fatalf("ParsePatch(%s): %v", body, err)
+	}
+	return patch
+}
+
+func applyTestPatch(t *testing.T, contentType, body string) (patchTarget, []string, error) {
+	t.Helper()
+
+	target := newPatchTarget()
+	changed, err := ApplyPatch(parseTestPatch(t, contentType, body), &target)
+	return target, changed, err
+}
+
+func TestParsePatchRejectsOtherContentTypes(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"title":"x"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	if _, err := ParsePatch(c); !errors.Is(err, ErrUnsupportedPatchType) {
+		t.Errorf("ParsePatch error = %v, want ErrUnsupportedPatchType", err)
+	}
+}
+
+func TestMergePatchNullRemovesAndAbsentKeeps(t *testing.T) {
+	target, changed, err := applyTestPatch(t, MergePatchContentType, `{"note":null,"due_date":null,"title":"Final report"}`)
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+
+	if target.Note != "" || target.DueDate != nil {
+		t.Errorf("null fields were not reset: note=%q due_date=%v", target.Note, target.DueDate)
+	}
+	if target.Title != "Final report" {
+		t.Errorf("title = %q", target.Title)
+	}
+	// Fields the patch leaves out keep their value
+	if target.Settings == nil || target.Settings.Color != "red" || target.Labels["a/b"] != "slash" || target.Version != 3 {
+		t.Errorf("absent fields changed: %+v", target)
+	}
+	if want := []string{"due_date", "note", "title"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestMergePatchMergesNestedObjects(t *testing.T) {
+	target, changed, err := applyTestPatch(t, MergePatchContentType, `{"settings":{"weight":5},"labels":{"c~d":null,"new":"label"}}`)
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+
+	if *target.Settings != (patchSettings{Color: "red", Weight: 5}) {
+		t.Errorf("settings = %+v", *target.Settings)
+	}
+	if want := map[string]string{"a/b": "slash", "new": "label"}; !reflect.DeepEqual(target.Labels, want) {
+		t.Errorf("labels = %v, want %v", target.Labels, want)
+	}
+	if want := []string{"labels", "settings"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestMergePatchWithoutChanges(t *testing.T) {
+	_, changed, err := applyTestPatch(t, MergePatchContentType, `{"title":"Write report"}`)
+	if err != nil || len(changed) != 0 {
+		t.Errorf("ApplyPatch = %v, %v, want no changes", changed, err)
+	}
+}
+
+func TestJSONPatchOperations(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		check   func(patchTarget) bool
+		changed []string
+	}{
+		{
+			name:    "add replaces an existing member",
+			patch:   `[{"op":"add","path":"/note","value":"final"}]`,
+			check:   func(p patchTarget) bool { return p.Note == "final" },
+			changed: []string{"note"},
+		},
+		{
+			name:    "add creates a nested member",
+			patch:   `[{"op":"add","path":"/labels/new","value":"label"}]`,
+			check:   func(p patchTarget) bool { return p.Labels["new"] == "label" && len(p.Labels) == 3 },
+			changed: []string{"labels"},
+		},
+		{
+			name:    "replace",
+			patch:   `[{"op":"replace","path":"/settings/color","value":"blue"}]`,
+			check:   func(p patchTarget) bool { return p.Settings.Color == "blue" && p.Settings.Weight == 2 },
+			changed: []string{"settings"},
+		},
+		{
+			name:    "remove resets to the zero value",
+			patch:   `[{"op":"remove","path":"/due_date"},{"op":"remove","path":"/note"}]`,
+			check:   func(p patchTarget) bool { return p.DueDate == nil && p.Note == "" },
+			changed: []string{"due_date", "note"},
+		},
+		{
+			name:    "add with null value",
+			patch:   `[{"op":"add","path":"/due_date","value":null}]`,
+			check:   func(p patchTarget) bool { return p.DueDate == nil },
+			changed: []string{"due_date"},
+		},
+		{
+			name:    "move",
+			patch:   `[{"op":"move","from":"/settings/color","path":"/labels/color"}]`,
+			check:   func(p patchTarget) bool { return p.Labels["color"] == "red" && p.Settings.Color == "" },
+			changed: []string{"labels", "settings"},
+		},
+		{
+			name:    "copy",
+			patch:   `[{"op":"copy","from":"/note","path":"/title"}]`,
+			check:   func(p patchTarget) bool { return p.Title == "draft" && p.Note == "draft" },
+			changed: []string{"title"},
+		},
+		{
+			name:    "passing test",
+			patch:   `[{"op":"test","path":"/settings/weight","value":2},{"op":"replace","path":"/note","value":"checked"}]`,
+			check:   func(p patchTarget) bool { return p.Note == "checked" },
+			changed: []string{"note"},
+		},
+		{
+			name:    "escaped slash",
+			patch:   `[{"op":"replace","path":"/labels/a~1b","value":"escaped"}]`,
+			check:   func(p patchTarget) bool { return p.Labels["a/b"] == "escaped" },
+			changed: []string{"labels"},
+		},
+		{
+			name:    "escaped tilde",
+			patch:   `[{"op":"remove","path":"/labels/c~0d"}]`,
+			check:   func(p patchTarget) bool { _, ok := p.Labels["c~d"]; return !ok && len(p.Labels) == 1 },
+			changed: []string{"labels"},
+		},
+		{
+			name:    "operations apply in order",
+			patch:   `[{"op":"add","path":"/labels/tmp","value":"x"},{"op":"move","from":"/labels/tmp","path":"/note"}]`,
+			check:   func(p patchTarget) bool { _, ok := p.Labels["tmp"]; return p.Note == "x" && !ok },
+			changed: []string{"note"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, changed, err := applyTestPatch(t, JSONPatchContentType, tt.patch)
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if !tt.check(target) {
+				t.Errorf("patched document = %+v", target)
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestJSONPatchCopyDoesNotShareObjects(t *testing.T) {
+	patch := jsonPatch{
+		{Op: "copy", From: "/settings", Path: "/labels"},
+		{Op: "replace", Path: "/labels/color", Value: json.RawMessage(`"blue"`)},
+	}
+	doc := map[string]interface{}{
+		"settings": map[string]interface{}{"color": "red"},
+	}
+
+	if _, err := patch.apply(doc); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if color := doc["settings"].(map[string]interface{})["color"]; color != "red" {
+		t.Errorf("changing the copy changed the original to %v", color)
+	}
+}
+
+func TestJSONPatchInvalidOperations(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		index string
+	}{
+		{"unknown op", `[{"op":"merge","path":"/note","value":"x"}]`, "0"},
+		{"add without value", `[{"op":"add","path":"/note"}]`, "0"},
+		{"replace missing member", `[{"op":"replace","path":"/labels/missing","value":"x"}]`, "0"},
+		{"remove missing member", `[{"op":"test","path":"/note","value":"draft"},{"op":"remove","path":"/labels/missing"}]`, "1"},
+		{"move from missing member", `[{"op":"move","from":"/labels/missing","path":"/note"}]`, "0"},
+		{"move into itself", `[{"op":"move","from":"/settings","path":"/settings/inner"}]`, "0"},
+		{"pointer without slash", `[{"op":"add","path":"note","value":"x"}]`, "0"},
+		{"whole document", `[{"op":"add","path":"","value":{}}]`, "0"},
+		{"through a missing object", `[{"op":"add","path":"/missing/note","value":"x"}]`, "0"},
+		{"through a string", `[{"op":"add","path":"/note/inner","value":"x"}]`, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := applyTestPatch(t, JSONPatchContentType, tt.patch)
+			var appErr *AppError
+			if !errors.As(err, &appErr) || appErr.Code != ErrInvalidPatch.Code {
+				t.Fatalf("ApplyPatch error = %v, want ErrInvalidPatch", err)
+			}
+			if appErr.Params["index"] != tt.index {
+				t.Errorf("index = %q, want %q", appErr.Params["index"], tt.index)
+			}
+		})
+	}
+}
+
+func TestJSONPatchFailedTest(t *testing.T) {
+	for _, patch := range []string{
+		`[{"op":"replace","path":"/note","value":"x"},{"op":"test","path":"/settings/weight","value":"2"}]`,
+		`[{"op":"replace","path":"/note","value":"x"},{"op":"test","path":"/labels/missing","value":null}]`,
+	} {
+		_, _, err := applyTestPatch(t, JSONPatchContentType, patch)
+		var appErr *AppError
+		if !errors.As(err, &appErr) || appErr.Code != ErrPatchTestFailed.Code || appErr.Status != http.StatusConflict {
+			t.Errorf("ApplyPatch(%s) error = %v, want ErrPatchTestFailed", patch, err)
+			continue
+		}
+		if appErr.Params["index"] != "1" {
+			t.Errorf("index = %q, want 1", appErr.Params["index"])
+		}
+	}
+}
+
+func TestApplyPatchRejectsUnknownFieldsAsReadOnly(t *testing.T) {
+	for contentType, patch := range map[string]string{
+		MergePatchContentType: `{"id":"x","note":"y","created_at":"2026-01-01"}`,
+		JSONPatchContentType:  `[{"op":"add","path":"/id","value":"x"},{"op":"copy","from":"/note","path":"/created_at"}]`,
+	} {
+		target := newPatchTarget()
+		_, err := ApplyPatch(parseTestPatch(t, contentType, patch), &target)
+
+		var appErr *AppError
+		if !errors.As(err, &appErr) || appErr.Code != ErrValidationFailed.Code {
+			t.Errorf("%s: error = %v, want ErrValidationFailed", contentType, err)
+			continue
+		}
+		var fields []string
+		for _, detail := range appErr.Details {
+			if detail.Code != "read_only" {
+				t.Errorf("%s: %s has code %q, want read_only", contentType, detail.Field, detail.Code)
+			}
+			fields = append(fields, detail.Field)
+		}
+		if want := []string{"created_at", "id"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("%s: read-only fields = %v, want %v", contentType, fields, want)
+		}
+
+		// A rejected patch leaves the target alone
+		if !reflect.DeepEqual(target, newPatchTarget()) {
+			t.Errorf("%s: target changed to %+v", contentType, target)
+		}
+	}
+}
+
+func TestApplyPatchValidatesResult(t *testing.T) {
+	for contentType, patch := range map[string]string{
+		MergePatchContentType: `{"title":null}`,
+		JSONPatchContentType:  `[{"op":"replace","path":"/title","value":"a title longer than twenty"}]`,
+	} {
+		target := newPatchTarget()
+		if _, err := ApplyPatch(parseTestPatch(t, contentType, patch), &target); err == nil {
+			t.Errorf("%s: ApplyPatch accepted an invalid title", contentType)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Authentication methods of a principal
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Principal is the authenticated caller of a request. The auth middleware
+// stores it in the request context, so services get the caller in one typed
+// value however it authenticated.
+type Principal struct {
+	UserID     uuid.UUID
+	Email      string
+	Role       string
+	AuthMethod string
+
+	// SessionID is the login session of a JWT
+	SessionID string
+
+	// APIKeyID and Scopes are set for API keys, which are limited to their scopes
+	APIKeyID uuid.UUID
+	Scopes   []string
+}
+
+// IsAPIKey reports whether the principal authenticated with an API key
+func (p *Principal) IsAPIKey() bool {
+	return p.AuthMethod == AuthMethodAPIKey
+}
+
+// HasScope reports whether an API key principal was granted a scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of a request context, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// CurrentPrincipal returns the principal of a request, or records
+// ErrAuthenticationRequired and returns false when the request is not
+// authenticated
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	principal, ok := PrincipalFromContext(c.Request.Context())
+	if !ok {
+		c.Error(ErrAuthenticationRequired)
+		return nil, false
+	}
+	return principal, true
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// ProblemContentType is the media type of problem details responses
+const ProblemContentType = "application/problem+json"
+
+// AppError is an error the API reports to clients, with the HTTP status and
+// a stable machine-readable code. Handlers pass it to c.Error and the error
+// middleware renders it as problem details. Message is the English text and
+// may hold {name} placeholders filled from Params; responses use the
+// translation of Code in the request's language.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Params  map[string]string
+	Details []FieldError
+}
+
+// NewAppError creates an application error
+func NewAppError(status int, code, message string) *AppError {
+	return &AppError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *AppError) Error() string {
+	return i18n.Format(e.Message, e.Params)
+}
+
+// Is matches application errors by code, so copies made with WithParam or
+// WithDetails still match their sentinel
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// WithParam returns a copy of the error that fills a placeholder of its
+// message
+func (e *AppError) WithParam(name, value string) *AppError {
+	copied := *e
+	copied.Params = make(map[string]string, len(e.Params)+1)
+	for k, v := range e.Params {
+		copied.Params[k] = v
+	}
+	copied.Params[name] = value
+	return &copied
+}
+
+// WithDetails returns a copy of the error with per-field details
+func (e *AppError) WithDetails(details []FieldError) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// FieldError describes why one field of a request is invalid. Message is the
+// English text; the catalog message of the rule replaces it in responses.
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Code    string `json:"code" example:"email"`
+	Message string `json:"message" example:"email must be a valid email address"`
+
+	// rule is the catalog key of the message, params fill its placeholders
+	rule   string
+	params map[string]string
+}
+
+// Problem represents an RFC 7807 problem details response
+type Problem struct {
+	Type     string       `json:"type" example:"about:blank"`
+	Title    string       `json:"title" example:"Not Found"`
+	Status   int          `json:"status" example:"404"`
+	Detail   string       `json:"detail" example:"task not found"`
+	Instance string       `json:"instance,omitempty" example:"/api/v1/tasks/0b7c5c1e-5a3f-4f4e-9d3a-2f1f7d6c8e90"`
+	Code     string       `json:"code" example:"task_not_found"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem creates the problem details of an application error in a
+// language
+func NewProblem(err *AppError, instance, lang string) Problem {
+	var details []FieldError
+	for _, fe := range err.Details {
+		if fe.rule != "" {
+			fe.Message = i18n.Format(i18n.Validation(lang, fe.rule, fe.Message), fe.params)
+		}
+		details = append(details, fe)
+	}
+
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.Status),
+		Status:   err.Status,
+		Detail:   i18n.Format(i18n.Error(lang, err.Code, err.Message), err.Params),
+		Instance: instance,
+		Code:     err.Code,
+		Errors:   details,
+	}
+}
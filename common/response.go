@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/i18n"
 )
 
 // BaseResponse represents the standard API response structure
@@ -13,15 +14,19 @@ type BaseResponse struct {
 	Data    interface{} `json:"data"`
 }
 
-// SuccessResponse creates a success response
+// SuccessResponse creates a success response. The message is the English
+// text, translated to the request's language.
 func SuccessResponse(c *gin.Context, data interface{}, message string) {
 	if message == "" {
 		message = "Success"
 	}
 
+	lang := i18n.Language(c.Request.Context())
+	c.Header("Content-Language", lang)
+
 	response := BaseResponse{
 		Code:    http.StatusOK,
-		Message: message,
+		Message: i18n.Message(lang, message),
 		Data:    data,
 	}
 
@@ -34,57 +39,14 @@ func CreatedResponse(c *gin.Context, data interface{}, message string) {
 		message = "Resource created successfully"
 	}
 
+	lang := i18n.Language(c.Request.Context())
+	c.Header("Content-Language", lang)
+
 	response := BaseResponse{
 		Code:    http.StatusCreated,
-		Message: message,
+		Message: i18n.Message(lang, message),
 		Data:    data,
 	}
 
 	c.JSON(http.StatusCreated, response)
 }
-
-// ErrorResponse creates an error response
-func ErrorResponse(c *gin.Context, statusCode int, message string) {
-	response := BaseResponse{
-		Code:    statusCode,
-		Message: message,
-		Data:    nil,
-	}
-
-	c.JSON(statusCode, response)
-}
-
-// BadRequestResponse creates a bad request error response
-func BadRequestResponse(c *gin.Context, message string) {
-	if message == "" {
-		message = "Bad request"
-	}
-	ErrorResponse(c, http.StatusBadRequest, message)
-}
-
-// ValidationErrorResponse creates a validation error response
-func ValidationErrorResponse(c *gin.Context, errors interface{}) {
-	response := BaseResponse{
-		Code:    http.StatusUnprocessableEntity,
-		Message: "Validation failed",
-		Data:    errors,
-	}
-
-	c.JSON(http.StatusUnprocessableEntity, response)
-}
-
-// InternalServerErrorResponse creates an internal server error response
-func InternalServerErrorResponse(c *gin.Context, message string) {
-	if message == "" {
-		message = "Internal server error"
-	}
-	ErrorResponse(c, http.StatusInternalServerError, message)
-}
-
-// ConflictResponse creates a conflict error response
-func ConflictResponse(c *gin.Context, message string) {
-	if message == "" {
-		message = "Resource already exists"
-	}
-	ErrorResponse(c, http.StatusConflict, message)
-}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// FieldName returns the name clients use for a struct field: its JSON name,
+// or its query parameter name for query structs. It is registered with the
+// validator so validation errors name fields the way requests spell them.
+func FieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// NewValidationError translates validator errors into a validation failure
+// with one message per invalid field
+func NewValidationError(errs validator.ValidationErrors) *AppError {
+	details := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		rule, params := fieldRule(fe)
+		details = append(details, newFieldError(params["field"], fe.Tag(), rule, params))
+	}
+	return ErrValidationFailed.WithDetails(details)
+}
+
+// newFieldError describes an invalid field with the catalog message of a
+// rule, keeping the rule so responses can translate it
+func newFieldError(field, code, rule string, params map[string]string) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    code,
+		Message: i18n.Format(i18n.Validation(i18n.Default, rule, rule), params),
+		rule:    rule,
+		params:  params,
+	}
+}
+
+// fieldPath returns the field of an error without the request struct name,
+// e.g. tags[0] instead of CreateTaskRequest.tags[0]
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+// fieldRule returns the catalog key describing a failed validation rule and
+// the values of its placeholders
+func fieldRule(fe validator.FieldError) (string, map[string]string) {
+	params := map[string]string{"field": fieldPath(fe), "param": fe.Param()}
+
+	switch fe.Tag() {
+	case "required", "email", "ip":
+		return fe.Tag(), params
+	case "oneof":
+		params["param"] = strings.ReplaceAll(fe.Param(), " ", ", ")
+		return "oneof", params
+	case "min", "max":
+		return fe.Tag() + lengthSuffix(fe.Kind()), params
+	default:
+		return "invalid", params
+	}
+}
+
+// lengthSuffix picks the variant of min and max rules for a kind: those on
+// strings count characters, those on collections count items
+func lengthSuffix(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "_length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "_items"
+	}
+	return ""
+}
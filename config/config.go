@@ -1,6 +1,11 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/viper"
+)
 
 var Configs *Config
 
@@ -9,6 +14,7 @@ type Config struct {
 	AppPort       int    `mapstructure:"APP_PORT"`
 	AppHost       string `mapstructure:"APP_HOST"`
 	AppName       string `mapstructure:"APP_NAME"`
+	AppUrl        string `mapstructure:"APP_URL"`
 	PgUri         string `mapstructure:"POSTGRES_URI"`
 	RedisAddress  string `mapstructure:"REDIS_ADDRESS"`
 	RedisPassword string `mapstructure:"REDIS_PASSWORD"`
@@ -19,6 +25,59 @@ type Config struct {
 	SmtpFrom      string `mapstructure:"SMTP_FROM"`
 	JWTSecret     string `mapstructure:"JWT_SECRET"`
 	HolidayApiKey string `mapstructure:"HOLIDAY_API_KEY"`
+
+	MailDriver      string `mapstructure:"MAIL_DRIVER"`
+	MailDir         string `mapstructure:"MAIL_DIR"`
+	SmtpPoolSize    int    `mapstructure:"SMTP_POOL_SIZE"`
+	SmtpIdleTimeout int    `mapstructure:"SMTP_IDLE_TIMEOUT"`
+
+	ReminderScanCron     string `mapstructure:"REMINDER_SCAN_CRON"`
+	ReminderDueSoonHours int    `mapstructure:"REMINDER_DUE_SOON_HOURS"`
+	DigestCron           string `mapstructure:"DIGEST_CRON"`
+	DigestWeeklyDay      string `mapstructure:"DIGEST_WEEKLY_DAY"`
+
+	AuditRetentionMonths int    `mapstructure:"AUDIT_RETENTION_MONTHS"`
+	AuditArchiveDir      string `mapstructure:"AUDIT_ARCHIVE_DIR"`
+	AuditMaintenanceCron string `mapstructure:"AUDIT_MAINTENANCE_CRON"`
+
+	AuditSink          string `mapstructure:"AUDIT_SINK"`
+	AuditSinkTarget    string `mapstructure:"AUDIT_SINK_TARGET"`
+	AuditSinkToken     string `mapstructure:"AUDIT_SINK_TOKEN"`
+	AuditSinkBatchSize int    `mapstructure:"AUDIT_SINK_BATCH_SIZE"`
+	AuditSinkBatchWait int    `mapstructure:"AUDIT_SINK_BATCH_WAIT"`
+
+	AuditFailedAuth    bool   `mapstructure:"AUDIT_FAILED_AUTH"`
+	AuditAccessDenied  bool   `mapstructure:"AUDIT_ACCESS_DENIED"`
+	AuditReadResources string `mapstructure:"AUDIT_READ_RESOURCES"`
+
+	LoginMaxAccountFailures int `mapstructure:"LOGIN_MAX_ACCOUNT_FAILURES"`
+	LoginMaxIPFailures      int `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+	LoginFailureWindow      int `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginLockoutBase        int `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax         int `mapstructure:"LOGIN_LOCKOUT_MAX"`
+
+	RateLimitEnabled   bool   `mapstructure:"RATE_LIMIT_ENABLED"`
+	RateLimitAllowlist string `mapstructure:"RATE_LIMIT_ALLOWLIST"`
+	TrustedProxies     string `mapstructure:"TRUSTED_PROXIES"`
+
+	IdempotencyKeyTTL int `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+
+	MFARequiredRoles string `mapstructure:"MFA_REQUIRED_ROLES"`
+	MFAEncryptionKey string `mapstructure:"MFA_ENCRYPTION_KEY"`
+
+	OIDCIssuerURL     string `mapstructure:"OIDC_ISSUER_URL"`
+	OIDCClientID      string `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret  string `mapstructure:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL   string `mapstructure:"OIDC_REDIRECT_URL"`
+	OIDCScopes        string `mapstructure:"OIDC_SCOPES"`
+	OIDCGroupsClaim   string `mapstructure:"OIDC_GROUPS_CLAIM"`
+	OIDCAdminGroups   string `mapstructure:"OIDC_ADMIN_GROUPS"`
+	OIDCManagerGroups string `mapstructure:"OIDC_MANAGER_GROUPS"`
+
+	JWTAlgorithm string `mapstructure:"JWT_ALGORITHM"`
+	JWTKeysDir   string `mapstructure:"JWT_KEYS_DIR"`
+	JWTIssuer    string `mapstructure:"JWT_ISSUER"`
+	JWTAudience  string `mapstructure:"JWT_AUDIENCE"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -28,6 +87,45 @@ func LoadConfig(path string) (*Config, error) {
 
 	viper.AutomaticEnv()
 
+	viper.SetDefault("APP_URL", "http://localhost:8080")
+	viper.SetDefault("MAIL_DRIVER", "smtp")
+	viper.SetDefault("MAIL_DIR", "tmp/mail")
+	viper.SetDefault("SMTP_POOL_SIZE", 2)
+	viper.SetDefault("SMTP_IDLE_TIMEOUT", 30)
+	viper.SetDefault("REMINDER_SCAN_CRON", "@every 15m")
+	viper.SetDefault("REMINDER_DUE_SOON_HOURS", 24)
+	viper.SetDefault("DIGEST_CRON", "0 7 * * *")
+	viper.SetDefault("DIGEST_WEEKLY_DAY", "monday")
+	viper.SetDefault("AUDIT_RETENTION_MONTHS", 12)
+	viper.SetDefault("AUDIT_ARCHIVE_DIR", "archive/audit")
+	viper.SetDefault("AUDIT_MAINTENANCE_CRON", "0 3 * * *")
+	viper.SetDefault("AUDIT_SINK", "none")
+	viper.SetDefault("AUDIT_SINK_BATCH_SIZE", 100)
+	viper.SetDefault("AUDIT_SINK_BATCH_WAIT", 5)
+	viper.SetDefault("AUDIT_FAILED_AUTH", true)
+	viper.SetDefault("AUDIT_ACCESS_DENIED", true)
+	viper.SetDefault("AUDIT_READ_RESOURCES", "")
+	viper.SetDefault("LOGIN_MAX_ACCOUNT_FAILURES", 5)
+	viper.SetDefault("LOGIN_MAX_IP_FAILURES", 20)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", 900)
+	viper.SetDefault("LOGIN_LOCKOUT_BASE", 60)
+	viper.SetDefault("LOGIN_LOCKOUT_MAX", 3600)
+	viper.SetDefault("RATE_LIMIT_ENABLED", true)
+	viper.SetDefault("RATE_LIMIT_ALLOWLIST", "")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", 86400)
+	viper.SetDefault("MFA_REQUIRED_ROLES", "admin,manager")
+	viper.SetDefault("MFA_ENCRYPTION_KEY", "")
+	viper.SetDefault("OIDC_ISSUER_URL", "")
+	viper.SetDefault("OIDC_SCOPES", "openid email profile")
+	viper.SetDefault("OIDC_GROUPS_CLAIM", "groups")
+	viper.SetDefault("OIDC_ADMIN_GROUPS", "")
+	viper.SetDefault("OIDC_MANAGER_GROUPS", "")
+	viper.SetDefault("JWT_ALGORITHM", "HS256")
+	viper.SetDefault("JWT_KEYS_DIR", "keys/jwt")
+	viper.SetDefault("JWT_ISSUER", "")
+	viper.SetDefault("JWT_AUDIENCE", "")
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		return nil, err
@@ -36,3 +134,13 @@ func LoadConfig(path string) (*Config, error) {
 	err = viper.Unmarshal(&Configs)
 	return Configs, err
 }
+
+// ValidateSecrets checks the secrets the API and the queue worker cannot run
+// without. JWT_SECRET signs unsubscribe links and, without MFA_ENCRYPTION_KEY,
+// encrypts TOTP secrets, so it is needed whatever JWT_ALGORITHM is.
+func (c *Config) ValidateSecrets() error {
+	if strings.TrimSpace(c.JWTSecret) == "" {
+		return errors.New("JWT_SECRET is required: it signs unsubscribe links and encrypts TOTP secrets unless MFA_ENCRYPTION_KEY is set")
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestValidateSecretsRequiresJWTSecret(t *testing.T) {
+	for _, algorithm := range []string{"HS256", "RS256", "EdDSA"} {
+		for _, secret := range []string{"", "   "} {
+			cfg := &Config{JWTAlgorithm: algorithm, JWTSecret: secret, MFAEncryptionKey: "mfa-key"}
+			if err := cfg.ValidateSecrets(); err == nil {
+				t.Errorf("%s: accepted JWT_SECRET %q", algorithm, secret)
+			}
+		}
+
+		cfg := &Config{JWTAlgorithm: algorithm, JWTSecret: "secret"}
+		if err := cfg.ValidateSecrets(); err != nil {
+			t.Errorf("%s: ValidateSecrets: %v", algorithm, err)
+		}
+	}
+}
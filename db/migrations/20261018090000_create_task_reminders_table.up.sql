@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS task_reminders (
+    id          UUID PRIMARY KEY,
+    task_id     UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    threshold   VARCHAR(20) CHECK (threshold IN ('due_soon', 'overdue')) NOT NULL,
+    due_date    DATE NOT NULL,
+    notified_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (task_id, threshold, due_date)
+);
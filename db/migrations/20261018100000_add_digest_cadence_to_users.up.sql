@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_cadence VARCHAR(10) CHECK (digest_cadence IN ('none', 'daily', 'weekly')) NOT NULL DEFAULT 'none';
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reminder_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET reminder_opt_out = TRUE
+WHERE id IN (SELECT user_id FROM notification_preferences WHERE event_type = 'due_soon' AND channel <> 'email');
+
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_type VARCHAR(20) CHECK (event_type IN ('assigned', 'mentioned', 'comment', 'due_soon', 'digest')) NOT NULL,
+    channel    VARCHAR(20) CHECK (channel IN ('email', 'in_app', 'webhook', 'none')) NOT NULL DEFAULT 'email',
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, event_type)
+);
+
+INSERT INTO notification_preferences (user_id, event_type, channel)
+SELECT id, 'due_soon', 'none' FROM users WHERE reminder_opt_out;
+
+ALTER TABLE users DROP COLUMN IF EXISTS reminder_opt_out;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_audit_logs_ip_address;
+DROP INDEX IF EXISTS idx_audit_logs_user_id;
+DROP INDEX IF EXISTS idx_audit_logs_target;
+DROP INDEX IF EXISTS idx_audit_logs_created_at_id;
@@ -0,0 +1,3 @@
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS seq;
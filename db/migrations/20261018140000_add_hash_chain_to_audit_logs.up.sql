@@ -0,0 +1,4 @@
+-- Rows written before the chain existed keep a NULL seq and are not verified
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS seq BIGINT UNIQUE;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS audit_archives;
+
+ALTER TABLE audit_logs RENAME TO audit_logs_partitioned;
+
+CREATE TABLE audit_logs (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(255) NOT NULL,
+    target_resource VARCHAR(100) NOT NULL,
+    target_id UUID,
+    details JSONB,
+    created_at TIMESTAMP DEFAULT NOW(),
+    seq BIGINT UNIQUE,
+    prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+INSERT INTO audit_logs (id, user_id, action, target_resource, target_id, details, created_at, seq, prev_hash, hash)
+SELECT id, user_id, action, target_resource, target_id, details, created_at, seq, prev_hash, hash
+FROM audit_logs_partitioned;
+
+DROP TABLE audit_logs_partitioned;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at_id ON audit_logs (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_resource, target_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_ip_address ON audit_logs ((details->>'ip_address'));
@@ -0,0 +1,68 @@
+-- Recreate audit_logs as a table range-partitioned by month on created_at.
+-- The partition key has to be part of the primary key.
+UPDATE audit_logs SET created_at = NOW() WHERE created_at IS NULL;
+
+DROP INDEX IF EXISTS idx_audit_logs_created_at_id;
+DROP INDEX IF EXISTS idx_audit_logs_target;
+DROP INDEX IF EXISTS idx_audit_logs_user_id;
+DROP INDEX IF EXISTS idx_audit_logs_ip_address;
+ALTER TABLE audit_logs RENAME TO audit_logs_unpartitioned;
+
+CREATE TABLE audit_logs (
+    id UUID NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(255) NOT NULL,
+    target_resource VARCHAR(100) NOT NULL,
+    target_id UUID,
+    details JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    seq BIGINT,
+    prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT '',
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- One partition per month from the oldest row until three months ahead.
+-- Later months are created by the audit maintenance job.
+DO $$
+DECLARE
+    partition_start DATE := date_trunc('month', COALESCE((SELECT MIN(created_at) FROM audit_logs_unpartitioned), NOW()));
+    last_start DATE := date_trunc('month', NOW()) + INTERVAL '3 months';
+BEGIN
+    WHILE partition_start <= last_start LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF audit_logs FOR VALUES FROM (%L) TO (%L)',
+            'audit_logs_' || to_char(partition_start, '"y"YYYY"m"MM'),
+            partition_start,
+            partition_start + INTERVAL '1 month');
+        partition_start := partition_start + INTERVAL '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO audit_logs (id, user_id, action, target_resource, target_id, details, created_at, seq, prev_hash, hash)
+SELECT id, user_id, action, target_resource, target_id, details, created_at, seq, prev_hash, hash
+FROM audit_logs_unpartitioned;
+
+DROP TABLE audit_logs_unpartitioned;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at_id ON audit_logs (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_resource, target_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_ip_address ON audit_logs ((details->>'ip_address'));
+CREATE INDEX IF NOT EXISTS idx_audit_logs_seq ON audit_logs (seq);
+
+-- Archived partitions, including where the hash chain continues from
+CREATE TABLE IF NOT EXISTS audit_archives (
+    id UUID PRIMARY KEY,
+    partition_name VARCHAR(63) NOT NULL UNIQUE,
+    range_start TIMESTAMP NOT NULL,
+    range_end TIMESTAMP NOT NULL,
+    file_path TEXT NOT NULL,
+    file_sha256 VARCHAR(64) NOT NULL,
+    row_count BIGINT NOT NULL,
+    first_seq BIGINT,
+    last_seq BIGINT,
+    last_hash VARCHAR(64) NOT NULL DEFAULT '',
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_archives_last_seq ON audit_archives (last_seq);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS api_keys;
+
+DELETE FROM users WHERE service_account;
+ALTER TABLE users DROP COLUMN IF EXISTS service_account;
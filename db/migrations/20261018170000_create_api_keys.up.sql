@@ -0,0 +1,20 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS service_account BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    kind         VARCHAR(20) CHECK (kind IN ('personal', 'service')) NOT NULL,
+    prefix       VARCHAR(20) NOT NULL,
+    key_hash     VARCHAR(64) NOT NULL UNIQUE,
+    scopes       JSONB NOT NULL DEFAULT '[]',
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at   TIMESTAMP,
+    created_by   UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at   TIMESTAMP DEFAULT NOW(),
+    updated_at   TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
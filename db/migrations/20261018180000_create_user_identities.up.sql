@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id            UUID PRIMARY KEY,
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer        VARCHAR(255) NOT NULL,
+    subject       VARCHAR(255) NOT NULL,
+    email         VARCHAR(255) NOT NULL DEFAULT '',
+    last_login_at TIMESTAMP,
+    created_at    TIMESTAMP DEFAULT NOW(),
+    updated_at    TIMESTAMP DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
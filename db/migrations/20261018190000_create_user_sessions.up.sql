@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent   VARCHAR(512) NOT NULL DEFAULT '',
+    ip_address   VARCHAR(45) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP DEFAULT NOW(),
+    last_seen_at TIMESTAMP DEFAULT NOW(),
+    expires_at   TIMESTAMP NOT NULL,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
@@ -0,0 +1,4 @@
+UPDATE users SET role = 'user' WHERE role NOT IN ('admin', 'manager', 'user');
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'manager', 'user'));
+
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id          UUID PRIMARY KEY,
+    name        VARCHAR(20) NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    permissions JSONB NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMP DEFAULT NOW(),
+    updated_at  TIMESTAMP DEFAULT NOW()
+);
+
+-- Users can hold custom roles now, which the application validates
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
@@ -0,0 +1,2 @@
+-- Fails while audit rows reference deleted users
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
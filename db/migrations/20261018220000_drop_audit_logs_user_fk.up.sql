@@ -0,0 +1,13 @@
+-- Audit rows are hash chained over user_id, so deleting a user must not
+-- rewrite them through ON DELETE SET NULL. Rows keep the ID of a deleted user.
+DO $$
+DECLARE
+    constraint_name TEXT;
+BEGIN
+    FOR constraint_name IN
+        SELECT conname FROM pg_constraint
+        WHERE conrelid = 'audit_logs'::regclass AND contype = 'f' AND confrelid = 'users'::regclass
+    LOOP
+        EXECUTE format('ALTER TABLE audit_logs DROP CONSTRAINT %I', constraint_name);
+    END LOOP;
+END $$;
@@ -0,0 +1,11 @@
+-- The SIEM relay streams committed audit entries after this seq. It starts
+-- at the end of the chain, so existing entries are not sent again.
+CREATE TABLE IF NOT EXISTS audit_sink_cursors (
+    name VARCHAR(50) PRIMARY KEY,
+    last_seq BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO audit_sink_cursors (name, last_seq)
+SELECT 'siem', COALESCE(MAX(seq), 0) FROM audit_logs
+ON CONFLICT (name) DO NOTHING;
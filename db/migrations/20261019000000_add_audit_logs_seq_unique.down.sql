@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_audit_logs_seq ON audit_logs (seq);
+
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_seq_created_at_key;
@@ -0,0 +1,7 @@
+-- A unique constraint on a partitioned table must include the partition key,
+-- so seq alone cannot be unique. This rejects a row repeated with its
+-- original created_at; VerifyChain reports any other duplicate seq.
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_seq_created_at_key UNIQUE (seq, created_at);
+
+-- The constraint's index covers lookups by seq
+DROP INDEX IF EXISTS idx_audit_logs_seq;
@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyTableName = "api_keys"
+)
+
+// API key kind constants
+const (
+	APIKeyKindPersonal = "personal"
+	APIKeyKindService  = "service"
+)
+
+// API key scope constants. A scope grants read (GET) or write (any other
+// method) access to one route group.
+const (
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeProjectsRead  = "projects:read"
+	ScopeProjectsWrite = "projects:write"
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeAuditRead     = "audit:read"
+)
+
+// APIKeyScopes lists every scope an API key can be granted
+var APIKeyScopes = []string{
+	ScopeTasksRead,
+	ScopeTasksWrite,
+	ScopeProjectsRead,
+	ScopeProjectsWrite,
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopeAuditRead,
+}
+
+// APIKey is a personal access token or a service account key. Only the hash
+// of the key is stored; the prefix identifies it in listings.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	previous *APIKey `gorm:"-"`
+}
+
+func (*APIKey) TableName() string {
+	return apiKeyTableName
+}
+
+// Active reports whether the key is neither revoked nor expired
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HasScope reports whether the key was granted a scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AfterCreate hook - logs key creation
+func (k *APIKey) AfterCreate(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionCreate, apiKeyTableName, k.ID, k, nil)
+}
+
+// BeforeUpdate hook - keeps the stored state for the update diff
+func (k *APIKey) BeforeUpdate(tx *gorm.DB) error {
+	k.previous = loadPrevious[APIKey](tx, k.ID)
+	return nil
+}
+
+// AfterUpdate hook - logs key updates such as revocation. Last-used tracking
+// bypasses hooks and is not audited.
+func (k *APIKey) AfterUpdate(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionUpdate, apiKeyTableName, k.ID, k, k.previousState())
+}
+
+// previousState returns the state loaded before the update, or nil if unknown
+func (k *APIKey) previousState() interface{} {
+	if k.previous == nil {
+		return nil
+	}
+	return k.previous
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	auditArchiveTableName = "audit_archives"
+)
+
+// AuditArchive records an audit log partition that was exported and dropped.
+// LastSeq and LastHash let the hash chain be verified across the archived gap.
+type AuditArchive struct {
+	ID            uuid.UUID `json:"id"`
+	PartitionName string    `json:"partition_name"`
+	RangeStart    time.Time `json:"range_start"`
+	RangeEnd      time.Time `json:"range_end"`
+	FilePath      string    `json:"file_path"`
+	FileSha256    string    `json:"file_sha256"`
+	RowCount      int64     `json:"row_count"`
+	FirstSeq      *int64    `json:"first_seq"`
+	LastSeq       *int64    `json:"last_seq"`
+	LastHash      string    `json:"last_hash"`
+	ArchivedAt    time.Time `json:"archived_at"`
+}
+
+func (*AuditArchive) TableName() string {
+	return auditArchiveTableName
+}
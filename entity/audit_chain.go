@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLockKey is the advisory lock that serializes appends to the audit chain
+const auditChainLockKey = 0x617564697463 // "auditc"
+
+// auditChainTimeLayout matches the microsecond precision of the created_at column
+const auditChainTimeLayout = "2006-01-02T15:04:05.000000"
+
+// canonicalAuditLog is the hashed representation of an audit log row. Field
+// order is fixed by the struct and details are re-encoded with sorted keys,
+// so the hash does not depend on how PostgreSQL stores the JSONB.
+type canonicalAuditLog struct {
+	Seq            int64       `json:"seq"`
+	ID             string      `json:"id"`
+	UserID         *string     `json:"user_id"`
+	Action         string      `json:"action"`
+	TargetResource string      `json:"target_resource"`
+	TargetID       *string     `json:"target_id"`
+	Details        interface{} `json:"details"`
+	CreatedAt      string      `json:"created_at"`
+	PrevHash       string      `json:"prev_hash"`
+}
+
+// ComputeAuditHash returns the chain hash of an audit log row, which covers
+// the row content and the hash of the previous row
+func ComputeAuditHash(log *AuditLog) (string, error) {
+	canonical := canonicalAuditLog{
+		ID:             log.ID.String(),
+		Action:         log.Action,
+		TargetResource: log.TargetResource,
+		CreatedAt:      log.CreatedAt.Format(auditChainTimeLayout),
+		PrevHash:       log.PrevHash,
+	}
+	if log.Seq != nil {
+		canonical.Seq = *log.Seq
+	}
+	if log.UserID != nil {
+		userID := log.UserID.String()
+		canonical.UserID = &userID
+	}
+	if log.TargetID != nil {
+		targetID := log.TargetID.String()
+		canonical.TargetID = &targetID
+	}
+	if len(log.Details) > 0 {
+		if err := json.Unmarshal(log.Details, &canonical.Details); err != nil {
+			return "", err
+		}
+	}
+
+	raw, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// appendAuditLog links the row to the end of the chain and inserts it. The
+// advisory lock is held until the surrounding transaction ends, so concurrent
+// writers append one after another and never fork the chain.
+func appendAuditLog(tx *gorm.DB, log *AuditLog) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+			return err
+		}
+
+		var last AuditLog
+		result := tx.Where("seq IS NOT NULL").Order("seq DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		seq := int64(1)
+		if result.RowsAffected > 0 {
+			seq = *last.Seq + 1
+			log.PrevHash = last.Hash
+		}
+		log.Seq = &seq
+
+		// Stored without time zone at microsecond precision, hash what is stored
+		log.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+		hash, err := ComputeAuditHash(log)
+		if err != nil {
+			return err
+		}
+		log.Hash = hash
+
+		return tx.Create(log).Error
+	})
+}
@@ -2,6 +2,7 @@ package entity
 
 import (
 	"encoding/json"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +21,9 @@ type AuditLog struct {
 	TargetID       *uuid.UUID      `json:"target_id"`
 	Details        json.RawMessage `json:"details"`
 	CreatedAt      time.Time       `json:"created_at"`
+	Seq            *int64          `json:"seq"`
+	PrevHash       string          `json:"prev_hash"`
+	Hash           string          `json:"hash"`
 }
 
 func (*AuditLog) TableName() string {
@@ -31,6 +35,10 @@ const (
 	AuditActionCreate = "CREATE"
 	AuditActionUpdate = "UPDATE"
 	AuditActionDelete = "DELETE"
+
+	AuditActionAuthFailure  = "AUTH_FAILURE"
+	AuditActionAccessDenied = "ACCESS_DENIED"
+	AuditActionRead         = "READ"
 )
 
 // Context keys for audit information
@@ -40,6 +48,8 @@ const (
 	AuditUserIDKey contextKey = "audit_user_id"
 	AuditIPKey     contextKey = "audit_ip"
 	AuditUserAgent contextKey = "audit_user_agent"
+
+	AuditRequestIDKey contextKey = "audit_request_id"
 )
 
 // CreateAuditLog creates an audit log entry within the same transaction
@@ -71,14 +81,30 @@ func CreateAuditLog(tx *gorm.DB, action, resource string, targetID uuid.UUID, da
 				details["user_agent"] = uaStr
 			}
 		}
-	}
 
-	// Add data changes
-	if data != nil {
-		details["new"] = sanitizeForAudit(data)
+		// Get request ID
+		if rid := ctx.Value(AuditRequestIDKey); rid != nil {
+			if ridStr, ok := rid.(string); ok && ridStr != "" {
+				details["request_id"] = ridStr
+			}
+		}
 	}
-	if oldData != nil {
-		details["old"] = sanitizeForAudit(oldData)
+
+	// Add data changes. Updates with a known previous state only record the
+	// fields that changed, and are skipped entirely when nothing did.
+	if action == AuditActionUpdate && data != nil && oldData != nil {
+		changes := auditDiff(oldData, data)
+		if len(changes) == 0 {
+			return nil
+		}
+		details["changes"] = changes
+	} else {
+		if data != nil {
+			details["new"] = sanitizeForAudit(data)
+		}
+		if oldData != nil {
+			details["old"] = sanitizeForAudit(oldData)
+		}
 	}
 
 	// Add timestamp
@@ -100,10 +126,69 @@ func CreateAuditLog(tx *gorm.DB, action, resource string, targetID uuid.UUID, da
 		TargetResource: resource,
 		TargetID:       &targetID,
 		Details:        detailsJSON,
-		CreatedAt:      time.Now(),
 	}
 
-	return tx.Create(auditLog).Error
+	return appendAuditLog(tx, auditLog)
+}
+
+// auditDiffIgnoredFields are bookkeeping fields that change on every update
+var auditDiffIgnoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// auditDiff returns {"field": {"old": .., "new": ..}} for every sanitized field
+// whose value differs between the two states
+func auditDiff(oldData, newData interface{}) map[string]interface{} {
+	oldFields := auditFields(oldData)
+	newFields := auditFields(newData)
+
+	changes := make(map[string]interface{})
+	for field, newValue := range newFields {
+		if auditDiffIgnoredFields[field] {
+			continue
+		}
+		oldValue := oldFields[field]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = map[string]interface{}{
+				"old": oldValue,
+				"new": newValue,
+			}
+		}
+	}
+
+	// Secrets are never logged, only the fact that they changed
+	if oldUser, ok := oldData.(*User); ok {
+		if newUser, ok := newData.(*User); ok && oldUser.PasswordHash != newUser.PasswordHash {
+			changes["password"] = map[string]interface{}{
+				"changed": true,
+			}
+		}
+	}
+
+	return changes
+}
+
+// auditFields flattens sanitized data into its JSON field values so states of
+// any entity can be compared field by field
+func auditFields(data interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	raw, err := json.Marshal(sanitizeForAudit(data))
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// loadPrevious loads the stored state of a row before it is updated, so the
+// update can be audited as a diff. It returns nil if the row cannot be read.
+func loadPrevious[T any](tx *gorm.DB, id uuid.UUID) *T {
+	var previous T
+	if err := tx.Session(&gorm.Session{NewDB: true}).Where("id = ?", id).First(&previous).Error; err != nil {
+		return nil
+	}
+	return &previous
 }
 
 // sanitizeForAudit removes sensitive data before logging
@@ -111,12 +196,15 @@ func sanitizeForAudit(data interface{}) interface{} {
 	// if data is user exclude PasswordHash
 	if user, ok := data.(*User); ok {
 		return map[string]interface{}{
-			"id":         user.ID,
-			"name":       user.Name,
-			"email":      user.Email,
-			"role":       user.Role,
-			"created_at": user.CreatedAt,
-			"updated_at": user.UpdatedAt,
+			"id":              user.ID,
+			"name":            user.Name,
+			"email":           user.Email,
+			"role":            user.Role,
+			"digest_cadence":  user.DigestCadence,
+			"language":        user.Language,
+			"service_account": user.ServiceAccount,
+			"created_at":      user.CreatedAt,
+			"updated_at":      user.UpdatedAt,
 		}
 	}
 	return data
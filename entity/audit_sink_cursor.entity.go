@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+const (
+	auditSinkCursorTableName = "audit_sink_cursors"
+)
+
+// AuditSinkCursorSIEM is the cursor of the SIEM sink relay
+const AuditSinkCursorSIEM = "siem"
+
+// AuditSinkCursor records the last chained audit entry handed to a sink.
+// Entries are relayed in seq order, so everything up to LastSeq was sent.
+type AuditSinkCursor struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	LastSeq   int64     `json:"last_seq"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (*AuditSinkCursor) TableName() string {
+	return auditSinkCursorTableName
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	notificationPreferenceTableName = "notification_preferences"
+)
+
+// Notification event type constants
+const (
+	NotificationEventAssigned  = "assigned"
+	NotificationEventMentioned = "mentioned"
+	NotificationEventComment   = "comment"
+	NotificationEventDueSoon   = "due_soon"
+	NotificationEventDigest    = "digest"
+)
+
+// Notification channel constants
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelInApp   = "in_app"
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelNone    = "none"
+)
+
+// NotificationEvents lists every event type a user can set a preference for
+var NotificationEvents = []string{
+	NotificationEventAssigned,
+	NotificationEventMentioned,
+	NotificationEventComment,
+	NotificationEventDueSoon,
+	NotificationEventDigest,
+}
+
+// DefaultNotificationChannel is used for events without a stored preference
+const DefaultNotificationChannel = NotificationChannelEmail
+
+// NotificationPreference stores the delivery channel a user chose for an event type
+type NotificationPreference struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"primaryKey"`
+	EventType string    `json:"event_type" gorm:"primaryKey"`
+	Channel   string    `json:"channel"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (*NotificationPreference) TableName() string {
+	return notificationPreferenceTableName
+}
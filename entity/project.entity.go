@@ -16,8 +16,11 @@ type Project struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	CreatedBy   *uuid.UUID `json:"created_by"`
+	Version     int        `json:"version" gorm:"default:1"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+
+	previous *Project `gorm:"-"`
 }
 
 func (*Project) TableName() string {
@@ -28,8 +31,21 @@ func (p *Project) AfterCreate(tx *gorm.DB) error {
 	return CreateAuditLog(tx, AuditActionCreate, projectTableName, p.ID, p, nil)
 }
 
+func (p *Project) BeforeUpdate(tx *gorm.DB) error {
+	p.previous = loadPrevious[Project](tx, p.ID)
+	return nil
+}
+
 func (p *Project) AfterUpdate(tx *gorm.DB) error {
-	return CreateAuditLog(tx, AuditActionUpdate, projectTableName, p.ID, p, nil)
+	return CreateAuditLog(tx, AuditActionUpdate, projectTableName, p.ID, p, p.previousState())
+}
+
+// previousState returns the state loaded before the update, or nil if unknown
+func (p *Project) previousState() interface{} {
+	if p.previous == nil {
+		return nil
+	}
+	return p.previous
 }
 
 func (p *Project) AfterDelete(tx *gorm.DB) error {
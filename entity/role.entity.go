@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	roleTableName = "roles"
+)
+
+// Role is a custom role created by an admin. Users refer to it by name; the
+// built-in roles are not stored.
+type Role struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions" gorm:"serializer:json"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	previous *Role `gorm:"-"`
+}
+
+func (*Role) TableName() string {
+	return roleTableName
+}
+
+// AfterCreate hook - logs role creation
+func (r *Role) AfterCreate(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionCreate, roleTableName, r.ID, r, nil)
+}
+
+// BeforeUpdate hook - keeps the stored state for the update diff
+func (r *Role) BeforeUpdate(tx *gorm.DB) error {
+	r.previous = loadPrevious[Role](tx, r.ID)
+	return nil
+}
+
+// AfterUpdate hook - logs permission changes
+func (r *Role) AfterUpdate(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionUpdate, roleTableName, r.ID, r, r.previousState())
+}
+
+// previousState returns the state loaded before the update, or nil if unknown
+func (r *Role) previousState() interface{} {
+	if r.previous == nil {
+		return nil
+	}
+	return r.previous
+}
+
+// AfterDelete hook - logs role deletion
+func (r *Role) AfterDelete(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionDelete, roleTableName, r.ID, nil, r)
+}
@@ -21,8 +21,11 @@ type Task struct {
 	ProjectID   *uuid.UUID `json:"project_id"`
 	CreatedBy   *uuid.UUID `json:"created_by"`
 	AssignedTo  *uuid.UUID `json:"assigned_to"`
+	Version     int        `json:"version" gorm:"default:1"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+
+	previous *Task `gorm:"-"`
 }
 
 func (*Task) TableName() string {
@@ -34,9 +37,23 @@ func (t *Task) AfterCreate(tx *gorm.DB) error {
 	return CreateAuditLog(tx, AuditActionCreate, taskTableName, t.ID, t, nil)
 }
 
+// BeforeUpdate hook - keeps the stored state for the update diff
+func (t *Task) BeforeUpdate(tx *gorm.DB) error {
+	t.previous = loadPrevious[Task](tx, t.ID)
+	return nil
+}
+
 // AfterUpdate hook - logs task updates
 func (t *Task) AfterUpdate(tx *gorm.DB) error {
-	return CreateAuditLog(tx, AuditActionUpdate, taskTableName, t.ID, t, nil)
+	return CreateAuditLog(tx, AuditActionUpdate, taskTableName, t.ID, t, t.previousState())
+}
+
+// previousState returns the state loaded before the update, or nil if unknown
+func (t *Task) previousState() interface{} {
+	if t.previous == nil {
+		return nil
+	}
+	return t.previous
 }
 
 // AfterDelete hook - logs task deletion
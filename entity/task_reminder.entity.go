@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	taskReminderTableName = "task_reminders"
+)
+
+// Reminder threshold constants
+const (
+	ReminderThresholdDueSoon = "due_soon"
+	ReminderThresholdOverdue = "overdue"
+)
+
+// TaskReminder records a reminder that has been sent for a task, so each
+// threshold only fires once per task and due date
+type TaskReminder struct {
+	ID         uuid.UUID `json:"id"`
+	TaskID     uuid.UUID `json:"task_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Threshold  string    `json:"threshold"`
+	DueDate    time.Time `json:"due_date"`
+	NotifiedAt time.Time `json:"notified_at"`
+}
+
+func (*TaskReminder) TableName() string {
+	return taskReminderTableName
+}
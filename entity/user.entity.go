@@ -12,25 +12,67 @@ const (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"password_hash"`
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	PasswordHash   string    `json:"password_hash"`
+	Role           string    `json:"role"`
+	DigestCadence  string    `json:"digest_cadence"`
+	Language       string    `json:"language"`
+	ServiceAccount bool      `json:"service_account"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	previous *User `gorm:"-"`
 }
 
+// Digest cadence constants
+const (
+	DigestCadenceNone   = "none"
+	DigestCadenceDaily  = "daily"
+	DigestCadenceWeekly = "weekly"
+)
+
+// Language constants for localized emails
+const (
+	LanguageEnglish    = "en"
+	LanguageIndonesian = "id"
+	DefaultLanguage    = LanguageEnglish
+)
+
 func (*User) TableName() string {
 	return userTableName
 }
 
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.DigestCadence == "" {
+		u.DigestCadence = DigestCadenceNone
+	}
+	if u.Language == "" {
+		u.Language = DefaultLanguage
+	}
+	return nil
+}
+
 func (u *User) AfterCreate(tx *gorm.DB) error {
 	return CreateAuditLog(tx, AuditActionCreate, userTableName, u.ID, u, nil)
 }
 
+func (u *User) BeforeUpdate(tx *gorm.DB) error {
+	u.previous = loadPrevious[User](tx, u.ID)
+	return nil
+}
+
 func (u *User) AfterUpdate(tx *gorm.DB) error {
-	return CreateAuditLog(tx, AuditActionUpdate, userTableName, u.ID, u, nil)
+	return CreateAuditLog(tx, AuditActionUpdate, userTableName, u.ID, u, u.previousState())
+}
+
+// previousState returns the state loaded before the update, or nil if unknown
+func (u *User) previousState() interface{} {
+	if u.previous == nil {
+		return nil
+	}
+	return u.previous
 }
 
 func (u *User) AfterDelete(tx *gorm.DB) error {
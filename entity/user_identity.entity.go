@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	userIdentityTableName = "user_identities"
+)
+
+// UserIdentity links a user to their account at an external OpenID Connect
+// provider, identified by the issuer and the subject claim
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (*UserIdentity) TableName() string {
+	return userIdentityTableName
+}
+
+// AfterCreate hook - logs account linking
+func (i *UserIdentity) AfterCreate(tx *gorm.DB) error {
+	return CreateAuditLog(tx, AuditActionCreate, userIdentityTableName, i.ID, i, nil)
+}
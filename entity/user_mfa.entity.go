@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	userMFATableName         = "user_mfa"
+	mfaRecoveryCodeTableName = "mfa_recovery_codes"
+)
+
+// UserMFA stores the TOTP enrollment of a user. The secret is encrypted at
+// rest and the enrollment only counts once EnabledAt is set by confirming a
+// first code.
+type UserMFA struct {
+	UserID       uuid.UUID  `json:"user_id" gorm:"primaryKey"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (*UserMFA) TableName() string {
+	return userMFATableName
+}
+
+// Enabled reports whether the enrollment was confirmed
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
+
+// MFARecoveryCode is a hashed single-use code that replaces a TOTP code
+type MFARecoveryCode struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (*MFARecoveryCode) TableName() string {
+	return mfaRecoveryCodeTableName
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	userSessionTableName = "user_sessions"
+)
+
+// UserSession is a login of a user. Access tokens carry the session ID and
+// stop working once the session is revoked.
+type UserSession struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func (*UserSession) TableName() string {
+	return userSessionTableName
+}
+
+// Active reports whether the session can still be used
+func (s *UserSession) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
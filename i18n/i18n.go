@@ -0,0 +1,123 @@
+// Package i18n holds the message catalogs of the API and the email templates
+// and picks the language of a request.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mnizarzr/dot-test/entity"
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Default is the language used when a request asks for none we support
+const Default = entity.DefaultLanguage
+
+// Catalog sections. Errors are keyed by their problem code and validation
+// messages by validation rule. Success messages are keyed by their English
+// text, so the English catalog does not list them.
+const (
+	sectionErrors     = "errors"
+	sectionValidation = "validation"
+	sectionMessages   = "messages"
+	sectionEmail      = "email"
+)
+
+// catalog holds the translations of one language by section and key
+type catalog map[string]map[string]string
+
+var catalogs = mustLoadCatalogs()
+
+// mustLoadCatalogs parses the embedded catalogs, one file per language
+func mustLoadCatalogs() map[string]catalog {
+	names, err := files.ReadDir("locales")
+	if err != nil {
+		// The directory is embedded at build time, so this cannot happen
+		panic(err)
+	}
+
+	loaded := make(map[string]catalog, len(names))
+	for _, name := range names {
+		data, err := files.ReadFile(path.Join("locales", name.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("invalid message catalog %s: %v", name.Name(), err))
+		}
+		loaded[strings.TrimSuffix(name.Name(), ".json")] = c
+	}
+
+	if _, ok := loaded[Default]; !ok {
+		panic(fmt.Sprintf("message catalog for default language %q not found", Default))
+	}
+	return loaded
+}
+
+// Supported reports whether there is a catalog for a language
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Languages returns the languages with a catalog
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Error translates the message of a problem code, or returns fallback
+func Error(lang, code, fallback string) string {
+	return lookup(lang, sectionErrors, code, fallback)
+}
+
+// Validation translates the message of a validation rule, or returns fallback
+func Validation(lang, rule, fallback string) string {
+	return lookup(lang, sectionValidation, rule, fallback)
+}
+
+// Message translates an English success message, or returns it unchanged
+func Message(lang, message string) string {
+	return lookup(lang, sectionMessages, message, message)
+}
+
+// Email translates a label of the email templates, or returns the key
+func Email(lang, key string) string {
+	return lookup(lang, sectionEmail, key, key)
+}
+
+// lookup finds a key in a section of the language, then of the default
+// language, and returns fallback when neither has it
+func lookup(lang, section, key, fallback string) string {
+	for _, l := range []string{lang, Default} {
+		if text, ok := catalogs[l][section][key]; ok {
+			return text
+		}
+	}
+	return fallback
+}
+
+// Format replaces the {name} placeholders of a message with their values
+func Format(message string, params map[string]string) string {
+	if len(params) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(message)
+}
@@ -0,0 +1,70 @@
+package i18n
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type resolverKey struct{}
+
+// Resolver returns the language of a request. It is called lazily so the
+// language can depend on the authenticated user, who is only known once the
+// auth middleware has run.
+type Resolver func(ctx context.Context) string
+
+// WithResolver returns a context whose language is picked by resolve
+func WithResolver(ctx context.Context, resolve Resolver) context.Context {
+	return context.WithValue(ctx, resolverKey{}, resolve)
+}
+
+// Language returns the language of a request context, or Default when the
+// language middleware did not run
+func Language(ctx context.Context) string {
+	if resolve, ok := ctx.Value(resolverKey{}).(Resolver); ok {
+		if lang := resolve(ctx); Supported(lang) {
+			return lang
+		}
+	}
+	return Default
+}
+
+// Negotiate picks the supported language a client prefers from an
+// Accept-Language header. Region subtags are ignored, so id-ID selects id.
+// It returns Default when the header names no supported language.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang    string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !Supported(lang) {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			quality = parsed
+		}
+		candidates = append(candidates, candidate{lang: lang, quality: quality})
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+
+	// Stable, so the header order breaks ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang
+}
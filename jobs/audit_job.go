@@ -0,0 +1,250 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/audit"
+	"github.com/mnizarzr/dot-test/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job type constants
+const (
+	TypeAuditMaintenance = "audit:maintenance"
+	TypeAuditEvent       = "audit:event"
+	TypeAuditEventBatch  = "audit:event_batch"
+)
+
+// AuditEventGroup is the asynq group audit events are aggregated in before
+// being shipped to the sink as one batch
+const AuditEventGroup = "audit-events"
+
+// auditRelayInterval is how often the relay looks for new audit entries
+const auditRelayInterval = 2 * time.Second
+
+// auditRelayBatchSize caps the entries relayed in one transaction
+const auditRelayBatchSize = 500
+
+// AuditPartitionsAhead is the number of future monthly partitions kept ready
+const AuditPartitionsAhead = 3
+
+// AuditJobHandler keeps the audit log partitions ready and archives expired ones
+type AuditJobHandler struct {
+	config       *config.Config
+	auditService audit.Service
+}
+
+// NewAuditJobHandler creates a new audit job handler
+func NewAuditJobHandler(cfg *config.Config, auditService audit.Service) *AuditJobHandler {
+	return &AuditJobHandler{
+		config:       cfg,
+		auditService: auditService,
+	}
+}
+
+// NewAuditMaintenanceTask creates a new audit maintenance task
+func NewAuditMaintenanceTask() *asynq.Task {
+	return asynq.NewTask(TypeAuditMaintenance, nil)
+}
+
+// HandleAuditMaintenance creates upcoming partitions and archives partitions past the retention period
+func (h *AuditJobHandler) HandleAuditMaintenance(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+
+	if err := h.auditService.EnsurePartitions(ctx, now, AuditPartitionsAhead); err != nil {
+		return fmt.Errorf("failed to create audit partitions: %w", err)
+	}
+
+	archives, err := h.auditService.ArchivePartitions(ctx, now, h.config.AuditRetentionMonths, h.config.AuditArchiveDir, false)
+	for _, archive := range archives {
+		log.Printf("Archived audit partition %s (%d rows) to %s", archive.PartitionName, archive.RowCount, archive.FilePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive audit partitions: %w", err)
+	}
+
+	return nil
+}
+
+// NewAuditEventTask creates a task carrying one written audit log entry
+func NewAuditEventTask(auditLog *entity.AuditLog) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(auditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TypeAuditEvent, payloadBytes), nil
+}
+
+// AuditEventRelay hands committed audit entries to the sink queue. Entries
+// are read back from audit_logs in chain order after the cursor, so rolled
+// back writes are never sent, writes from every process are covered, and
+// requests never wait on the queue.
+type AuditEventRelay struct {
+	db     *gorm.DB
+	client *asynq.Client
+}
+
+// NewAuditEventRelay creates a new audit event relay
+func NewAuditEventRelay(db *gorm.DB, client *asynq.Client) *AuditEventRelay {
+	return &AuditEventRelay{
+		db:     db,
+		client: client,
+	}
+}
+
+// Run relays new entries every few seconds until ctx is cancelled
+func (r *AuditEventRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(auditRelayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep going while full batches come back, so a backlog drains quickly
+		for {
+			relayed, err := r.relay(ctx)
+			if err != nil {
+				log.Printf("Failed to relay audit events: %v", err)
+				break
+			}
+			if relayed < auditRelayBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// relay enqueues the entries after the cursor and moves the cursor past the
+// ones enqueued. The cursor row stays locked meanwhile, so only one worker
+// relays at a time. Entries are committed in seq order because appending
+// holds the chain lock until commit, so none can appear behind the cursor.
+func (r *AuditEventRelay) relay(ctx context.Context) (int, error) {
+	relayed := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cursor entity.AuditSinkCursor
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("name = ?", entity.AuditSinkCursorSIEM).
+			Limit(1).
+			Find(&cursor)
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Another worker holds the cursor
+			return result.Error
+		}
+
+		var auditLogs []*entity.AuditLog
+		if err := tx.Where("seq > ?", cursor.LastSeq).Order("seq ASC").Limit(auditRelayBatchSize).Find(&auditLogs).Error; err != nil {
+			return err
+		}
+
+		lastSeq := cursor.LastSeq
+		var enqueueErr error
+		for _, auditLog := range auditLogs {
+			if enqueueErr = r.enqueue(ctx, auditLog); enqueueErr != nil {
+				break
+			}
+			lastSeq = *auditLog.Seq
+			relayed++
+		}
+
+		if lastSeq != cursor.LastSeq {
+			if err := tx.Model(&cursor).Updates(map[string]interface{}{"last_seq": lastSeq, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return enqueueErr
+	})
+	return relayed, err
+}
+
+// enqueue queues one entry for the sink. The task ID is the entry ID, so an
+// entry relayed again after a failed cursor update is not queued twice.
+func (r *AuditEventRelay) enqueue(ctx context.Context, auditLog *entity.AuditLog) error {
+	task, err := NewAuditEventTask(auditLog)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.EnqueueContext(ctx, task, asynq.Queue("low"), asynq.Group(AuditEventGroup), asynq.TaskID(auditLog.ID.String()))
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// AggregateAuditEvents joins grouped audit event tasks into one batch task
+// whose payload is a JSON array of the events
+func AggregateAuditEvents(group string, tasks []*asynq.Task) *asynq.Task {
+	events := make([]json.RawMessage, 0, len(tasks))
+	for _, task := range tasks {
+		events = append(events, task.Payload())
+	}
+
+	payloadBytes, err := json.Marshal(events)
+	if err != nil {
+		// Payloads come from NewAuditEventTask, so only a corrupted task ends up here
+		log.Printf("Failed to aggregate %d audit events in group %s: %v", len(tasks), group, err)
+		payloadBytes = []byte("[]")
+	}
+
+	return asynq.NewTask(TypeAuditEventBatch, payloadBytes)
+}
+
+// AuditSinkJobHandler ships audit events to the configured sink
+type AuditSinkJobHandler struct {
+	sink utils.AuditSink
+}
+
+// NewAuditSinkJobHandler creates a new audit sink job handler. A nil sink
+// drops events, which happens when AUDIT_SINK is disabled on the worker.
+func NewAuditSinkJobHandler(sink utils.AuditSink) *AuditSinkJobHandler {
+	return &AuditSinkJobHandler{
+		sink: sink,
+	}
+}
+
+// HandleAuditEvent ships a single event that was not aggregated into a batch
+func (h *AuditSinkJobHandler) HandleAuditEvent(ctx context.Context, t *asynq.Task) error {
+	return h.send([]json.RawMessage{t.Payload()})
+}
+
+// HandleAuditEventBatch ships an aggregated batch of events
+func (h *AuditSinkJobHandler) HandleAuditEventBatch(ctx context.Context, t *asynq.Task) error {
+	var events []json.RawMessage
+	if err := json.Unmarshal(t.Payload(), &events); err != nil {
+		return fmt.Errorf("failed to unmarshal audit event batch: %w", err)
+	}
+
+	return h.send(events)
+}
+
+// send returns the sink error so asynq retries the batch with backoff
+func (h *AuditSinkJobHandler) send(events []json.RawMessage) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if h.sink == nil {
+		log.Printf("Audit sink disabled, dropping %d audit events", len(events))
+		return nil
+	}
+
+	if err := h.sink.Send(events); err != nil {
+		return fmt.Errorf("failed to ship %d audit events: %w", len(events), err)
+	}
+
+	return nil
+}
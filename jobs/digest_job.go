@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+	"github.com/mnizarzr/dot-test/utils"
+	"gorm.io/gorm"
+)
+
+// Job type constants
+const (
+	TypeDigestScan  = "digest:scan"
+	TypeEmailDigest = "email:digest"
+)
+
+// digestListLimit caps the number of rows in each digest section
+const digestListLimit = 20
+
+// DigestEmailPayload represents the payload for digest email job
+type DigestEmailPayload struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Cadence string    `json:"cadence"`
+}
+
+// digestTaskRow is a task joined with its project name
+type digestTaskRow struct {
+	Title       string
+	ProjectName string
+	Status      string
+	Priority    string
+	DueDate     *time.Time
+}
+
+// DigestJobHandler collects per-user task summaries and sends digest emails
+type DigestJobHandler struct {
+	db           *gorm.DB
+	client       *asynq.Client
+	emailService *utils.EmailService
+	permissions  *policy.Engine
+	weeklyDay    time.Weekday
+}
+
+// NewDigestJobHandler creates a new digest job handler
+func NewDigestJobHandler(cfg *config.Config, emailService *utils.EmailService, db *gorm.DB, client *asynq.Client, permissions *policy.Engine) *DigestJobHandler {
+	return &DigestJobHandler{
+		db:           db,
+		client:       client,
+		emailService: emailService,
+		permissions:  permissions,
+		weeklyDay:    parseWeekday(cfg.DigestWeeklyDay),
+	}
+}
+
+// NewDigestScanTask creates a new digest scan task
+func NewDigestScanTask() *asynq.Task {
+	return asynq.NewTask(TypeDigestScan, nil)
+}
+
+// NewDigestEmailTask creates a new digest email task for a user
+func NewDigestEmailTask(userID uuid.UUID, cadence string) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(DigestEmailPayload{
+		UserID:  userID,
+		Cadence: cadence,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TypeEmailDigest, payloadBytes), nil
+}
+
+// HandleDigestScan enqueues a digest email for every user whose cadence is due today
+func (h *DigestJobHandler) HandleDigestScan(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+
+	cadences := []string{entity.DigestCadenceDaily}
+	if now.Weekday() == h.weeklyDay {
+		cadences = append(cadences, entity.DigestCadenceWeekly)
+	}
+
+	var users []entity.User
+	if err := h.db.WithContext(ctx).Where("digest_cadence IN ?", cadences).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to find digest recipients: %w", err)
+	}
+
+	enqueued := 0
+	for _, u := range users {
+		task, err := NewDigestEmailTask(u.ID, u.DigestCadence)
+		if err != nil {
+			log.Printf("Failed to create digest task for user %s: %v", u.ID, err)
+			continue
+		}
+
+		// The task ID makes a repeated scan on the same day a no-op
+		taskID := fmt.Sprintf("digest:%s:%s", u.ID, now.Format("2006-01-02"))
+		_, err = h.client.Enqueue(task, asynq.Queue("low"), asynq.MaxRetry(3), asynq.TaskID(taskID), asynq.Retention(24*time.Hour))
+		if err != nil {
+			if !errors.Is(err, asynq.ErrTaskIDConflict) {
+				log.Printf("Failed to enqueue digest for user %s: %v", u.ID, err)
+			}
+			continue
+		}
+		enqueued++
+	}
+
+	if enqueued > 0 {
+		log.Printf("Enqueued %d digest emails", enqueued)
+	}
+	return nil
+}
+
+// HandleDigestEmail builds and sends the digest for a single user
+func (h *DigestJobHandler) HandleDigestEmail(ctx context.Context, t *asynq.Task) error {
+	var payload DigestEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal digest payload: %w", err)
+	}
+
+	ok, err := wantsEmail(ctx, h.db, payload.UserID, entity.NotificationEventDigest)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preference: %w", err)
+	}
+	if !ok {
+		log.Printf("Skipping digest for user %s: disabled by preference", payload.UserID)
+		return nil
+	}
+
+	var user entity.User
+	if err := h.db.WithContext(ctx).Where("id = ?", payload.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to load digest recipient %s: %w", payload.UserID, err)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	window := 24 * time.Hour
+	if payload.Cadence == entity.DigestCadenceWeekly {
+		window = 7 * 24 * time.Hour
+	}
+
+	data := utils.DigestEmailData{
+		Name:           user.Name,
+		Period:         payload.Cadence,
+		UnsubscribeURL: h.emailService.UnsubscribeURL(user.ID, entity.NotificationEventDigest),
+	}
+
+	if data.AssignedTasks, err = h.findTasks(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to load assigned tasks: %w", err)
+	}
+	if data.DueSoonTasks, err = h.findTasks(ctx, user.ID, "t.due_date >= ? AND t.due_date <= ?", today, today.Add(window)); err != nil {
+		return fmt.Errorf("failed to load tasks due soon: %w", err)
+	}
+	if data.OverdueTasks, err = h.findTasks(ctx, user.ID, "t.due_date < ?", today); err != nil {
+		return fmt.Errorf("failed to load overdue tasks: %w", err)
+	}
+	if h.permissions.Can(ctx, user.Role, policy.ProjectReport) {
+		if data.Projects, err = h.findProjectProgress(ctx); err != nil {
+			return fmt.Errorf("failed to load project progress: %w", err)
+		}
+	}
+
+	if len(data.AssignedTasks) == 0 && len(data.Projects) == 0 {
+		log.Printf("Skipping empty %s digest for %s", payload.Cadence, user.Email)
+		return nil
+	}
+
+	if err := h.emailService.SendDigestEmail(user.Email, user.Language, data); err != nil {
+		return fmt.Errorf("failed to send digest to %s: %w", user.Email, err)
+	}
+
+	log.Printf("Digest email sent successfully to %s", user.Email)
+	return nil
+}
+
+// findTasks returns open tasks assigned to the user, optionally narrowed by a due date condition
+func (h *DigestJobHandler) findTasks(ctx context.Context, userID uuid.UUID, conditions ...interface{}) ([]utils.DigestTask, error) {
+	var rows []digestTaskRow
+
+	query := h.db.WithContext(ctx).
+		Table("tasks AS t").
+		Select("t.title, COALESCE(p.name, '') AS project_name, t.status, t.priority, t.due_date").
+		Joins("LEFT JOIN projects p ON p.id = t.project_id").
+		Where("t.assigned_to = ?", userID).
+		Where("t.status <> ?", "completed")
+	if len(conditions) > 0 {
+		query = query.Where(conditions[0], conditions[1:]...)
+	}
+
+	err := query.Order("t.due_date ASC NULLS LAST").Limit(digestListLimit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]utils.DigestTask, len(rows))
+	for i, row := range rows {
+		tasks[i] = utils.DigestTask{
+			Title:       row.Title,
+			ProjectName: row.ProjectName,
+			Status:      row.Status,
+			Priority:    row.Priority,
+			DueDate:     row.DueDate,
+		}
+	}
+	return tasks, nil
+}
+
+// findProjectProgress returns task completion counts per project
+func (h *DigestJobHandler) findProjectProgress(ctx context.Context) ([]utils.DigestProject, error) {
+	var projects []utils.DigestProject
+
+	err := h.db.WithContext(ctx).
+		Table("projects AS p").
+		Select("p.name, COUNT(t.id) AS total, COUNT(t.id) FILTER (WHERE t.status = 'completed') AS completed").
+		Joins("LEFT JOIN tasks t ON t.project_id = p.id").
+		Group("p.id, p.name").
+		Order("p.name ASC").
+		Limit(digestListLimit).
+		Scan(&projects).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range projects {
+		if projects[i].Total > 0 {
+			projects[i].Percent = projects[i].Completed * 100 / projects[i].Total
+		}
+	}
+	return projects, nil
+}
+
+// parseWeekday parses a weekday name, defaulting to Monday
+func parseWeekday(name string) time.Weekday {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d
+		}
+	}
+	return time.Monday
+}
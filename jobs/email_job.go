@@ -3,46 +3,84 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
-	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
 	"github.com/mnizarzr/dot-test/utils"
+	"gorm.io/gorm"
 )
 
 // Job type constants
 const (
-	TypeEmailWelcome = "email:welcome"
+	TypeEmailWelcome       = "email:welcome"
+	TypeEmailTaskAssigned  = "email:task_assigned"
+	TypeEmailTaskDueSoon   = "email:task_due_soon"
+	TypeEmailTaskOverdue   = "email:task_overdue"
+	TypeEmailAccountLocked = "email:account_locked"
 )
 
 // WelcomeEmailPayload represents the payload for welcome email job
 type WelcomeEmailPayload struct {
-	UserEmail string `json:"user_email"`
-	UserName  string `json:"user_name"`
-	UserRole  string `json:"user_role"`
+	UserEmail    string `json:"user_email"`
+	UserName     string `json:"user_name"`
+	UserRole     string `json:"user_role"`
 	UserPassword string `json:"user_password"`
+	Language     string `json:"language"`
+}
+
+// TaskAssignedEmailPayload represents the payload for task assigned email job
+type TaskAssignedEmailPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	TaskID uuid.UUID `json:"task_id"`
+}
+
+// TaskReminderEmailPayload represents the payload for due soon and overdue email jobs
+type TaskReminderEmailPayload struct {
+	UserID      uuid.UUID `json:"user_id"`
+	UserEmail   string    `json:"user_email"`
+	UserName    string    `json:"user_name"`
+	TaskTitle   string    `json:"task_title"`
+	ProjectName string    `json:"project_name"`
+	DueDate     time.Time `json:"due_date"`
+	Language    string    `json:"language"`
+}
+
+// AccountLockedEmailPayload represents the payload for account locked email job
+type AccountLockedEmailPayload struct {
+	UserEmail string        `json:"user_email"`
+	UserName  string        `json:"user_name"`
+	Language  string        `json:"language"`
+	IPAddress string        `json:"ip_address"`
+	LockedFor time.Duration `json:"locked_for"`
 }
 
 // EmailJobHandler handles email-related jobs
 type EmailJobHandler struct {
 	emailService *utils.EmailService
+	db           *gorm.DB
 }
 
 // NewEmailJobHandler creates a new email job handler
-func NewEmailJobHandler(cfg *config.Config) *EmailJobHandler {
+func NewEmailJobHandler(emailService *utils.EmailService, db *gorm.DB) *EmailJobHandler {
 	return &EmailJobHandler{
-		emailService: utils.NewEmailService(cfg),
+		emailService: emailService,
+		db:           db,
 	}
 }
 
 // NewWelcomeEmailTask creates a new welcome email task
-func NewWelcomeEmailTask(userEmail, userName, userRole, userPlainPassword string) (*asynq.Task, error) {
+func NewWelcomeEmailTask(userEmail, userName, userRole, userPlainPassword, language string) (*asynq.Task, error) {
 	payload := WelcomeEmailPayload{
-		UserEmail: userEmail,
-		UserName:  userName,
-		UserRole:  userRole,
+		UserEmail:    userEmail,
+		UserName:     userName,
+		UserRole:     userRole,
 		UserPassword: userPlainPassword,
+		Language:     language,
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -53,7 +91,8 @@ func NewWelcomeEmailTask(userEmail, userName, userRole, userPlainPassword string
 	return asynq.NewTask(TypeEmailWelcome, payloadBytes), nil
 }
 
-// HandleWelcomeEmail processes welcome email job
+// HandleWelcomeEmail processes welcome email job. Welcome emails carry the
+// account credentials, so they are not subject to notification preferences.
 func (h *EmailJobHandler) HandleWelcomeEmail(ctx context.Context, t *asynq.Task) error {
 	var payload WelcomeEmailPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -62,7 +101,7 @@ func (h *EmailJobHandler) HandleWelcomeEmail(ctx context.Context, t *asynq.Task)
 
 	log.Printf("Sending welcome email to %s (%s)", payload.UserName, payload.UserEmail)
 
-	err := h.emailService.SendWelcomeEmail(payload.UserEmail, payload.UserName, payload.UserRole, payload.UserPassword)
+	err := h.emailService.SendWelcomeEmail(payload.UserEmail, payload.UserName, payload.Language, payload.UserRole, payload.UserPassword)
 	if err != nil {
 		return fmt.Errorf("failed to send welcome email to %s: %w", payload.UserEmail, err)
 	}
@@ -70,3 +109,161 @@ func (h *EmailJobHandler) HandleWelcomeEmail(ctx context.Context, t *asynq.Task)
 	log.Printf("Welcome email sent successfully to %s", payload.UserEmail)
 	return nil
 }
+
+// NewTaskAssignedEmailTask creates a new task assigned email task
+func NewTaskAssignedEmailTask(userID, taskID uuid.UUID) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(TaskAssignedEmailPayload{
+		UserID: userID,
+		TaskID: taskID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TypeEmailTaskAssigned, payloadBytes), nil
+}
+
+// HandleTaskAssignedEmail processes task assigned email job
+func (h *EmailJobHandler) HandleTaskAssignedEmail(ctx context.Context, t *asynq.Task) error {
+	var payload TaskAssignedEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal task assigned payload: %w", err)
+	}
+
+	ok, err := wantsEmail(ctx, h.db, payload.UserID, entity.NotificationEventAssigned)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preference: %w", err)
+	}
+	if !ok {
+		log.Printf("Skipping task assigned email for user %s: disabled by preference", payload.UserID)
+		return nil
+	}
+
+	var user entity.User
+	if err := h.db.WithContext(ctx).Where("id = ?", payload.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to load assignee %s: %w", payload.UserID, err)
+	}
+
+	var task entity.Task
+	if err := h.db.WithContext(ctx).Where("id = ?", payload.TaskID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The task was deleted before the email went out
+			return nil
+		}
+		return fmt.Errorf("failed to load task %s: %w", payload.TaskID, err)
+	}
+
+	var project entity.Project
+	if task.ProjectID != nil {
+		h.db.WithContext(ctx).Where("id = ?", *task.ProjectID).First(&project)
+	}
+
+	log.Printf("Sending task assigned email for %q to %s", task.Title, user.Email)
+
+	unsubscribeURL := h.emailService.UnsubscribeURL(user.ID, entity.NotificationEventAssigned)
+	err = h.emailService.SendTaskAssignedEmail(user.Email, user.Name, user.Language, task.Title, project.Name, task.DueDate, unsubscribeURL)
+	if err != nil {
+		return fmt.Errorf("failed to send task assigned email to %s: %w", user.Email, err)
+	}
+
+	return nil
+}
+
+// NewTaskReminderEmailTask creates a new due soon or overdue email task
+func NewTaskReminderEmailTask(taskType string, payload TaskReminderEmailPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(taskType, payloadBytes), nil
+}
+
+// HandleTaskDueSoonEmail processes due soon reminder email job
+func (h *EmailJobHandler) HandleTaskDueSoonEmail(ctx context.Context, t *asynq.Task) error {
+	var payload TaskReminderEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal task due soon payload: %w", err)
+	}
+
+	ok, err := wantsEmail(ctx, h.db, payload.UserID, entity.NotificationEventDueSoon)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preference: %w", err)
+	}
+	if !ok {
+		log.Printf("Skipping due soon reminder for user %s: disabled by preference", payload.UserID)
+		return nil
+	}
+
+	log.Printf("Sending due soon reminder for %q to %s", payload.TaskTitle, payload.UserEmail)
+
+	unsubscribeURL := h.emailService.UnsubscribeURL(payload.UserID, entity.NotificationEventDueSoon)
+	err = h.emailService.SendTaskDueSoonEmail(payload.UserEmail, payload.UserName, payload.Language, payload.TaskTitle, payload.ProjectName, payload.DueDate, unsubscribeURL)
+	if err != nil {
+		return fmt.Errorf("failed to send due soon reminder to %s: %w", payload.UserEmail, err)
+	}
+
+	return nil
+}
+
+// HandleTaskOverdueEmail processes overdue notification email job
+func (h *EmailJobHandler) HandleTaskOverdueEmail(ctx context.Context, t *asynq.Task) error {
+	var payload TaskReminderEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal task overdue payload: %w", err)
+	}
+
+	// Overdue notifications follow the due soon preference
+	ok, err := wantsEmail(ctx, h.db, payload.UserID, entity.NotificationEventDueSoon)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preference: %w", err)
+	}
+	if !ok {
+		log.Printf("Skipping overdue notification for user %s: disabled by preference", payload.UserID)
+		return nil
+	}
+
+	log.Printf("Sending overdue notification for %q to %s", payload.TaskTitle, payload.UserEmail)
+
+	unsubscribeURL := h.emailService.UnsubscribeURL(payload.UserID, entity.NotificationEventDueSoon)
+	err = h.emailService.SendTaskOverdueEmail(payload.UserEmail, payload.UserName, payload.Language, payload.TaskTitle, payload.ProjectName, payload.DueDate, unsubscribeURL)
+	if err != nil {
+		return fmt.Errorf("failed to send overdue notification to %s: %w", payload.UserEmail, err)
+	}
+
+	return nil
+}
+
+// NewAccountLockedEmailTask creates a new account locked email task
+func NewAccountLockedEmailTask(userEmail, userName, language, ipAddress string, lockedFor time.Duration) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(AccountLockedEmailPayload{
+		UserEmail: userEmail,
+		UserName:  userName,
+		Language:  language,
+		IPAddress: ipAddress,
+		LockedFor: lockedFor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TypeEmailAccountLocked, payloadBytes), nil
+}
+
+// HandleAccountLockedEmail processes account locked email job. It is a
+// security notice, so it is not subject to notification preferences.
+func (h *EmailJobHandler) HandleAccountLockedEmail(ctx context.Context, t *asynq.Task) error {
+	var payload AccountLockedEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal account locked payload: %w", err)
+	}
+
+	log.Printf("Sending account locked email to %s", payload.UserEmail)
+
+	err := h.emailService.SendAccountLockedEmail(payload.UserEmail, payload.UserName, payload.Language, payload.IPAddress, payload.LockedFor)
+	if err != nil {
+		return fmt.Errorf("failed to send account locked email to %s: %w", payload.UserEmail, err)
+	}
+
+	return nil
+}
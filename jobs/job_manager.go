@@ -3,20 +3,49 @@ package jobs
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/audit"
+	"github.com/mnizarzr/dot-test/modules/role"
+	"github.com/mnizarzr/dot-test/policy"
+	emailtemplate "github.com/mnizarzr/dot-test/template"
+	"github.com/mnizarzr/dot-test/utils"
+	"gorm.io/gorm"
 )
 
 type JobManager struct {
-	client *asynq.Client
-	server *asynq.Server
-	mux    *asynq.ServeMux
-	config *config.Config
+	client    *asynq.Client
+	server    *asynq.Server
+	scheduler *asynq.Scheduler
+	mux       *asynq.ServeMux
+	config    *config.Config
+	db        *gorm.DB
+	mailer    utils.Mailer
+	renderer  *utils.TemplateRenderer
+	auditSink utils.AuditSink
 }
 
 // NewJobManager creates a new job manager instance
-func NewJobManager(cfg *config.Config) *JobManager {
+func NewJobManager(cfg *config.Config, database *gorm.DB) (*JobManager, error) {
+	// Templates are parsed once here so a broken template fails at startup
+	renderer, err := utils.NewTemplateRenderer(emailtemplate.Email(), entity.DefaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := utils.NewMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	auditSink, err := utils.NewAuditSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	redisOpt := asynq.RedisClientOpt{
 		Addr:     cfg.RedisAddress,
 		Password: cfg.RedisPassword,
@@ -31,22 +60,71 @@ func NewJobManager(cfg *config.Config) *JobManager {
 			"default":  3,
 			"low":      1,
 		},
+		// Audit events are batched before being shipped to the sink
+		GroupAggregator:  asynq.GroupAggregatorFunc(AggregateAuditEvents),
+		GroupMaxSize:     cfg.AuditSinkBatchSize,
+		GroupGracePeriod: time.Duration(cfg.AuditSinkBatchWait) * time.Second,
 	})
 
+	scheduler := asynq.NewScheduler(redisOpt, nil)
+
 	mux := asynq.NewServeMux()
 
 	return &JobManager{
-		client: client,
-		server: server,
-		mux:    mux,
-		config: cfg,
-	}
+		client:    client,
+		server:    server,
+		scheduler: scheduler,
+		mux:       mux,
+		config:    cfg,
+		db:        database,
+		mailer:    mailer,
+		renderer:  renderer,
+		auditSink: auditSink,
+	}, nil
 }
 
 // RegisterHandlers registers all job handlers
 func (jm *JobManager) RegisterHandlers() {
-	emailJobHandler := NewEmailJobHandler(jm.config)
+	// All handlers share one email service so SMTP connections are pooled across jobs
+	emailService := utils.NewEmailService(jm.config, jm.mailer, jm.renderer)
+
+	emailJobHandler := NewEmailJobHandler(emailService, jm.db)
 	jm.mux.HandleFunc(TypeEmailWelcome, emailJobHandler.HandleWelcomeEmail)
+	jm.mux.HandleFunc(TypeEmailTaskAssigned, emailJobHandler.HandleTaskAssignedEmail)
+	jm.mux.HandleFunc(TypeEmailTaskDueSoon, emailJobHandler.HandleTaskDueSoonEmail)
+	jm.mux.HandleFunc(TypeEmailTaskOverdue, emailJobHandler.HandleTaskOverdueEmail)
+	jm.mux.HandleFunc(TypeEmailAccountLocked, emailJobHandler.HandleAccountLockedEmail)
+
+	reminderJobHandler := NewReminderJobHandler(jm.db, jm.client, jm.config.ReminderDueSoonHours)
+	jm.mux.HandleFunc(TypeReminderScan, reminderJobHandler.HandleReminderScan)
+
+	digestJobHandler := NewDigestJobHandler(jm.config, emailService, jm.db, jm.client, policy.NewEngine(role.NewRepository(jm.db)))
+	jm.mux.HandleFunc(TypeDigestScan, digestJobHandler.HandleDigestScan)
+	jm.mux.HandleFunc(TypeEmailDigest, digestJobHandler.HandleDigestEmail)
+
+	auditJobHandler := NewAuditJobHandler(jm.config, audit.NewService(audit.NewRepository(jm.db)))
+	jm.mux.HandleFunc(TypeAuditMaintenance, auditJobHandler.HandleAuditMaintenance)
+
+	auditSinkJobHandler := NewAuditSinkJobHandler(jm.auditSink)
+	jm.mux.HandleFunc(TypeAuditEvent, auditSinkJobHandler.HandleAuditEvent)
+	jm.mux.HandleFunc(TypeAuditEventBatch, auditSinkJobHandler.HandleAuditEventBatch)
+}
+
+// RegisterSchedules registers all periodic jobs
+func (jm *JobManager) RegisterSchedules() error {
+	// Unique keeps overlapping schedulers from running the same scan twice
+	_, err := jm.scheduler.Register(jm.config.ReminderScanCron, NewReminderScanTask(), asynq.Queue("low"), asynq.Unique(5*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	_, err = jm.scheduler.Register(jm.config.DigestCron, NewDigestScanTask(), asynq.Queue("low"), asynq.Unique(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	_, err = jm.scheduler.Register(jm.config.AuditMaintenanceCron, NewAuditMaintenanceTask(), asynq.Queue("low"), asynq.Unique(time.Hour))
+	return err
 }
 
 // EnqueueJob enqueues a job for processing
@@ -54,9 +132,31 @@ func (jm *JobManager) EnqueueJob(ctx context.Context, task *asynq.Task, opts ...
 	return jm.client.Enqueue(task, opts...)
 }
 
-// Start starts the job processing server
+// Start starts the job scheduler and processing server
 func (jm *JobManager) Start() error {
 	jm.RegisterHandlers()
+
+	if err := jm.RegisterSchedules(); err != nil {
+		return err
+	}
+
+	log.Println("Starting job scheduler...")
+	if err := jm.scheduler.Start(); err != nil {
+		return err
+	}
+	defer jm.scheduler.Shutdown()
+	defer jm.mailer.Close()
+	if jm.auditSink != nil {
+		defer jm.auditSink.Close()
+	}
+
+	// Committed audit entries are streamed to the sink while the worker runs
+	if jm.auditSink != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go NewAuditEventRelay(jm.db, jm.client).Run(ctx)
+	}
+
 	log.Println("Starting job processing server...")
 	return jm.server.Run(jm.mux)
 }
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+)
+
+// notificationChannel returns the channel a user chose for an event type,
+// falling back to the default channel when no preference is stored
+func notificationChannel(ctx context.Context, db *gorm.DB, userID uuid.UUID, eventType string) (string, error) {
+	var pref entity.NotificationPreference
+	err := db.WithContext(ctx).Where("user_id = ? AND event_type = ?", userID, eventType).First(&pref).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.DefaultNotificationChannel, nil
+		}
+		return "", err
+	}
+	return pref.Channel, nil
+}
+
+// wantsEmail reports whether a user receives the given event type by email
+func wantsEmail(ctx context.Context, db *gorm.DB, userID uuid.UUID, eventType string) (bool, error) {
+	channel, err := notificationChannel(ctx, db, userID, eventType)
+	if err != nil {
+		return false, err
+	}
+	return channel == entity.NotificationChannelEmail, nil
+}
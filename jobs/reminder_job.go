@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job type constants
+const (
+	TypeReminderScan = "reminder:scan"
+)
+
+// reminderCandidate is a task assignment that may need a reminder
+type reminderCandidate struct {
+	TaskID      uuid.UUID
+	TaskTitle   string
+	ProjectName string
+	DueDate     time.Time
+	UserID      uuid.UUID
+	UserName    string
+	UserEmail   string
+	Language    string
+}
+
+// ReminderJobHandler finds tasks that are due soon or overdue and enqueues reminder emails
+type ReminderJobHandler struct {
+	db            *gorm.DB
+	client        *asynq.Client
+	dueSoonWindow time.Duration
+}
+
+// NewReminderJobHandler creates a new reminder job handler
+func NewReminderJobHandler(db *gorm.DB, client *asynq.Client, dueSoonHours int) *ReminderJobHandler {
+	return &ReminderJobHandler{
+		db:            db,
+		client:        client,
+		dueSoonWindow: time.Duration(dueSoonHours) * time.Hour,
+	}
+}
+
+// NewReminderScanTask creates a new reminder scan task
+func NewReminderScanTask() *asynq.Task {
+	return asynq.NewTask(TypeReminderScan, nil)
+}
+
+// HandleReminderScan processes reminder scan job
+func (h *ReminderJobHandler) HandleReminderScan(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	dueSoon, err := h.findCandidates(ctx, entity.ReminderThresholdDueSoon, "t.due_date >= ? AND t.due_date <= ?", today, now.Add(h.dueSoonWindow))
+	if err != nil {
+		return fmt.Errorf("failed to find tasks due soon: %w", err)
+	}
+
+	overdue, err := h.findCandidates(ctx, entity.ReminderThresholdOverdue, "t.due_date < ?", today)
+	if err != nil {
+		return fmt.Errorf("failed to find overdue tasks: %w", err)
+	}
+
+	sent := h.notify(ctx, entity.ReminderThresholdDueSoon, TypeEmailTaskDueSoon, dueSoon)
+	sent += h.notify(ctx, entity.ReminderThresholdOverdue, TypeEmailTaskOverdue, overdue)
+
+	if sent > 0 {
+		log.Printf("Enqueued %d task reminder emails", sent)
+	}
+	return nil
+}
+
+// findCandidates returns open, assigned tasks matching the due date condition
+// that have not been reminded for the given threshold yet
+func (h *ReminderJobHandler) findCandidates(ctx context.Context, threshold, dueCondition string, args ...interface{}) ([]reminderCandidate, error) {
+	var candidates []reminderCandidate
+
+	err := h.db.WithContext(ctx).
+		Table("tasks AS t").
+		Select("t.id AS task_id, t.title AS task_title, COALESCE(p.name, '') AS project_name, t.due_date, u.id AS user_id, u.name AS user_name, u.email AS user_email, u.language").
+		Joins("JOIN users u ON u.id = t.assigned_to").
+		Joins("LEFT JOIN projects p ON p.id = t.project_id").
+		Where("t.status <> ?", "completed").
+		Where(dueCondition, args...).
+		Where("NOT EXISTS (SELECT 1 FROM task_reminders r WHERE r.task_id = t.id AND r.threshold = ? AND r.due_date = t.due_date)", threshold).
+		Scan(&candidates).Error
+
+	return candidates, err
+}
+
+// notify claims a reminder slot for each candidate and enqueues the email,
+// returning the number of emails enqueued
+func (h *ReminderJobHandler) notify(ctx context.Context, threshold, emailType string, candidates []reminderCandidate) int {
+	sent := 0
+	for _, c := range candidates {
+		reminder := &entity.TaskReminder{
+			ID:         uuid.New(),
+			TaskID:     c.TaskID,
+			UserID:     c.UserID,
+			Threshold:  threshold,
+			DueDate:    c.DueDate,
+			NotifiedAt: time.Now(),
+		}
+
+		// The unique constraint makes the claim safe when several workers scan at once
+		result := h.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+		if result.Error != nil {
+			log.Printf("Failed to record %s reminder for task %s: %v", threshold, c.TaskID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		task, err := NewTaskReminderEmailTask(emailType, TaskReminderEmailPayload{
+			UserID:      c.UserID,
+			UserEmail:   c.UserEmail,
+			UserName:    c.UserName,
+			TaskTitle:   c.TaskTitle,
+			ProjectName: c.ProjectName,
+			DueDate:     c.DueDate,
+			Language:    c.Language,
+		})
+		if err == nil {
+			_, err = h.client.Enqueue(task, asynq.Queue("default"), asynq.MaxRetry(3))
+		}
+		if err != nil {
+			log.Printf("Failed to enqueue %s reminder for task %s: %v", threshold, c.TaskID, err)
+			// Release the claim so the next scan retries it
+			h.db.WithContext(ctx).Delete(reminder)
+			continue
+		}
+
+		sent++
+	}
+	return sent
+}
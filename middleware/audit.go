@@ -2,23 +2,24 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/config"
 	"github.com/mnizarzr/dot-test/entity"
 	"gorm.io/gorm"
 )
 
-// AuditResourceContext injects audit information into the request context for GORM hooks
+// AuditResourceContext injects audit information into the request context for
+// GORM hooks. The user ID is added by the auth middleware with the principal.
 func AuditResourceContext() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
-		// Inject user ID if available
-		if userID, exists := c.Get("user_id"); exists {
-			ctx = context.WithValue(ctx, entity.AuditUserIDKey, userID)
-		}
-
 		// Inject IP address
 		ctx = context.WithValue(ctx, entity.AuditIPKey, c.ClientIP())
 
@@ -37,7 +38,8 @@ func AuditResourceContext() gin.HandlerFunc {
 func AuditMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
-		// after request processing, log the action if it was successful, something like bruteforce should be handled by other tools
+		writeErrors(c)
+		// after request processing, log the action if it was successful, failures are logged by AuditSecurityEvents
 		if c.Writer.Status() >= 200 && c.Writer.Status() < 300 {
 			path := c.Request.URL.Path
 			method := c.Request.Method
@@ -45,14 +47,6 @@ func AuditMiddleware(db *gorm.DB) gin.HandlerFunc {
 			action := method + " " + path
 			targetResource := method + " " + path
 
-			ctx := c.Request.Context()
-
-			if userID, exists := c.Get("user_id"); exists {
-				ctx = context.WithValue(ctx, entity.AuditUserIDKey, userID)
-			}
-
-			ctx = context.WithValue(ctx, entity.AuditIPKey, c.ClientIP())
-			ctx = context.WithValue(ctx, entity.AuditUserAgent, c.Request.UserAgent())
 			targetID := uuid.Nil
 
 			auditData := map[string]any{
@@ -61,7 +55,114 @@ func AuditMiddleware(db *gorm.DB) gin.HandlerFunc {
 				"status": c.Writer.Status(),
 			}
 
-			entity.CreateAuditLog(db.WithContext(ctx), action, targetResource, targetID, auditData, nil)
+			entity.CreateAuditLog(db.WithContext(auditContext(c)), action, targetResource, targetID, auditData, nil)
+		}
+	}
+}
+
+// AuditSecurityEvents logs failed authentication (401, or 429 from a login
+// lockout) and authorization denials (403) when enabled by AUDIT_FAILED_AUTH
+// and AUDIT_ACCESS_DENIED.
+// Handlers and middleware can explain a failure by setting "audit_reason", and
+// login attempts set "audit_email", which is only stored as a hash.
+func AuditSecurityEvents(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeErrors(c)
+
+		var action, reason string
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized && cfg.AuditFailedAuth:
+			action, reason = entity.AuditActionAuthFailure, "unauthorized"
+		case status == http.StatusTooManyRequests && cfg.AuditFailedAuth && c.GetString("audit_reason") == "locked_out":
+			action = entity.AuditActionAuthFailure
+		case status == http.StatusForbidden && cfg.AuditAccessDenied:
+			action, reason = entity.AuditActionAccessDenied, "forbidden"
+		default:
+			return
+		}
+
+		if r := c.GetString("audit_reason"); r != "" {
+			reason = r
+		}
+
+		auditData := map[string]any{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"status": c.Writer.Status(),
+			"reason": reason,
+		}
+		if email := c.GetString("audit_email"); email != "" {
+			auditData["email_hash"] = hashEmail(email)
+		}
+
+		entity.CreateAuditLog(db.WithContext(auditContext(c)), action, routeResource(c), paramID(c), auditData, nil)
+	}
+}
+
+// AuditReadAccess logs successful GET requests against a resource type when it
+// is listed in AUDIT_READ_RESOURCES
+func AuditReadAccess(db *gorm.DB, cfg *config.Config, resource string) gin.HandlerFunc {
+	enabled := false
+	for _, r := range strings.Split(cfg.AuditReadResources, ",") {
+		if strings.TrimSpace(r) == resource {
+			enabled = true
 		}
 	}
+
+	return func(c *gin.Context) {
+		c.Next()
+		writeErrors(c)
+
+		if !enabled || c.Request.Method != http.MethodGet {
+			return
+		}
+		if c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
+			return
+		}
+
+		auditData := map[string]any{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"query":  c.Request.URL.RawQuery,
+			"status": c.Writer.Status(),
+		}
+
+		entity.CreateAuditLog(db.WithContext(auditContext(c)), entity.AuditActionRead, resource, paramID(c), auditData, nil)
+	}
+}
+
+// auditContext returns the request context with the client information. The
+// authenticated user, if any, was added by the auth middleware.
+func auditContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+
+	ctx = context.WithValue(ctx, entity.AuditIPKey, c.ClientIP())
+	ctx = context.WithValue(ctx, entity.AuditUserAgent, c.Request.UserAgent())
+
+	return ctx
+}
+
+// routeResource names the resource of a request by its route, e.g. "GET /api/v1/tasks/:id"
+func routeResource(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return c.Request.Method + " " + route
+}
+
+// paramID returns the :id route parameter, or uuid.Nil when there is none
+func paramID(c *gin.Context) uuid.UUID {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// hashEmail identifies an email address in the audit log without storing it
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+var registerFieldNames sync.Once
+
+// ErrorHandler renders the last error a handler or middleware passed to
+// c.Error as RFC 7807 problem details. Application errors keep their status
+// and code, validation errors list the invalid fields, malformed requests are
+// a 400, and anything else is logged and reported as an internal error.
+// Messages are translated to the request's language.
+func ErrorHandler() gin.HandlerFunc {
+	registerFieldNames.Do(func() {
+		if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+			v.RegisterTagNameFunc(common.FieldName)
+		}
+	})
+
+	return func(c *gin.Context) {
+		c.Next()
+		writeErrors(c)
+	}
+}
+
+// writeErrors renders the request's error unless a response was written.
+// Middlewares that inspect the response status call it after c.Next, since
+// ErrorHandler only renders once they have returned.
+func writeErrors(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	appErr := toAppError(err)
+	if appErr.Status >= 500 {
+		log.Printf("Request %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	lang := i18n.Language(c.Request.Context())
+
+	// c.JSON keeps a content type that is already set
+	c.Header("Content-Type", common.ProblemContentType)
+	c.Header("Content-Language", lang)
+	c.JSON(appErr.Status, common.NewProblem(appErr, c.Request.URL.Path, lang))
+}
+
+// toAppError classifies an error for the response
+func toAppError(err error) *common.AppError {
+	var appErr *common.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return common.NewValidationError(validationErrs)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	var timeErr *time.ParseError
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &numErr), errors.As(err, &timeErr):
+		return common.ErrInvalidRequestBody
+	case errors.As(err, &typeErr):
+		return common.ErrInvalidFieldType.WithParam("field", typeErr.Field).WithParam("type", typeErr.Type.String())
+	}
+
+	return common.ErrInternal
+}
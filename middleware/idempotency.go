@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/db"
+)
+
+// IdempotencyKeyHeader carries the client's key for retrying a POST safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength caps client supplied keys before they are stored
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockTTL bounds how long a key stays locked after its request
+// stopped extending it, so a crashed replica does not block retries for the
+// whole TTL. A running request extends it every idempotencyLockRefresh.
+const (
+	idempotencyLockTTL     = time.Minute
+	idempotencyLockRefresh = idempotencyLockTTL / 3
+)
+
+// idempotencyReplayHeaders are the response headers replayed with the body
+var idempotencyReplayHeaders = []string{"Content-Type", "Content-Language", "Location", "ETag"}
+
+// idempotencyRecord is the state of a key in Redis: in flight until the first
+// request finishes, then its response
+type idempotencyRecord struct {
+	Fingerprint string              `json:"fingerprint"`
+	InFlight    bool                `json:"in_flight"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// Idempotency lets clients retry POST requests without repeating them. The
+// first request with an Idempotency-Key runs and its response is kept for
+// ttl; retries with the same key and body get that response again, marked
+// with Idempotent-Replayed. A retry while the first request still runs gets
+// 409, and reusing a key for a different request gets 422. Keys are scoped to
+// the authenticated user, so it must run after the JWT middleware. Server
+// errors are not kept, so those requests can be retried, and Redis errors
+// let the request through rather than take the API down.
+func Idempotency(cache *db.RedisClient, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || !isPrintableASCII(key) {
+			c.Error(common.ErrInvalidIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(common.ErrInvalidRequestBody)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		redisKey := "idempotency:ip:" + c.ClientIP() + ":" + key
+		if principal, ok := common.PrincipalFromContext(ctx); ok {
+			redisKey = "idempotency:user:" + principal.UserID.String() + ":" + key
+		}
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		lock, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, InFlight: true})
+		acquired, err := cache.GetClient().SetNX(ctx, redisKey, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			log.Printf("Idempotency check failed for %s: %v", redisKey, err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(c, cache, redisKey, fingerprint)
+			return
+		}
+
+		// Keep the key locked however long the handler runs, so a retry
+		// cannot run the request a second time. A panicking handler stops
+		// extending it too.
+		release := sync.OnceFunc(holdIdempotencyLock(ctx, idempotencyLockRefresh, func(ctx context.Context) error {
+			return cache.GetClient().Expire(ctx, redisKey, idempotencyLockTTL).Err()
+		}))
+		defer release()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		release()
+
+		// Render errors now, so the stored response is the one the client got
+		writeErrors(c)
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := cache.Delete(ctx, redisKey); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", redisKey, err)
+			}
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      make(map[string][]string),
+			Body:        recorder.body.Bytes(),
+		}
+		for _, name := range idempotencyReplayHeaders {
+			if values := c.Writer.Header().Values(name); len(values) > 0 {
+				record.Header[name] = values
+			}
+		}
+		if err := cache.Set(ctx, redisKey, record, ttl); err != nil {
+			log.Printf("Failed to store idempotent response for %s: %v", redisKey, err)
+		}
+	}
+}
+
+// holdIdempotencyLock calls extend every interval until the returned function
+// is called, which waits for a running extend so it cannot cut the TTL of the
+// stored response short. Extending continues when the client disconnects,
+// since the handler keeps running.
+func holdIdempotencyLock(ctx context.Context, interval time.Duration, extend func(context.Context) error) func() {
+	ctx = context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := extend(ctx); err != nil {
+					log.Printf("Failed to extend idempotency lock: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// replayIdempotentResponse answers a request whose key is already taken
+func replayIdempotentResponse(c *gin.Context, cache *db.RedisClient, redisKey, fingerprint string) {
+	var record idempotencyRecord
+	if err := cache.Get(c.Request.Context(), redisKey, &record); err != nil {
+		log.Printf("Failed to load idempotent response for %s: %v", redisKey, err)
+		c.Error(common.ErrInternal)
+		c.Abort()
+		return
+	}
+
+	switch {
+	case record.Fingerprint == "":
+		// The first request failed and released the key in the meantime
+		c.Error(common.ErrIdempotencyKeyInUse)
+	case record.Fingerprint != fingerprint:
+		c.Error(common.ErrIdempotencyKeyReused)
+	case record.InFlight:
+		c.Error(common.ErrIdempotencyKeyInUse)
+	default:
+		for name, values := range record.Header {
+			for _, value := range values {
+				c.Writer.Header().Add(name, value)
+			}
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(record.Status)
+		_, _ = c.Writer.Write(record.Body)
+	}
+	c.Abort()
+}
+
+// requestFingerprint identifies a request by its method, URI and body, so a
+// key cannot be reused for a different request
+func requestFingerprint(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + uri + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bodyRecorder keeps a copy of the response body while writing it
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHoldIdempotencyLockExtendsUntilReleased(t *testing.T) {
+	var extended atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	release := holdIdempotencyLock(ctx, time.Millisecond, func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Error("the lock was extended with a cancelled context")
+		}
+		extended.Add(1)
+		return nil
+	})
+
+	// A client disconnecting does not stop the handler, so the lock is
+	// still extended
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for extended.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	release()
+
+	count := extended.Load()
+	if count < 3 {
+		t.Fatalf("lock extended %d times while the request ran", count)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if extended.Load() != count {
+		t.Error("lock was extended after it was released")
+	}
+}
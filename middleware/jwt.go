@@ -1,27 +1,45 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
 	"github.com/mnizarzr/dot-test/utils"
 )
 
-// JWTAuth middleware for JWT authentication
-func JWTAuth(jwtSecret string) gin.HandlerFunc {
+// SessionValidator checks that the login session of an access token was not revoked
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, sessionID, userID, clientIP string) error
+}
+
+// APIKeyAuthenticator resolves an API key to the user it authenticates as
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key, clientIP string) (*entity.User, *entity.APIKey, error)
+}
+
+// JWTAuth middleware for JWT authentication. Personal access tokens and
+// service account API keys are accepted in place of a JWT when apiKeys is
+// set; pass nil for routes that must only be used by a logged in user.
+func JWTAuth(jwtKeys *utils.JWTKeys, sessions SessionValidator, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			common.ErrorResponse(c, 401, "Authorization header required")
+			c.Set("audit_reason", "missing_token")
+			c.Error(common.ErrAuthenticationRequired)
 			c.Abort()
 			return
 		}
 
 		// Check if it starts with "Bearer "
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			common.ErrorResponse(c, 401, "Invalid authorization header format")
+			c.Set("audit_reason", "invalid_header")
+			c.Error(common.ErrInvalidAuthHeader)
 			c.Abort()
 			return
 		}
@@ -29,23 +47,115 @@ func JWTAuth(jwtSecret string) gin.HandlerFunc {
 		// Extract token
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == "" {
-			common.ErrorResponse(c, 401, "Token is required")
+			c.Set("audit_reason", "missing_token")
+			c.Error(common.ErrAuthenticationRequired)
 			c.Abort()
 			return
 		}
 
+		if apiKeys != nil && utils.IsAPIKey(tokenString) {
+			apiKeyAuth(c, apiKeys, tokenString)
+			return
+		}
+
 		// Validate token
-		claims, err := utils.ValidateJWT(tokenString, jwtSecret)
+		claims, err := utils.ValidateJWT(tokenString, jwtKeys)
+		if err != nil {
+			c.Set("audit_reason", "invalid_token")
+			c.Error(common.ErrInvalidToken)
+			c.Abort()
+			return
+		}
+
+		if err := sessions.ValidateSession(c.Request.Context(), claims.SessionID, claims.UserID, c.ClientIP()); err != nil {
+			if errors.Is(err, common.ErrSessionRevoked) {
+				c.Set("audit_reason", "revoked_session")
+			}
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		principal, err := jwtPrincipal(claims)
 		if err != nil {
-			common.ErrorResponse(c, 401, "Invalid or expired token")
+			c.Set("audit_reason", "invalid_token")
+			c.Error(common.ErrInvalidToken)
 			c.Abort()
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// apiKeyAuth authenticates the request as the owner of an API key
+func apiKeyAuth(c *gin.Context, apiKeys APIKeyAuthenticator, key string) {
+	user, apiKey, err := apiKeys.Authenticate(c.Request.Context(), key, c.ClientIP())
+	if err != nil {
+		c.Set("audit_reason", "invalid_api_key")
+		c.Error(common.ErrInvalidAPIKey)
+		c.Abort()
+		return
+	}
+
+	setPrincipal(c, &common.Principal{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Role:       user.Role,
+		AuthMethod: common.AuthMethodAPIKey,
+		APIKeyID:   apiKey.ID,
+		Scopes:     apiKey.Scopes,
+	})
+	c.Next()
+}
+
+// jwtPrincipal returns the principal an access token authenticates
+func jwtPrincipal(claims *utils.JWTClaims) (*common.Principal, error) {
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.Principal{
+		UserID:     userID,
+		Email:      claims.Email,
+		Role:       claims.Role,
+		AuthMethod: common.AuthMethodJWT,
+		SessionID:  claims.SessionID,
+	}, nil
+}
+
+// setPrincipal stores the principal in the request context, where handlers,
+// services and the audit hooks find it
+func setPrincipal(c *gin.Context, principal *common.Principal) {
+	ctx := common.WithPrincipal(c.Request.Context(), principal)
+	ctx = context.WithValue(ctx, entity.AuditUserIDKey, principal.UserID.String())
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// RequireScope restricts API key requests to keys granted the resource scope:
+// <resource>:read for GET and HEAD requests, <resource>:write otherwise.
+// Requests authenticated with a JWT are not affected.
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := common.PrincipalFromContext(c.Request.Context())
+		if !ok || !principal.IsAPIKey() {
+			c.Next()
+			return
+		}
+
+		scope := resource + ":write"
+		if c.Request.Method == "GET" || c.Request.Method == "HEAD" {
+			scope = resource + ":read"
+		}
+
+		if !principal.HasScope(scope) {
+			c.Set("audit_reason", "missing_scope")
+			c.Error(common.ErrMissingScope.WithParam("scope", scope))
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// UserLanguageStore loads the user whose language preference applies
+type UserLanguageStore interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
+}
+
+// Language picks the language of a request's messages. The language saved in
+// the authenticated user's preferences wins, otherwise the Accept-Language
+// header is negotiated against the catalogs. The user is looked up only when
+// a message is translated, since authentication runs after this middleware.
+func Language(users UserLanguageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		negotiated := i18n.Negotiate(c.GetHeader("Accept-Language"))
+
+		var preferred string
+		resolve := func(ctx context.Context) string {
+			if preferred != "" {
+				return preferred
+			}
+
+			principal, ok := common.PrincipalFromContext(ctx)
+			if !ok {
+				return negotiated
+			}
+
+			preferred = negotiated
+
+			// A deleted user is not found, while their token can still be trusted
+			if user, err := users.GetByID(ctx, principal.UserID); err == nil && user != nil && i18n.Supported(user.Language) {
+				preferred = user.Language
+			}
+			return preferred
+		}
+
+		c.Header("Vary", "Accept-Language")
+		c.Request = c.Request.WithContext(i18n.WithResolver(c.Request.Context(), resolve))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// fakeLanguageStore returns the users it holds, and nil without an error for
+// others like the user repository does
+type fakeLanguageStore map[uuid.UUID]*entity.User
+
+func (s fakeLanguageStore) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return s[id], nil
+}
+
+func languageOf(t *testing.T, users fakeLanguageStore, userID uuid.UUID, acceptLanguage string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var lang string
+	router := gin.New()
+	router.Use(Language(users))
+	router.GET("/", func(c *gin.Context) {
+		ctx := common.WithPrincipal(c.Request.Context(), &common.Principal{UserID: userID})
+		lang = i18n.Language(ctx)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	return lang
+}
+
+func TestLanguagePrefersUserPreference(t *testing.T) {
+	userID := uuid.New()
+	users := fakeLanguageStore{userID: {ID: userID, Language: "id"}}
+
+	if lang := languageOf(t, users, userID, "en-US"); lang != "id" {
+		t.Errorf("language = %q, want the user's preference id", lang)
+	}
+}
+
+func TestLanguageFallsBackForDeletedUser(t *testing.T) {
+	if lang := languageOf(t, fakeLanguageStore{}, uuid.New(), "id-ID,en;q=0.5"); lang != "id" {
+		t.Errorf("language = %q, want the negotiated id", lang)
+	}
+}
@@ -10,7 +10,7 @@ import (
 // OptionalJWTAuth middleware that allows both authenticated and unauthenticated requests
 // If a valid JWT token is provided, it extracts user information
 // If no token or invalid token, it continues without setting user context
-func OptionalJWTAuth(jwtSecret string) gin.HandlerFunc {
+func OptionalJWTAuth(jwtKeys *utils.JWTKeys, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -36,18 +36,27 @@ func OptionalJWTAuth(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// Validate token
-		claims, err := utils.ValidateJWT(tokenString, jwtSecret)
+		claims, err := utils.ValidateJWT(tokenString, jwtKeys)
 		if err != nil {
 			// Invalid token, continue without user context
 			c.Next()
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		if err := sessions.ValidateSession(c.Request.Context(), claims.SessionID, claims.UserID, c.ClientIP()); err != nil {
+			// Revoked session, continue without user context
+			c.Next()
+			return
+		}
+
+		principal, err := jwtPrincipal(claims)
+		if err != nil {
+			// Malformed subject, continue without user context
+			c.Next()
+			return
+		}
 
+		setPrincipal(c, principal)
 		c.Next()
 	}
 }
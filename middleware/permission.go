@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// RequirePermission restricts a route to users whose role has every listed
+// permission. It must run after JWTAuth, which sets the principal.
+func RequirePermission(engine *policy.Engine, permissions ...policy.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := common.CurrentPrincipal(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		granted, err := engine.Permissions(c.Request.Context(), principal.Role)
+		if err != nil {
+			c.Error(common.ErrFailedToCheckPermission)
+			c.Abort()
+			return
+		}
+
+		for _, permission := range permissions {
+			if !granted.Has(permission) {
+				c.Set("audit_reason", "missing_permission")
+				c.Error(common.ErrMissingPermission.WithParam("permission", string(permission)))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
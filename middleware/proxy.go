@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTrustedProxies sets the proxies whose X-Forwarded-For and X-Real-IP
+// headers gin believes, from a comma-separated list of IPs and CIDR ranges.
+// With an empty list no proxy is trusted and the client IP is the address of
+// the peer, so clients cannot pick the IP that rate limits, login lockouts,
+// the allowlist and audit entries see.
+func SetupTrustedProxies(r *gin.Engine, list string) error {
+	var proxies []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, entry)
+		}
+	}
+
+	return r.SetTrustedProxies(proxies)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIdentity serves one request from peer with an X-Forwarded-For header
+// and returns the rate limit key and allowlist match the request gets
+func clientIdentity(t *testing.T, trustedProxies, peer, forwardedFor string) (string, bool) {
+	t.Helper()
+
+	r := gin.New()
+	if err := SetupTrustedProxies(r, trustedProxies); err != nil {
+		t.Fatalf("SetupTrustedProxies: %v", err)
+	}
+	allowlist := ParseAllowlist("10.0.0.0/8")
+
+	var key string
+	var allowlisted bool
+	r.GET("/", func(c *gin.Context) {
+		key = rateLimitIPKey(c, "api")
+		allowlisted = isAllowlisted(allowlist, c.ClientIP())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = peer + ":41234"
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	return key, allowlisted
+}
+
+func TestSpoofedForwardedForIsIgnored(t *testing.T) {
+	for _, forwardedFor := range []string{"10.0.0.1", "203.0.113.1", "203.0.113.2, 10.0.0.1"} {
+		key, allowlisted := clientIdentity(t, "", "198.51.100.9", forwardedFor)
+		if key != "ratelimit:api:ip:198.51.100.9" {
+			t.Errorf("X-Forwarded-For %q: key = %s, want the peer address", forwardedFor, key)
+		}
+		if allowlisted {
+			t.Errorf("X-Forwarded-For %q matched the allowlist", forwardedFor)
+		}
+	}
+}
+
+func TestForwardedForFromUntrustedPeerIsIgnored(t *testing.T) {
+	key, allowlisted := clientIdentity(t, "192.0.2.10", "198.51.100.9", "10.0.0.1")
+	if key != "ratelimit:api:ip:198.51.100.9" || allowlisted {
+		t.Errorf("key = %s, allowlisted = %v, want the peer address", key, allowlisted)
+	}
+}
+
+func TestForwardedForFromTrustedProxy(t *testing.T) {
+	key, allowlisted := clientIdentity(t, "192.0.2.0/24, 198.51.100.1", "192.0.2.10", "203.0.113.5")
+	if key != "ratelimit:api:ip:203.0.113.5" || allowlisted {
+		t.Errorf("key = %s, allowlisted = %v, want the forwarded address", key, allowlisted)
+	}
+
+	if _, allowlisted := clientIdentity(t, "192.0.2.10", "192.0.2.10", "10.0.0.1"); !allowlisted {
+		t.Error("an internal client behind a trusted proxy did not match the allowlist")
+	}
+}
+
+func TestSetupTrustedProxiesRejectsInvalidEntries(t *testing.T) {
+	if err := SetupTrustedProxies(gin.New(), "192.0.2.10, not-an-ip"); err == nil {
+		t.Error("SetupTrustedProxies accepted an invalid proxy")
+	}
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/db"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitPolicy allows Limit requests per client within a sliding Window.
+// Name namespaces the counters, so groups sharing a name share a budget.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// slidingWindowScript keeps one sorted set entry per request within the
+// window, scored by Redis server time so replicas with skewed clocks agree.
+// It returns whether the request is allowed, the remaining requests and the
+// milliseconds until the oldest request leaves the window.
+var slidingWindowScript = redis.NewScript(`
+local now = redis.call('TIME')
+local now_ms = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now_ms - window)
+
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now_ms, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now_ms
+end
+
+return {allowed, limit - count, reset}
+`)
+
+// RateLimit throttles requests with a Redis sliding window, so limits hold
+// across replicas. Clients are keyed by user ID when the JWT middleware ran
+// before it, and by IP otherwise. Clients in the allowlist are not limited.
+// Redis errors let the request through rather than take the API down.
+func RateLimit(cache *db.RedisClient, allowlist []*net.IPNet, policy RateLimitPolicy) gin.HandlerFunc {
+	windowMillis := policy.Window.Milliseconds()
+	policyHeader := strconv.Itoa(policy.Limit) + ";w=" + strconv.FormatInt(int64(policy.Window.Seconds()), 10)
+
+	return func(c *gin.Context) {
+		if isAllowlisted(allowlist, c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		key := rateLimitIPKey(c, policy.Name)
+		if principal, ok := common.PrincipalFromContext(c.Request.Context()); ok {
+			key = "ratelimit:" + policy.Name + ":user:" + principal.UserID.String()
+		}
+
+		result, err := slidingWindowScript.Run(c.Request.Context(), cache.GetClient(), []string{key}, windowMillis, policy.Limit, uuid.NewString()).Int64Slice()
+		if err != nil || len(result) != 3 {
+			log.Printf("Rate limit check failed for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		allowed, remaining, resetMillis := result[0] == 1, result[1], result[2]
+		resetSeconds := strconv.FormatInt((resetMillis+999)/1000, 10)
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("RateLimit-Reset", resetSeconds)
+
+		if !allowed {
+			c.Header("Retry-After", resetSeconds)
+			c.Error(common.ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// failureWindowScript drops entries older than the window from a sliding
+// window and returns how many are left and the milliseconds until the oldest
+// one leaves it. With ARGV[2] set it first adds an entry for this request.
+var failureWindowScript = redis.NewScript(`
+local now = redis.call('TIME')
+local now_ms = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local window = tonumber(ARGV[1])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now_ms - window)
+if ARGV[2] ~= '' then
+	redis.call('ZADD', KEYS[1], now_ms, ARGV[2])
+	redis.call('PEXPIRE', KEYS[1], window)
+end
+
+local reset = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now_ms
+end
+
+return {redis.call('ZCARD', KEYS[1]), reset}
+`)
+
+// AuthFailureLimit throttles clients by IP once Limit of their requests were
+// rejected with 401 within the window. It runs before the JWT middleware,
+// which aborts such requests before the per-user limit is reached, so
+// requests with missing or invalid credentials cannot be sent without limit.
+// Requests that authenticate do not count, so users sharing an IP are not
+// limited by each other.
+func AuthFailureLimit(cache *db.RedisClient, allowlist []*net.IPNet, policy RateLimitPolicy) gin.HandlerFunc {
+	windowMillis := policy.Window.Milliseconds()
+
+	return func(c *gin.Context) {
+		if isAllowlisted(allowlist, c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		key := rateLimitIPKey(c, policy.Name)
+		client := cache.GetClient()
+
+		result, err := failureWindowScript.Run(c.Request.Context(), client, []string{key}, windowMillis, "").Int64Slice()
+		if err != nil || len(result) != 2 {
+			log.Printf("Auth failure limit check failed for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		if result[0] >= int64(policy.Limit) {
+			c.Header("Retry-After", strconv.FormatInt((result[1]+999)/1000, 10))
+			c.Error(common.ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		// Render errors now, since the status is only known once they are
+		writeErrors(c)
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			if err := failureWindowScript.Run(c.Request.Context(), client, []string{key}, windowMillis, uuid.NewString()).Err(); err != nil {
+				log.Printf("Failed to record auth failure for %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// rateLimitIPKey is the counter of a policy for the client IP of a request.
+// The IP only comes from X-Forwarded-For when the peer is a trusted proxy.
+func rateLimitIPKey(c *gin.Context, name string) string {
+	return "ratelimit:" + name + ":ip:" + c.ClientIP()
+}
+
+// ParseAllowlist parses a comma-separated list of IP addresses and CIDR
+// ranges, skipping invalid entries
+func ParseAllowlist(list string) []*net.IPNet {
+	var allowlist []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid rate limit allowlist entry %q", entry)
+			continue
+		}
+		allowlist = append(allowlist, ipNet)
+	}
+	return allowlist
+}
+
+// isAllowlisted reports whether an IP address is in the allowlist
+func isAllowlisted(allowlist []*net.IPNet, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range allowlist {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps client supplied request IDs before they are stored
+const maxRequestIDLength = 64
+
+// RequestID assigns every request an ID, reusing a sane X-Request-ID from the
+// client or proxy, and exposes it in the response and to the audit log
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength || !isPrintableASCII(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), entity.AuditRequestIDKey, requestID))
+
+		c.Next()
+	}
+}
+
+// isPrintableASCII reports whether value only has printable ASCII characters
+func isPrintableASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 33 || value[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
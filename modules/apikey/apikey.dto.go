@@ -0,0 +1,48 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateAPIKeyRequest represents the request to create a personal access token or service account key
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100" example:"CI pipeline"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=tasks:read tasks:write projects:read projects:write users:read users:write audit:read" example:"tasks:read,tasks:write"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" binding:"omitempty,min=1,max=365" example:"90"` // Omit for a key that never expires
+}
+
+// APIKeyResponse represents an API key in API responses. The key itself is never returned after creation.
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name       string     `json:"name" example:"CI pipeline"`
+	Kind       string     `json:"kind" example:"personal"`
+	Prefix     string     `json:"prefix" example:"dtt_pat_Xb3kQz"`
+	Scopes     []string   `json:"scopes" example:"tasks:read,tasks:write"`
+	ExpiresAt  *time.Time `json:"expires_at" example:"2025-10-17T10:30:00Z"`
+	LastUsedAt *time.Time `json:"last_used_at" example:"2025-07-19T10:30:00Z"`
+	LastUsedIP string     `json:"last_used_ip" example:"203.0.113.7"`
+	RevokedAt  *time.Time `json:"revoked_at" example:"2025-07-20T10:30:00Z"`
+	CreatedAt  time.Time  `json:"created_at" example:"2025-07-19T10:30:00Z"`
+}
+
+// CreateAPIKeyResponse represents a newly created key, the only time the key is shown
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key" example:"dtt_pat_Xb3kQz..."`
+}
+
+// CreateServiceAccountRequest represents the request to create a service account
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=100" example:"Deploy bot"`
+	Role string `json:"role" binding:"required,oneof=user manager" example:"user"`
+}
+
+// ServiceAccountResponse represents a service account in API responses
+type ServiceAccountResponse struct {
+	ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name      string    `json:"name" example:"Deploy bot"`
+	Role      string    `json:"role" example:"user"`
+	CreatedAt time.Time `json:"created_at" example:"2025-07-19T10:30:00Z"`
+}
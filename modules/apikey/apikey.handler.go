@@ -0,0 +1,281 @@
+package apikey
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+)
+
+// Handler handles HTTP requests for API key operations
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new API key handler instance
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// CreatePersonalToken handles personal access token creation requests
+//
+//	@Summary		Create a personal access token
+//	@Description	Create a scoped token that authenticates as the current user. The token is only shown in this response.
+//	@Tags			API Keys
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body		CreateAPIKeyRequest								true	"Token name, scopes and expiry"
+//	@Success		201		{object}	common.BaseResponse{data=CreateAPIKeyResponse}	"Token created successfully"
+//	@Failure		401		{object}	common.Problem									"Unauthorized"
+//	@Failure		403		{object}	common.Problem									"Forbidden - API keys cannot manage API keys"
+//	@Failure		422		{object}	common.Problem									"Validation failed"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/user/me/tokens [post]
+func (h *Handler) CreatePersonalToken(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.CreatePersonalToken(c.Request.Context(), principal.UserID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.CreatedResponse(c, response, "Token created successfully. Copy it now, it will not be shown again.")
+}
+
+// ListPersonalTokens handles requests to list the current user's personal access tokens
+//
+//	@Summary		List personal access tokens
+//	@Description	List the current user's personal access tokens, including revoked and expired ones
+//	@Tags			API Keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=[]APIKeyResponse}	"Tokens retrieved successfully"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/user/me/tokens [get]
+func (h *Handler) ListPersonalTokens(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.ListPersonalTokens(c.Request.Context(), principal.UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Tokens retrieved successfully")
+}
+
+// RevokePersonalToken handles personal access token revocation requests
+//
+//	@Summary		Revoke a personal access token
+//	@Description	Revoke one of the current user's personal access tokens
+//	@Tags			API Keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string				true	"Token ID"
+//	@Success		200	{object}	common.BaseResponse	"Token revoked successfully"
+//	@Failure		400	{object}	common.Problem		"Invalid token ID"
+//	@Failure		401	{object}	common.Problem		"Unauthorized"
+//	@Failure		404	{object}	common.Problem		"Token not found"
+//	@Failure		500	{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/user/me/tokens/{id} [delete]
+func (h *Handler) RevokePersonalToken(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	if err := h.service.RevokePersonalToken(c.Request.Context(), principal.UserID, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "Token revoked successfully")
+}
+
+// CreateServiceAccount handles service account creation requests
+//
+//	@Summary		Create a service account
+//	@Description	Create a non-human account that authenticates with API keys (requires the service_account.manage permission)
+//	@Tags			API Keys
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body		CreateServiceAccountRequest							true	"Service account name and role"
+//	@Success		201		{object}	common.BaseResponse{data=ServiceAccountResponse}	"Service account created successfully"
+//	@Failure		401		{object}	common.Problem										"Unauthorized"
+//	@Failure		403		{object}	common.Problem										"Forbidden"
+//	@Failure		422		{object}	common.Problem										"Validation failed"
+//	@Failure		500		{object}	common.Problem										"Internal server error"
+//	@Router			/api/v1/service-accounts [post]
+func (h *Handler) CreateServiceAccount(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.CreateServiceAccount(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.CreatedResponse(c, response, "Service account created successfully")
+}
+
+// ListServiceAccounts handles requests to list service accounts
+//
+//	@Summary		List service accounts
+//	@Description	List all service accounts (requires the service_account.manage permission)
+//	@Tags			API Keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=[]ServiceAccountResponse}	"Service accounts retrieved successfully"
+//	@Failure		401	{object}	common.Problem										"Unauthorized"
+//	@Failure		403	{object}	common.Problem										"Forbidden"
+//	@Failure		500	{object}	common.Problem										"Internal server error"
+//	@Router			/api/v1/service-accounts [get]
+func (h *Handler) ListServiceAccounts(c *gin.Context) {
+	response, err := h.service.ListServiceAccounts(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Service accounts retrieved successfully")
+}
+
+// CreateServiceKey handles service account API key creation requests
+//
+//	@Summary		Create a service account API key
+//	@Description	Create a scoped API key for a service account (requires the service_account.manage permission). The key is only shown in this response.
+//	@Tags			API Keys
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string											true	"Service account ID"
+//	@Param			payload	body		CreateAPIKeyRequest								true	"Key name, scopes and expiry"
+//	@Success		201		{object}	common.BaseResponse{data=CreateAPIKeyResponse}	"API key created successfully"
+//	@Failure		400		{object}	common.Problem									"Invalid service account ID"
+//	@Failure		401		{object}	common.Problem									"Unauthorized"
+//	@Failure		403		{object}	common.Problem									"Forbidden"
+//	@Failure		404		{object}	common.Problem									"Service account not found"
+//	@Failure		422		{object}	common.Problem									"Validation failed"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/service-accounts/{id}/keys [post]
+func (h *Handler) CreateServiceKey(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.CreateServiceKey(c.Request.Context(), accountID, principal.UserID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.CreatedResponse(c, response, "API key created successfully. Copy it now, it will not be shown again.")
+}
+
+// ListServiceKeys handles requests to list the API keys of a service account
+//
+//	@Summary		List service account API keys
+//	@Description	List the API keys of a service account, including revoked and expired ones (requires the service_account.manage permission)
+//	@Tags			API Keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string										true	"Service account ID"
+//	@Success		200	{object}	common.BaseResponse{data=[]APIKeyResponse}	"API keys retrieved successfully"
+//	@Failure		400	{object}	common.Problem								"Invalid service account ID"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		403	{object}	common.Problem								"Forbidden"
+//	@Failure		404	{object}	common.Problem								"Service account not found"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/service-accounts/{id}/keys [get]
+func (h *Handler) ListServiceKeys(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	response, err := h.service.ListServiceKeys(c.Request.Context(), accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "API keys retrieved successfully")
+}
+
+// RevokeServiceKey handles service account API key revocation requests
+//
+//	@Summary		Revoke a service account API key
+//	@Description	Revoke an API key of a service account (requires the service_account.manage permission)
+//	@Tags			API Keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string				true	"Service account ID"
+//	@Param			keyId	path		string				true	"API key ID"
+//	@Success		200		{object}	common.BaseResponse	"API key revoked successfully"
+//	@Failure		400		{object}	common.Problem		"Invalid ID"
+//	@Failure		401		{object}	common.Problem		"Unauthorized"
+//	@Failure		403		{object}	common.Problem		"Forbidden"
+//	@Failure		404		{object}	common.Problem		"Service account or API key not found"
+//	@Failure		500		{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/service-accounts/{id}/keys/{keyId} [delete]
+func (h *Handler) RevokeServiceKey(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	if err := h.service.RevokeServiceKey(c.Request.Context(), accountID, keyID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "API key revoked successfully")
+}
@@ -0,0 +1,115 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often last-used tracking writes for a busy key
+const lastUsedResolution = time.Minute
+
+// Repository defines the interface for API key data operations
+type Repository interface {
+	Create(ctx context.Context, key *entity.APIKey) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*entity.APIKey, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.APIKey, error)
+	Update(ctx context.Context, key *entity.APIKey) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, ip string, now time.Time) error
+	CreateServiceAccount(ctx context.Context, account *entity.User) error
+	GetServiceAccount(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	ListServiceAccounts(ctx context.Context) ([]entity.User, error)
+}
+
+// repository implements the Repository interface
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new API key repository instance
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// Create creates a new API key
+func (r *repository) Create(ctx context.Context, key *entity.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// GetByID retrieves an API key by ID
+func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*entity.APIKey, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+// GetByHash retrieves an API key by the hash of the key
+func (r *repository) GetByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	return r.first(ctx, "key_hash = ?", hash)
+}
+
+// first retrieves the first API key matching a condition, or nil if none does
+func (r *repository) first(ctx context.Context, query string, args ...interface{}) (*entity.APIKey, error) {
+	var key entity.APIKey
+	err := r.db.WithContext(ctx).Where(query, args...).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUser retrieves the API keys of a user, newest first
+func (r *repository) ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Update updates an existing API key
+func (r *repository) Update(ctx context.Context, key *entity.APIKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+// TouchLastUsed records when and from where a key was used. It skips hooks
+// and writes at most once per lastUsedResolution.
+func (r *repository) TouchLastUsed(ctx context.Context, id uuid.UUID, ip string, now time.Time) error {
+	return r.db.WithContext(ctx).Model(&entity.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution)).
+		UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error
+}
+
+// CreateServiceAccount creates the user behind a service account
+func (r *repository) CreateServiceAccount(ctx context.Context, account *entity.User) error {
+	return r.db.WithContext(ctx).Create(account).Error
+}
+
+// GetServiceAccount retrieves a service account by ID, or nil if there is none
+func (r *repository) GetServiceAccount(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var account entity.User
+	err := r.db.WithContext(ctx).Where("id = ? AND service_account", id).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListServiceAccounts retrieves all service accounts
+func (r *repository) ListServiceAccounts(ctx context.Context) ([]entity.User, error) {
+	var accounts []entity.User
+	err := r.db.WithContext(ctx).Where("service_account").Order("created_at DESC").Find(&accounts).Error
+	return accounts, err
+}
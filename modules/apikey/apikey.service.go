@@ -0,0 +1,259 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/user"
+	"github.com/mnizarzr/dot-test/utils"
+)
+
+// Service defines the interface for API key business logic
+type Service interface {
+	Authenticate(ctx context.Context, key, clientIP string) (*entity.User, *entity.APIKey, error)
+	CreatePersonalToken(ctx context.Context, userID uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	ListPersonalTokens(ctx context.Context, userID uuid.UUID) ([]APIKeyResponse, error)
+	RevokePersonalToken(ctx context.Context, userID, id uuid.UUID) error
+	CreateServiceAccount(ctx context.Context, req CreateServiceAccountRequest) (*ServiceAccountResponse, error)
+	ListServiceAccounts(ctx context.Context) ([]ServiceAccountResponse, error)
+	CreateServiceKey(ctx context.Context, accountID, createdBy uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	ListServiceKeys(ctx context.Context, accountID uuid.UUID) ([]APIKeyResponse, error)
+	RevokeServiceKey(ctx context.Context, accountID, id uuid.UUID) error
+}
+
+// service implements the Service interface
+type service struct {
+	repo     Repository
+	userRepo user.Repository
+}
+
+// NewService creates a new API key service instance
+func NewService(repo Repository, userRepo user.Repository) Service {
+	return &service{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// Authenticate resolves an active API key to its owner and records its use
+func (s *service) Authenticate(ctx context.Context, key, clientIP string) (*entity.User, *entity.APIKey, error) {
+	apiKey, err := s.repo.GetByHash(ctx, utils.HashAPIKey(key))
+	if err != nil {
+		return nil, nil, common.ErrFailedToRetrieveAPIKey
+	}
+
+	now := time.Now()
+	if apiKey == nil || !apiKey.Active(now) {
+		return nil, nil, common.ErrInvalidAPIKey
+	}
+
+	owner, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, nil, common.ErrFailedToRetrieveUser
+	}
+	if owner == nil {
+		return nil, nil, common.ErrInvalidAPIKey
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, apiKey.ID, clientIP, now); err != nil {
+		log.Printf("Failed to record use of API key %s: %v", apiKey.ID, err)
+	}
+
+	return owner, apiKey, nil
+}
+
+// CreatePersonalToken creates a personal access token for a user
+func (s *service) CreatePersonalToken(ctx context.Context, userID uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	return s.createKey(ctx, userID, userID, entity.APIKeyKindPersonal, utils.PersonalTokenPrefix, req)
+}
+
+// ListPersonalTokens lists the personal access tokens of a user
+func (s *service) ListPersonalTokens(ctx context.Context, userID uuid.UUID) ([]APIKeyResponse, error) {
+	return s.listKeys(ctx, userID)
+}
+
+// RevokePersonalToken revokes a personal access token owned by the user
+func (s *service) RevokePersonalToken(ctx context.Context, userID, id uuid.UUID) error {
+	return s.revokeKey(ctx, userID, id)
+}
+
+// CreateServiceAccount creates a service account. It has no password, so it
+// can only authenticate with its API keys.
+func (s *service) CreateServiceAccount(ctx context.Context, req CreateServiceAccountRequest) (*ServiceAccountResponse, error) {
+	now := time.Now()
+	id := uuid.New()
+	account := &entity.User{
+		ID:             id,
+		Name:           req.Name,
+		Email:          fmt.Sprintf("svc-%s@service-accounts.invalid", id),
+		Role:           req.Role,
+		ServiceAccount: true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.repo.CreateServiceAccount(ctx, account); err != nil {
+		return nil, common.ErrFailedToCreateServiceAccount
+	}
+
+	return toServiceAccountResponse(account), nil
+}
+
+// ListServiceAccounts lists all service accounts
+func (s *service) ListServiceAccounts(ctx context.Context) ([]ServiceAccountResponse, error) {
+	accounts, err := s.repo.ListServiceAccounts(ctx)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveServiceAccounts
+	}
+
+	responses := make([]ServiceAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		responses = append(responses, *toServiceAccountResponse(&accounts[i]))
+	}
+
+	return responses, nil
+}
+
+// CreateServiceKey creates an API key for a service account
+func (s *service) CreateServiceKey(ctx context.Context, accountID, createdBy uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	if err := s.requireServiceAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	return s.createKey(ctx, accountID, createdBy, entity.APIKeyKindService, utils.ServiceAPIKeyPrefix, req)
+}
+
+// ListServiceKeys lists the API keys of a service account
+func (s *service) ListServiceKeys(ctx context.Context, accountID uuid.UUID) ([]APIKeyResponse, error) {
+	if err := s.requireServiceAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	return s.listKeys(ctx, accountID)
+}
+
+// RevokeServiceKey revokes an API key of a service account
+func (s *service) RevokeServiceKey(ctx context.Context, accountID, id uuid.UUID) error {
+	if err := s.requireServiceAccount(ctx, accountID); err != nil {
+		return err
+	}
+
+	return s.revokeKey(ctx, accountID, id)
+}
+
+// createKey generates and stores a key, returning it in plain text this one time
+func (s *service) createKey(ctx context.Context, ownerID, createdBy uuid.UUID, kind, prefix string, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	key, visiblePrefix, hash, err := utils.GenerateAPIKey(prefix)
+	if err != nil {
+		return nil, common.ErrFailedToCreateAPIKey
+	}
+
+	now := time.Now()
+	apiKey := &entity.APIKey{
+		ID:        uuid.New(),
+		UserID:    ownerID,
+		Name:      req.Name,
+		Kind:      kind,
+		Prefix:    visiblePrefix,
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		CreatedBy: &createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.Create(ctx, apiKey); err != nil {
+		return nil, common.ErrFailedToCreateAPIKey
+	}
+
+	return &CreateAPIKeyResponse{
+		APIKeyResponse: *toAPIKeyResponse(apiKey),
+		Key:            key,
+	}, nil
+}
+
+// listKeys lists the keys owned by a user
+func (s *service) listKeys(ctx context.Context, ownerID uuid.UUID) ([]APIKeyResponse, error) {
+	keys, err := s.repo.ListByUser(ctx, ownerID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveAPIKey
+	}
+
+	responses := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		responses = append(responses, *toAPIKeyResponse(&keys[i]))
+	}
+
+	return responses, nil
+}
+
+// revokeKey revokes a key, treating keys of other owners as not found
+func (s *service) revokeKey(ctx context.Context, ownerID, id uuid.UUID) error {
+	apiKey, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return common.ErrFailedToRetrieveAPIKey
+	}
+	if apiKey == nil || apiKey.UserID != ownerID {
+		return common.ErrAPIKeyNotFound
+	}
+	if apiKey.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	apiKey.RevokedAt = &now
+	apiKey.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, apiKey); err != nil {
+		return common.ErrFailedToRevokeAPIKey
+	}
+
+	return nil
+}
+
+// requireServiceAccount checks that an ID belongs to a service account
+func (s *service) requireServiceAccount(ctx context.Context, accountID uuid.UUID) error {
+	account, err := s.repo.GetServiceAccount(ctx, accountID)
+	if err != nil {
+		return common.ErrFailedToRetrieveServiceAccounts
+	}
+	if account == nil {
+		return common.ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+// toAPIKeyResponse converts an API key entity to its response
+func toAPIKeyResponse(apiKey *entity.APIKey) *APIKeyResponse {
+	return &APIKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Kind:       apiKey.Kind,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		LastUsedIP: apiKey.LastUsedIP,
+		RevokedAt:  apiKey.RevokedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+// toServiceAccountResponse converts a service account user to its response
+func toServiceAccountResponse(account *entity.User) *ServiceAccountResponse {
+	return &ServiceAccountResponse{
+		ID:        account.ID,
+		Name:      account.Name,
+		Role:      account.Role,
+		CreatedAt: account.CreatedAt,
+	}
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page size defaults for audit log listings
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// AuditLogResponse represents an audit log entry in API responses
+type AuditLogResponse struct {
+	ID             uuid.UUID       `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID         *uuid.UUID      `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440001"`
+	Action         string          `json:"action" example:"UPDATE"`
+	TargetResource string          `json:"target_resource" example:"tasks"`
+	TargetID       *uuid.UUID      `json:"target_id" example:"550e8400-e29b-41d4-a716-446655440002"`
+	Details        json.RawMessage `json:"details" swaggertype:"object"`
+	CreatedAt      time.Time       `json:"created_at" example:"2025-07-19T10:30:00Z"`
+	Seq            *int64          `json:"seq" example:"42"`
+	PrevHash       string          `json:"prev_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Hash           string          `json:"hash" example:"60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"`
+}
+
+// AuditLogListResponse represents a page of audit log entries. NextCursor is
+// empty on the last page.
+type AuditLogListResponse struct {
+	AuditLogs  []AuditLogResponse `json:"audit_logs"`
+	NextCursor string             `json:"next_cursor" example:"MjAyNS0wNy0xOVQxMDozMDowMFp8NTUwZTg0MDA"`
+	Limit      int                `json:"limit" example:"20"`
+}
+
+// PageRequest represents keyset pagination parameters
+type PageRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SetDefaults sets default values for pagination
+func (p *PageRequest) SetDefaults() {
+	if p.Limit == 0 {
+		p.Limit = DefaultLimit
+	}
+}
+
+// AuditLogFilterRequest represents audit log filtering parameters
+type AuditLogFilterRequest struct {
+	UserID         *uuid.UUID `form:"user_id,omitempty"`
+	Action         *string    `form:"action,omitempty"`
+	TargetResource *string    `form:"target_resource,omitempty"`
+	TargetID       *uuid.UUID `form:"target_id,omitempty"`
+	IPAddress      *string    `form:"ip_address,omitempty" binding:"omitempty,ip"`
+	From           *time.Time `form:"from,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
+	To             *time.Time `form:"to,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
+	PageRequest
+
+	// Actions limits the entries to a set of actions; it is not a query parameter
+	Actions []string `form:"-"`
+}
+
+// VerifyResult reports the outcome of walking the audit hash chain
+type VerifyResult struct {
+	Checked  int64
+	FirstSeq int64
+	LastSeq  int64
+	Broken   *BrokenLink
+}
+
+// BrokenLink describes the first entry at which the chain does not verify
+type BrokenLink struct {
+	Seq    int64
+	ID     uuid.UUID
+	Reason string
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+)
+
+// Handler handles HTTP requests for audit log operations
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new audit log handler instance
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetAuditLogs handles audit log search requests
+//
+//	@Summary		Search audit logs
+//	@Description	Search audit logs newest first with keyset pagination (requires the audit.read permission)
+//	@Tags			Audit
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			user_id			query		string											false	"Filter by acting user ID"
+//	@Param			action			query		string											false	"Filter by action (CREATE, UPDATE, DELETE, ...)"
+//	@Param			target_resource	query		string											false	"Filter by target resource (tasks, projects, users, ...)"
+//	@Param			target_id		query		string											false	"Filter by target ID"
+//	@Param			ip_address		query		string											false	"Filter by client IP address"
+//	@Param			from			query		string											false	"Only entries at or after this time (RFC 3339)"
+//	@Param			to				query		string											false	"Only entries before this time (RFC 3339)"
+//	@Param			cursor			query		string											false	"Cursor from the previous page"
+//	@Param			limit			query		int												false	"Page size (default: 20, max: 100)"
+//	@Success		200				{object}	common.BaseResponse{data=AuditLogListResponse}	"Audit logs retrieved successfully"
+//	@Failure		400				{object}	common.Problem									"Bad request"
+//	@Failure		401				{object}	common.Problem									"Unauthorized"
+//	@Failure		403				{object}	common.Problem									"Forbidden"
+//	@Failure		500				{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/audit-logs [get]
+func (h *Handler) GetAuditLogs(c *gin.Context) {
+	var filters AuditLogFilterRequest
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.GetAuditLogs(c.Request.Context(), filters)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Audit logs retrieved successfully")
+}
+
+// GetAuditLog handles get audit log by ID requests
+//
+//	@Summary		Get audit log by ID
+//	@Description	Get a single audit log entry (requires the audit.read permission)
+//	@Tags			Audit
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id	path		string										true	"Audit log ID"
+//	@Success		200	{object}	common.BaseResponse{data=AuditLogResponse}	"Audit log retrieved successfully"
+//	@Failure		400	{object}	common.Problem								"Bad request"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		403	{object}	common.Problem								"Forbidden"
+//	@Failure		404	{object}	common.Problem								"Audit log not found"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/audit-logs/{id} [get]
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	auditLogID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	auditLog, err := h.service.GetAuditLog(c.Request.Context(), auditLogID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, auditLog, "Audit log retrieved successfully")
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+)
+
+// cursorTimeLayout matches the microsecond precision of the created_at column
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// Cursor is the position after which the next page starts
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Repository defines the interface for audit log data operations
+type Repository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.AuditLog, error)
+	List(ctx context.Context, filters AuditLogFilterRequest, after *Cursor) ([]*entity.AuditLog, error)
+	GetBySeq(ctx context.Context, seq int64) (*entity.AuditLog, error)
+	ListChain(ctx context.Context, afterSeq int64, from, to *time.Time, limit int) ([]*entity.AuditLog, error)
+	GetArchiveByLastSeq(ctx context.Context, seq int64) (*entity.AuditArchive, error)
+	ListPartitions(ctx context.Context) ([]string, error)
+	CreatePartition(ctx context.Context, name string, start, end time.Time) error
+	ListPartitionRows(ctx context.Context, partition string, after *Cursor, limit int) ([]*entity.AuditLog, error)
+	DropPartition(ctx context.Context, partition string, archive *entity.AuditArchive) error
+}
+
+// repository implements the Repository interface
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new audit log repository instance
+func NewRepository(database *gorm.DB) Repository {
+	return &repository{
+		db: database,
+	}
+}
+
+// GetByID retrieves an audit log entry by ID
+func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*entity.AuditLog, error) {
+	var auditLog entity.AuditLog
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&auditLog).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &auditLog, nil
+}
+
+// List retrieves audit log entries newest first, starting after the cursor.
+// One extra row is fetched so the caller can tell whether another page exists.
+func (r *repository) List(ctx context.Context, filters AuditLogFilterRequest, after *Cursor) ([]*entity.AuditLog, error) {
+	var auditLogs []*entity.AuditLog
+
+	query := r.db.WithContext(ctx).Model(&entity.AuditLog{})
+
+	if filters.UserID != nil {
+		query = query.Where("user_id = ?", *filters.UserID)
+	}
+	if filters.Action != nil {
+		query = query.Where("action = ?", *filters.Action)
+	}
+	if len(filters.Actions) > 0 {
+		query = query.Where("action IN ?", filters.Actions)
+	}
+	if filters.TargetResource != nil {
+		query = query.Where("target_resource = ?", *filters.TargetResource)
+	}
+	if filters.TargetID != nil {
+		query = query.Where("target_id = ?", *filters.TargetID)
+	}
+	if filters.IPAddress != nil {
+		query = query.Where("details->>'ip_address' = ?", *filters.IPAddress)
+	}
+	if filters.From != nil {
+		query = query.Where("created_at >= ?::timestamp", filters.From.UTC().Format(cursorTimeLayout))
+	}
+	if filters.To != nil {
+		query = query.Where("created_at < ?::timestamp", filters.To.UTC().Format(cursorTimeLayout))
+	}
+	if after != nil {
+		query = query.Where("(created_at, id) < (?::timestamp, ?)", after.CreatedAt.Format(cursorTimeLayout), after.ID)
+	}
+
+	err := query.Order("created_at DESC, id DESC").Limit(filters.Limit + 1).Find(&auditLogs).Error
+	return auditLogs, err
+}
+
+// GetBySeq retrieves the audit log entry at a position in the hash chain
+func (r *repository) GetBySeq(ctx context.Context, seq int64) (*entity.AuditLog, error) {
+	var auditLog entity.AuditLog
+	err := r.db.WithContext(ctx).Where("seq = ?", seq).First(&auditLog).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &auditLog, nil
+}
+
+// ListChain retrieves chained entries in chain order, starting after a position
+func (r *repository) ListChain(ctx context.Context, afterSeq int64, from, to *time.Time, limit int) ([]*entity.AuditLog, error) {
+	var auditLogs []*entity.AuditLog
+
+	query := r.db.WithContext(ctx).Where("seq > ?", afterSeq)
+	if from != nil {
+		query = query.Where("created_at >= ?::timestamp", from.UTC().Format(cursorTimeLayout))
+	}
+	if to != nil {
+		query = query.Where("created_at < ?::timestamp", to.UTC().Format(cursorTimeLayout))
+	}
+
+	err := query.Order("seq ASC, id ASC").Limit(limit).Find(&auditLogs).Error
+	return auditLogs, err
+}
+
+// GetArchiveByLastSeq retrieves the archive whose last chained entry is at the given position
+func (r *repository) GetArchiveByLastSeq(ctx context.Context, seq int64) (*entity.AuditArchive, error) {
+	var archive entity.AuditArchive
+	err := r.db.WithContext(ctx).Where("last_seq = ?", seq).First(&archive).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// ListPartitions returns the names of all audit_logs partitions
+func (r *repository) ListPartitions(ctx context.Context) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ?
+		ORDER BY c.relname`, (&entity.AuditLog{}).TableName()).Scan(&names).Error
+	return names, err
+}
+
+// CreatePartition creates the partition for [start, end) if it does not exist yet
+func (r *repository) CreatePartition(ctx context.Context, name string, start, end time.Time) error {
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %q PARTITION OF audit_logs FOR VALUES FROM ('%s') TO ('%s')",
+		name, start.Format(cursorTimeLayout), end.Format(cursorTimeLayout))
+	return r.db.WithContext(ctx).Exec(sql).Error
+}
+
+// ListPartitionRows retrieves the rows of one partition oldest first, starting after the cursor
+func (r *repository) ListPartitionRows(ctx context.Context, partition string, after *Cursor, limit int) ([]*entity.AuditLog, error) {
+	var auditLogs []*entity.AuditLog
+
+	query := r.db.WithContext(ctx).Table(partition)
+	if after != nil {
+		query = query.Where("(created_at, id) > (?::timestamp, ?)", after.CreatedAt.Format(cursorTimeLayout), after.ID)
+	}
+
+	err := query.Order("created_at ASC, id ASC").Limit(limit).Find(&auditLogs).Error
+	return auditLogs, err
+}
+
+// DropPartition records the archive and drops the partition in one transaction
+func (r *repository) DropPartition(ctx context.Context, partition string, archive *entity.AuditArchive) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(archive).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("DROP TABLE %q", partition)).Error
+	})
+}
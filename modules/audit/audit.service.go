@@ -0,0 +1,469 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// Service defines the interface for audit log business logic
+type Service interface {
+	GetAuditLog(ctx context.Context, id uuid.UUID) (*AuditLogResponse, error)
+	GetAuditLogs(ctx context.Context, filters AuditLogFilterRequest) (*AuditLogListResponse, error)
+	GetHistory(ctx context.Context, resource string, targetID uuid.UUID, page PageRequest, withClientMetadata bool) (*AuditLogListResponse, error)
+	VerifyChain(ctx context.Context, from, to *time.Time) (*VerifyResult, error)
+	EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) error
+	ArchivePartitions(ctx context.Context, now time.Time, retentionMonths int, dir string, dryRun bool) ([]entity.AuditArchive, error)
+}
+
+// Batch sizes used while walking large parts of the audit log
+const (
+	verifyBatchSize  = 1000
+	archiveBatchSize = 1000
+)
+
+// partitionNameFormat names monthly partitions, e.g. audit_logs_y2025m07
+const partitionNameFormat = "audit_logs_y%04dm%02d"
+
+// service implements the Service interface
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new audit log service instance
+func NewService(repo Repository) Service {
+	return &service{
+		repo: repo,
+	}
+}
+
+// GetAuditLog retrieves a single audit log entry
+func (s *service) GetAuditLog(ctx context.Context, id uuid.UUID) (*AuditLogResponse, error) {
+	auditLog, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveAuditLogs
+	}
+	if auditLog == nil {
+		return nil, common.ErrAuditLogNotFound
+	}
+
+	return s.entityToResponse(auditLog), nil
+}
+
+// GetAuditLogs retrieves audit log entries matching the filters, newest first
+func (s *service) GetAuditLogs(ctx context.Context, filters AuditLogFilterRequest) (*AuditLogListResponse, error) {
+	filters.SetDefaults()
+
+	after, err := decodeCursor(filters.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLogs, err := s.repo.List(ctx, filters, after)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveAuditLogs
+	}
+
+	return s.toListResponse(auditLogs, filters.Limit), nil
+}
+
+// historyActions are the entries of an entity's history: its changes, not
+// who read it or was denied access
+var historyActions = []string{entity.AuditActionCreate, entity.AuditActionUpdate, entity.AuditActionDelete}
+
+// clientMetadataFields are the details that identify the client of another
+// actor, shown only to users who may read the audit log
+var clientMetadataFields = []string{"ip_address", "user_agent", "request_id"}
+
+// GetHistory retrieves the changes made to a single entity. Callers are
+// responsible for checking that the user may view the entity, and only ask
+// for client metadata for users with the audit.read permission.
+func (s *service) GetHistory(ctx context.Context, resource string, targetID uuid.UUID, page PageRequest, withClientMetadata bool) (*AuditLogListResponse, error) {
+	response, err := s.GetAuditLogs(ctx, AuditLogFilterRequest{
+		TargetResource: &resource,
+		TargetID:       &targetID,
+		PageRequest:    page,
+		Actions:        historyActions,
+	})
+	if err != nil || withClientMetadata {
+		return response, err
+	}
+
+	for i := range response.AuditLogs {
+		response.AuditLogs[i].Details = withoutClientMetadata(response.AuditLogs[i].Details)
+	}
+	return response, nil
+}
+
+// CanReadClientMetadata checks if a principal may see the client metadata of
+// history entries: it needs what the audit log endpoints require
+func CanReadClientMetadata(ctx context.Context, permissions *policy.Engine, principal *common.Principal) bool {
+	if principal.IsAPIKey() && !principal.HasScope("audit:read") {
+		return false
+	}
+	return permissions.Can(ctx, principal.Role, policy.AuditRead)
+}
+
+// withoutClientMetadata removes the client metadata fields from the details
+// of an entry
+func withoutClientMetadata(details json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(details, &fields); err != nil {
+		// Details are always an object; hide anything else rather than leak it
+		return json.RawMessage("{}")
+	}
+	for _, field := range clientMetadataFields {
+		delete(fields, field)
+	}
+
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return stripped
+}
+
+// VerifyChain walks the hash chain in order and stops at the first entry that
+// was modified, duplicated, or whose predecessor was modified or deleted.
+// Entries written before the chain existed are skipped.
+func (s *service) VerifyChain(ctx context.Context, from, to *time.Time) (*VerifyResult, error) {
+	result := &VerifyResult{}
+
+	var expectedSeq int64
+	var expectedPrevHash string
+	var lastID uuid.UUID
+
+	for {
+		// Each batch after the first starts again at the last verified seq, so
+		// a duplicate of it is seen even when it falls into the next batch.
+		// seq is only unique together with created_at, the partition key.
+		afterSeq := result.LastSeq
+		if result.Checked > 0 {
+			afterSeq--
+		}
+		batch, err := s.repo.ListChain(ctx, afterSeq, from, to, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		progressed := false
+		for _, auditLog := range batch {
+			if result.Checked > 0 && auditLog.ID == lastID {
+				continue
+			}
+			progressed = true
+			seq := *auditLog.Seq
+
+			if result.Checked == 0 {
+				result.FirstSeq = seq
+				expectedSeq = seq
+				if seq > 1 {
+					// Link the first entry in range to the entry before it
+					previous, err := s.repo.GetBySeq(ctx, seq-1)
+					if err != nil {
+						return nil, err
+					}
+					if previous != nil {
+						expectedPrevHash = previous.Hash
+					} else {
+						// The previous entry may have been archived with its partition
+						archive, err := s.repo.GetArchiveByLastSeq(ctx, seq-1)
+						if err != nil {
+							return nil, err
+						}
+						if archive == nil {
+							result.Broken = &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: fmt.Sprintf("previous entry %d is missing", seq-1)}
+							return result, nil
+						}
+						expectedPrevHash = archive.LastHash
+					}
+				}
+			}
+
+			if broken := verifyLink(auditLog, expectedSeq, expectedPrevHash); broken != nil {
+				result.Broken = broken
+				return result, nil
+			}
+
+			result.Checked++
+			result.LastSeq = seq
+			expectedSeq = seq + 1
+			expectedPrevHash = auditLog.Hash
+			lastID = auditLog.ID
+		}
+		if !progressed {
+			return result, nil
+		}
+	}
+}
+
+// verifyLink checks one entry against the position and hash the chain expects
+func verifyLink(auditLog *entity.AuditLog, expectedSeq int64, expectedPrevHash string) *BrokenLink {
+	seq := *auditLog.Seq
+
+	if seq < expectedSeq {
+		return &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: fmt.Sprintf("entry %d appears more than once", seq)}
+	}
+	if seq != expectedSeq {
+		return &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: fmt.Sprintf("entries %d to %d are missing", expectedSeq, seq-1)}
+	}
+	if auditLog.PrevHash != expectedPrevHash {
+		return &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: "prev_hash does not match the hash of the previous entry"}
+	}
+
+	hash, err := entity.ComputeAuditHash(auditLog)
+	if err != nil {
+		return &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: "details are not valid JSON"}
+	}
+	if hash != auditLog.Hash {
+		return &BrokenLink{Seq: seq, ID: auditLog.ID, Reason: "hash does not match the entry content"}
+	}
+
+	return nil
+}
+
+// EnsurePartitions creates the monthly partitions from the current month up to
+// monthsAhead months ahead, so inserts never hit a missing partition
+func (s *service) EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) error {
+	start := startOfMonth(now)
+	for i := 0; i <= monthsAhead; i++ {
+		month := start.AddDate(0, i, 0)
+		if err := s.repo.CreatePartition(ctx, partitionName(month), month, month.AddDate(0, 1, 0)); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", partitionName(month), err)
+		}
+	}
+	return nil
+}
+
+// ArchivePartitions exports every partition older than the retention period to
+// a gzipped NDJSON file in dir and drops it. A retention of zero keeps everything.
+func (s *service) ArchivePartitions(ctx context.Context, now time.Time, retentionMonths int, dir string, dryRun bool) ([]entity.AuditArchive, error) {
+	if retentionMonths <= 0 {
+		return nil, nil
+	}
+
+	cutoff := startOfMonth(now).AddDate(0, -retentionMonths, 0)
+
+	partitions, err := s.repo.ListPartitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	if !dryRun {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create archive directory: %w", err)
+		}
+	}
+
+	var archives []entity.AuditArchive
+	for _, partition := range partitions {
+		start, ok := parsePartitionName(partition)
+		if !ok || start.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+
+		archive := entity.AuditArchive{
+			ID:            uuid.New(),
+			PartitionName: partition,
+			RangeStart:    start,
+			RangeEnd:      start.AddDate(0, 1, 0),
+			FilePath:      filepath.Join(dir, partition+".ndjson.gz"),
+		}
+
+		if dryRun {
+			archives = append(archives, archive)
+			continue
+		}
+
+		if err := s.exportPartition(ctx, &archive); err != nil {
+			return archives, fmt.Errorf("failed to export partition %s: %w", partition, err)
+		}
+
+		archive.ArchivedAt = time.Now()
+		if err := s.repo.DropPartition(ctx, partition, &archive); err != nil {
+			return archives, fmt.Errorf("failed to drop partition %s: %w", partition, err)
+		}
+
+		archives = append(archives, archive)
+	}
+
+	return archives, nil
+}
+
+// exportPartition writes all rows of a partition to the archive file and fills
+// in the row count, checksum and chain position of the archive
+func (s *service) exportPartition(ctx context.Context, archive *entity.AuditArchive) error {
+	// Write to a temporary file first so a crash never leaves a truncated archive
+	tmpPath := archive.FilePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	checksum := sha256.New()
+	buffered := bufio.NewWriter(io.MultiWriter(file, checksum))
+	compressed := gzip.NewWriter(buffered)
+	encoder := json.NewEncoder(compressed)
+
+	var after *Cursor
+	for {
+		rows, err := s.repo.ListPartitionRows(ctx, archive.PartitionName, after, archiveBatchSize)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				file.Close()
+				return err
+			}
+
+			archive.RowCount++
+			if row.Seq != nil {
+				if archive.FirstSeq == nil || *row.Seq < *archive.FirstSeq {
+					archive.FirstSeq = row.Seq
+				}
+				if archive.LastSeq == nil || *row.Seq > *archive.LastSeq {
+					archive.LastSeq = row.Seq
+					archive.LastHash = row.Hash
+				}
+			}
+		}
+
+		last := rows[len(rows)-1]
+		after = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	if err := compressed.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	archive.FileSha256 = hex.EncodeToString(checksum.Sum(nil))
+	return os.Rename(tmpPath, archive.FilePath)
+}
+
+// startOfMonth returns midnight UTC on the first day of the month of t
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName returns the name of the partition holding the month starting at start
+func partitionName(start time.Time) string {
+	return fmt.Sprintf(partitionNameFormat, start.Year(), int(start.Month()))
+}
+
+// parsePartitionName returns the first day of the month a partition holds
+func parsePartitionName(name string) (time.Time, bool) {
+	var year, month int
+	if _, err := fmt.Sscanf(name, partitionNameFormat, &year, &month); err != nil {
+		return time.Time{}, false
+	}
+	if month < 1 || month > 12 || partitionName(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)) != name {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// toListResponse trims the extra row fetched by the repository into a next cursor
+func (s *service) toListResponse(auditLogs []*entity.AuditLog, limit int) *AuditLogListResponse {
+	response := &AuditLogListResponse{
+		AuditLogs: make([]AuditLogResponse, 0, len(auditLogs)),
+		Limit:     limit,
+	}
+
+	if len(auditLogs) > limit {
+		auditLogs = auditLogs[:limit]
+		last := auditLogs[limit-1]
+		response.NextCursor = encodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, auditLog := range auditLogs {
+		response.AuditLogs = append(response.AuditLogs, *s.entityToResponse(auditLog))
+	}
+
+	return response
+}
+
+// entityToResponse converts an audit log entity to response DTO
+func (s *service) entityToResponse(auditLog *entity.AuditLog) *AuditLogResponse {
+	return &AuditLogResponse{
+		ID:             auditLog.ID,
+		UserID:         auditLog.UserID,
+		Action:         auditLog.Action,
+		TargetResource: auditLog.TargetResource,
+		TargetID:       auditLog.TargetID,
+		Details:        auditLog.Details,
+		CreatedAt:      auditLog.CreatedAt,
+		Seq:            auditLog.Seq,
+		PrevHash:       auditLog.PrevHash,
+		Hash:           auditLog.Hash,
+	}
+}
+
+// encodeCursor encodes the position of the last returned entry as an opaque string
+func encodeCursor(cursor Cursor) string {
+	raw := cursor.CreatedAt.Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor; an empty cursor means the first page
+func decodeCursor(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, common.ErrInvalidCursor
+	}
+
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, common.ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, common.ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, common.ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: createdAt, ID: id}, nil
+}
@@ -0,0 +1,296 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// fakeAuditRepo keeps audit log entries in memory, in chain order
+type fakeAuditRepo struct {
+	auditLogs   []*entity.AuditLog
+	lastFilters AuditLogFilterRequest
+}
+
+func (r *fakeAuditRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.AuditLog, error) {
+	for _, auditLog := range r.auditLogs {
+		if auditLog.ID == id {
+			return auditLog, nil
+		}
+	}
+	return nil, nil
+}
+
+// List applies the action filter only, which is what the tests look at
+func (r *fakeAuditRepo) List(ctx context.Context, filters AuditLogFilterRequest, after *Cursor) ([]*entity.AuditLog, error) {
+	r.lastFilters = filters
+
+	var auditLogs []*entity.AuditLog
+	for _, auditLog := range r.auditLogs {
+		if len(filters.Actions) > 0 && !containsString(filters.Actions, auditLog.Action) {
+			continue
+		}
+		auditLogs = append(auditLogs, auditLog)
+	}
+	return auditLogs, nil
+}
+
+func (r *fakeAuditRepo) GetBySeq(ctx context.Context, seq int64) (*entity.AuditLog, error) {
+	for _, auditLog := range r.auditLogs {
+		if auditLog.Seq != nil && *auditLog.Seq == seq {
+			return auditLog, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuditRepo) ListChain(ctx context.Context, afterSeq int64, from, to *time.Time, limit int) ([]*entity.AuditLog, error) {
+	var auditLogs []*entity.AuditLog
+	for _, auditLog := range r.auditLogs {
+		if auditLog.Seq != nil && *auditLog.Seq > afterSeq && len(auditLogs) < limit {
+			auditLogs = append(auditLogs, auditLog)
+		}
+	}
+	return auditLogs, nil
+}
+
+func (r *fakeAuditRepo) GetArchiveByLastSeq(ctx context.Context, seq int64) (*entity.AuditArchive, error) {
+	return nil, nil
+}
+
+func (r *fakeAuditRepo) ListPartitions(ctx context.Context) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *fakeAuditRepo) CreatePartition(ctx context.Context, name string, start, end time.Time) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeAuditRepo) ListPartitionRows(ctx context.Context, partition string, after *Cursor, limit int) ([]*entity.AuditLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *fakeAuditRepo) DropPartition(ctx context.Context, partition string, archive *entity.AuditArchive) error {
+	return errors.New("not implemented")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func testAuditLog(action string, details string) *entity.AuditLog {
+	targetID := uuid.New()
+	return &entity.AuditLog{
+		ID:             uuid.New(),
+		Action:         action,
+		TargetResource: "tasks",
+		TargetID:       &targetID,
+		Details:        json.RawMessage(details),
+		CreatedAt:      time.Now(),
+	}
+}
+
+func TestGetHistoryOnlyReturnsChanges(t *testing.T) {
+	repo := &fakeAuditRepo{auditLogs: []*entity.AuditLog{
+		testAuditLog(entity.AuditActionUpdate, `{"changes":{"title":{"old":"a","new":"b"}}}`),
+		testAuditLog(entity.AuditActionRead, `{"ip_address":"203.0.113.7"}`),
+		testAuditLog(entity.AuditActionAccessDenied, `{"ip_address":"203.0.113.8"}`),
+		testAuditLog(entity.AuditActionCreate, `{"new":{"title":"a"}}`),
+	}}
+	s := NewService(repo)
+
+	history, err := s.GetHistory(context.Background(), "tasks", uuid.New(), PageRequest{}, true)
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+
+	var actions []string
+	for _, auditLog := range history.AuditLogs {
+		actions = append(actions, auditLog.Action)
+	}
+	if want := []string{entity.AuditActionUpdate, entity.AuditActionCreate}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+	if repo.lastFilters.TargetResource == nil || *repo.lastFilters.TargetResource != "tasks" {
+		t.Errorf("history was not filtered by resource: %+v", repo.lastFilters)
+	}
+}
+
+func TestGetHistoryStripsClientMetadata(t *testing.T) {
+	details := `{"ip_address":"203.0.113.7","user_agent":"curl/8.0","request_id":"req-1","changes":{"title":{"old":"a","new":"b"}}}`
+	repo := &fakeAuditRepo{auditLogs: []*entity.AuditLog{testAuditLog(entity.AuditActionUpdate, details)}}
+	s := NewService(repo)
+
+	history, err := s.GetHistory(context.Background(), "tasks", uuid.New(), PageRequest{}, false)
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(history.AuditLogs[0].Details, &fields); err != nil {
+		t.Fatalf("details: %v", err)
+	}
+	for _, field := range clientMetadataFields {
+		if _, ok := fields[field]; ok {
+			t.Errorf("details contain %s", field)
+		}
+	}
+	if _, ok := fields["changes"]; !ok {
+		t.Error("details lost the field changes")
+	}
+
+	// Auditors still see everything, and the stored entry is left alone
+	history, err = s.GetHistory(context.Background(), "tasks", uuid.New(), PageRequest{}, true)
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if string(history.AuditLogs[0].Details) != details || string(repo.auditLogs[0].Details) != details {
+		t.Errorf("details = %s", history.AuditLogs[0].Details)
+	}
+}
+
+func TestCanReadClientMetadata(t *testing.T) {
+	permissions := policy.NewEngine(nil)
+
+	tests := []struct {
+		name      string
+		principal *common.Principal
+		want      bool
+	}{
+		{"admin", &common.Principal{Role: policy.RoleAdmin, AuthMethod: common.AuthMethodJWT}, true},
+		{"user", &common.Principal{Role: policy.RoleUser, AuthMethod: common.AuthMethodJWT}, false},
+		{"admin API key without audit scope", &common.Principal{Role: policy.RoleAdmin, AuthMethod: common.AuthMethodAPIKey, Scopes: []string{"tasks:read"}}, false},
+		{"admin API key with audit scope", &common.Principal{Role: policy.RoleAdmin, AuthMethod: common.AuthMethodAPIKey, Scopes: []string{"tasks:read", "audit:read"}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := CanReadClientMetadata(context.Background(), permissions, tt.principal); got != tt.want {
+			t.Errorf("%s: CanReadClientMetadata = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testChain builds n correctly chained entries with seq 1 to n
+func testChain(t *testing.T, n int) []*entity.AuditLog {
+	t.Helper()
+
+	chain := make([]*entity.AuditLog, 0, n)
+	prevHash := ""
+	start := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		seq := int64(i)
+		auditLog := testAuditLog(entity.AuditActionCreate, `{"new":{"title":"a"}}`)
+		auditLog.Seq = &seq
+		auditLog.PrevHash = prevHash
+		auditLog.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		chain = append(chain, sealTestAuditLog(t, auditLog))
+		prevHash = auditLog.Hash
+	}
+	return chain
+}
+
+func sealTestAuditLog(t *testing.T, auditLog *entity.AuditLog) *entity.AuditLog {
+	t.Helper()
+
+	hash, err := entity.ComputeAuditHash(auditLog)
+	if err != nil {
+		t.Fatalf("ComputeAuditHash: %v", err)
+	}
+	auditLog.Hash = hash
+	return auditLog
+}
+
+// duplicateOf is a validly hashed entry claiming the same place in the chain
+func duplicateOf(t *testing.T, original *entity.AuditLog) *entity.AuditLog {
+	t.Helper()
+
+	duplicate := *original
+	duplicate.ID = uuid.New()
+	duplicate.CreatedAt = original.CreatedAt.Add(time.Millisecond)
+	return sealTestAuditLog(t, &duplicate)
+}
+
+func TestVerifyChainAcceptsIntactChain(t *testing.T) {
+	s := NewService(&fakeAuditRepo{auditLogs: testChain(t, 5)})
+
+	result, err := s.VerifyChain(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if result.Broken != nil || result.Checked != 5 || result.FirstSeq != 1 || result.LastSeq != 5 {
+		t.Errorf("result = %+v, broken = %+v", result, result.Broken)
+	}
+}
+
+func TestVerifyChainDetectsDuplicateSeq(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		at     int
+	}{
+		{"within a batch", 5, 2},
+		{"across batches", verifyBatchSize + 1, verifyBatchSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := testChain(t, tt.length)
+			duplicate := duplicateOf(t, chain[tt.at-1])
+
+			auditLogs := append([]*entity.AuditLog{}, chain[:tt.at]...)
+			auditLogs = append(auditLogs, duplicate)
+			auditLogs = append(auditLogs, chain[tt.at:]...)
+			s := NewService(&fakeAuditRepo{auditLogs: auditLogs})
+
+			result, err := s.VerifyChain(context.Background(), nil, nil)
+			if err != nil {
+				t.Fatalf("VerifyChain: %v", err)
+			}
+			if result.Broken == nil {
+				t.Fatalf("VerifyChain accepted a duplicate of entry %d", tt.at)
+			}
+			if result.Broken.ID != duplicate.ID || result.Broken.Seq != int64(tt.at) {
+				t.Errorf("broken = %+v, want the duplicate at %d", result.Broken, tt.at)
+			}
+		})
+	}
+}
+
+func TestVerifyChainDetectsTamperingAndGaps(t *testing.T) {
+	tampered := testChain(t, 3)
+	tampered[1].Details = json.RawMessage(`{"new":{"title":"b"}}`)
+
+	gap := testChain(t, 3)
+	gap = append(gap[:1], gap[2:]...)
+
+	tests := []struct {
+		name      string
+		auditLogs []*entity.AuditLog
+		brokenSeq int64
+	}{
+		{"tampered", tampered, 2},
+		{"gap", gap, 3},
+	}
+
+	for _, tt := range tests {
+		result, err := NewService(&fakeAuditRepo{auditLogs: tt.auditLogs}).VerifyChain(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatalf("%s: VerifyChain: %v", tt.name, err)
+		}
+		if result.Broken == nil || result.Broken.Seq != tt.brokenSeq {
+			t.Errorf("%s: broken = %+v, want seq %d", tt.name, result.Broken, tt.brokenSeq)
+		}
+	}
+}
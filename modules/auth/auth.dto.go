@@ -11,7 +11,8 @@ type RegisterRequest struct {
 	Name     string `json:"name" binding:"required" example:"John Doe"`
 	Email    string `json:"email" binding:"required,email" example:"john.doe@example.com"`
 	Password string `json:"password" binding:"required" example:"SecurePass123"`
-	Role     string `json:"role,omitempty" example:"user"` // Optional, only allowed for admin users
+	Role     string `json:"role,omitempty" example:"user"` // Optional, requires the user.assign_role permission
+	Language string `json:"language,omitempty" binding:"omitempty,oneof=en id" example:"en"`
 }
 
 // LoginRequest represents the request payload for user login
@@ -20,6 +21,27 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required" example:"SecurePass123"`
 }
 
+// UnlockRequest represents the request payload for lifting a login lockout
+type UnlockRequest struct {
+	Email     string `json:"email" binding:"required,email" example:"john.doe@example.com"`
+	IPAddress string `json:"ip_address,omitempty" binding:"omitempty,ip" example:"203.0.113.7"`
+}
+
+// OIDCLoginStart is a single sign-on login waiting at the identity provider.
+// Binding goes into a cookie and has to come back with the callback.
+type OIDCLoginStart struct {
+	AuthURL string
+	Binding string
+}
+
+// OIDCCallbackRequest represents the query parameters the identity provider redirects back with
+type OIDCCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
 // UserResponse represents the user data in API responses
 type UserResponse struct {
 	ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
@@ -36,21 +58,55 @@ type RegisterResponse struct {
 	Message string       `json:"message" example:"Registration successful. Welcome email has been sent."`
 }
 
-// LoginResponse represents the response for user login
+// LoginResponse represents the response for user login. When a second factor
+// is required it only carries the MFA token to complete the login with.
 type LoginResponse struct {
-	User        UserResponse `json:"user"`
-	AccessToken string       `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
-	TokenType   string       `json:"token_type" example:"Bearer"`
-	ExpiresIn   int64        `json:"expires_in" example:"3600"`
+	User                  *UserResponse `json:"user,omitempty"`
+	AccessToken           string        `json:"access_token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType             string        `json:"token_type,omitempty" example:"Bearer"`
+	ExpiresIn             int64         `json:"expires_in" example:"3600"`
+	MFARequired           bool          `json:"mfa_required,omitempty" example:"false"`
+	MFAEnrollmentRequired bool          `json:"mfa_enrollment_required,omitempty" example:"false"`
+	MFAToken              string        `json:"mfa_token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
+// MFAVerifyRequest represents the second step of a login challenged for MFA
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // TOTP or recovery code
+}
+
+// MFAEnrollRequest represents the request to start a TOTP enrollment. The MFA
+// token is only needed when enrolling during a login that requires MFA.
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+// MFAEnrollResponse represents a started TOTP enrollment
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/dtt-api:john.doe%40example.com?algorithm=SHA1&digits=6&issuer=dtt-api&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+}
+
+// MFAConfirmRequest represents the first code confirming a TOTP enrollment
+type MFAConfirmRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+// MFAConfirmResponse represents a confirmed enrollment. Login is set when the
+// enrollment completed a login.
+type MFAConfirmResponse struct {
+	RecoveryCodes []string       `json:"recovery_codes" example:"k3m9d-q2xw7"`
+	Login         *LoginResponse `json:"login,omitempty"`
 }
 
-// ValidationError represents a field validation error
-type ValidationError struct {
-	Field   string `json:"field" example:"email"`
-	Message string `json:"message" example:"email format is invalid"`
+// MFACodeRequest represents a current TOTP or recovery code proving possession of the second factor
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
 }
 
-// ValidationErrors represents multiple validation errors
-type ValidationErrors struct {
-	Errors []ValidationError `json:"errors"`
+// MFARecoveryCodesResponse represents newly generated recovery codes
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3m9d-q2xw7"`
 }
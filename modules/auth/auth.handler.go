@@ -2,6 +2,10 @@ package auth
 
 import (
 	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mnizarzr/dot-test/common"
@@ -22,18 +26,18 @@ func NewHandler(service Service) *Handler {
 // Register handles user registration requests
 //
 //	@Summary		Register a new user
-//	@Description	Register a new user account with email and password. Admins can specify role.
+//	@Description	Register a new user account with email and password. Users with the user.assign_role permission can specify a built-in or custom role.
 //	@Tags			Authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			request	body		RegisterRequest								true	"User registration data"
 //	@Success		201		{object}	common.BaseResponse{data=RegisterResponse}	"User registered successfully"
-//	@Failure		400		{object}	common.BaseResponse							"Bad request - validation errors"
-//	@Failure		401		{object}	common.BaseResponse							"Unauthorized - invalid token"
-//	@Failure		403		{object}	common.BaseResponse							"Forbidden - insufficient permissions"
-//	@Failure		409		{object}	common.BaseResponse							"Conflict - email already exists"
-//	@Failure		422		{object}	common.BaseResponse{data=ValidationErrors}	"Validation failed"
-//	@Failure		500		{object}	common.BaseResponse							"Internal server error"
+//	@Failure		400		{object}	common.Problem								"Bad request - validation errors"
+//	@Failure		401		{object}	common.Problem								"Unauthorized - invalid token"
+//	@Failure		403		{object}	common.Problem								"Forbidden - insufficient permissions"
+//	@Failure		409		{object}	common.Problem								"Conflict - email already exists"
+//	@Failure		422		{object}	common.Problem								"Validation failed"
+//	@Failure		500		{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/auth/register [post]
 //	@Security		BearerAuth
 func (h *Handler) Register(c *gin.Context) {
@@ -41,47 +45,17 @@ func (h *Handler) Register(c *gin.Context) {
 
 	// Bind and validate JSON request
 	if err := c.ShouldBindJSON(&req); err != nil {
-		validationErrors := h.extractValidationErrors(err)
-		if len(validationErrors.Errors) > 0 {
-			common.ValidationErrorResponse(c, validationErrors)
-			return
-		}
-		common.BadRequestResponse(c, "Invalid request format")
+		c.Error(err)
 		return
 	}
 
-	requestingUserRole := ""
-	if userRole, exists := c.Get("user_role"); exists {
-		requestingUserRole = userRole.(string)
-	}
+	// Set by the optional auth middleware when an admin registers someone
+	requester, _ := common.PrincipalFromContext(c.Request.Context())
 
-	response, err := h.service.Register(c.Request.Context(), req, requestingUserRole)
+	response, err := h.service.Register(c.Request.Context(), req, requester)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrEmailAlreadyRegistered):
-			common.ConflictResponse(c, "Email address is already registered")
-			return
-		case errors.Is(err, common.ErrInvalidNameFormat):
-			common.BadRequestResponse(c, err.Error())
-			return
-		case errors.Is(err, common.ErrInvalidEmailFormat):
-			common.BadRequestResponse(c, err.Error())
-			return
-		case errors.Is(err, common.ErrInvalidPasswordFormat):
-			common.BadRequestResponse(c, err.Error())
-			return
-		case err.Error() == "insufficient permissions to assign role":
-			common.ErrorResponse(c, 403, "Insufficient permissions to assign role")
-			return
-		default:
-			// Check for invalid role errors
-			if len(err.Error()) > 13 && err.Error()[:13] == "invalid role:" {
-				common.BadRequestResponse(c, err.Error())
-				return
-			}
-			common.InternalServerErrorResponse(c, "Failed to register user")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
 	common.CreatedResponse(c, response, "User registered successfully")
@@ -94,55 +68,356 @@ func (h *Handler) Register(c *gin.Context) {
 //	@Tags			Authentication
 //	@Accept			json
 //	@Produce		json
-//	@Param			request	body		LoginRequest								true	"User login credentials"
-//	@Success		200		{object}	common.BaseResponse{data=LoginResponse}		"Login successful"
-//	@Failure		400		{object}	common.BaseResponse							"Bad request - validation errors"
-//	@Failure		401		{object}	common.BaseResponse							"Unauthorized - invalid credentials"
-//	@Failure		422		{object}	common.BaseResponse{data=ValidationErrors}	"Validation failed"
-//	@Failure		500		{object}	common.BaseResponse							"Internal server error"
+//	@Param			request	body		LoginRequest							true	"User login credentials"
+//	@Success		200		{object}	common.BaseResponse{data=LoginResponse}	"Login successful, or MFA code required"
+//	@Failure		400		{object}	common.Problem							"Bad request - validation errors"
+//	@Failure		401		{object}	common.Problem							"Unauthorized - invalid credentials"
+//	@Failure		422		{object}	common.Problem							"Validation failed"
+//	@Failure		429		{object}	common.Problem							"Too many failed attempts - see Retry-After"
+//	@Failure		500		{object}	common.Problem							"Internal server error"
 //	@Router			/api/v1/auth/login [post]
 func (h *Handler) Login(c *gin.Context) {
 	var req LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		validationErrors := h.extractValidationErrors(err)
-		if len(validationErrors.Errors) > 0 {
-			common.ValidationErrorResponse(c, validationErrors)
-			return
-		}
-		common.BadRequestResponse(c, "Invalid request format")
+		c.Error(err)
 		return
 	}
 
-	response, err := h.service.Login(c.Request.Context(), req)
+	// Failed attempts are audited with a hash of the email
+	c.Set("audit_email", req.Email)
+
+	response, err := h.service.Login(c.Request.Context(), req, c.ClientIP())
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrInvalidCredentials):
-			common.ErrorResponse(c, 401, "Invalid email or password")
-			return
-		case errors.Is(err, common.ErrInvalidEmailFormat):
-			common.BadRequestResponse(c, err.Error())
-			return
-		case errors.Is(err, common.ErrInvalidPasswordFormat):
-			common.BadRequestResponse(c, err.Error())
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to login user")
-			return
-		}
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	if response.MFARequired {
+		common.SuccessResponse(c, response, "MFA code required")
+		return
 	}
 
 	common.SuccessResponse(c, response, "Login successful")
 }
 
-// extractValidationErrors extracts validation errors from binding errors
-func (h *Handler) extractValidationErrors(err error) ValidationErrors {
-	var validationErrors ValidationErrors
+// VerifyMFA handles the second step of a login challenged for MFA
+//
+//	@Summary		Verify MFA code
+//	@Description	Complete a login with the MFA token from the login response and a TOTP or recovery code
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MFAVerifyRequest						true	"MFA token and code"
+//	@Success		200		{object}	common.BaseResponse{data=LoginResponse}	"Login successful, or MFA code required"
+//	@Failure		401		{object}	common.Problem							"Unauthorized - invalid token or code"
+//	@Failure		422		{object}	common.Problem							"Validation failed"
+//	@Failure		429		{object}	common.Problem							"Too many failed attempts - see Retry-After"
+//	@Failure		500		{object}	common.Problem							"Internal server error"
+//	@Router			/api/v1/auth/mfa/verify [post]
+func (h *Handler) VerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.VerifyMFA(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Login successful")
+}
+
+// EnrollMFA handles requests to start a TOTP enrollment
+//
+//	@Summary		Start MFA enrollment
+//	@Description	Generate a TOTP secret and otpauth:// URI. Authenticate with an access token, or with the MFA token of a login that requires enrollment.
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MFAEnrollRequest							false	"MFA token when enrolling during login"
+//	@Success		200		{object}	common.BaseResponse{data=MFAEnrollResponse}	"MFA enrollment started"
+//	@Failure		401		{object}	common.Problem								"Unauthorized"
+//	@Failure		409		{object}	common.Problem								"MFA is already enabled"
+//	@Failure		500		{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/auth/mfa/enroll [post]
+//	@Security		BearerAuth
+func (h *Handler) EnrollMFA(c *gin.Context) {
+	var req MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.Error(err)
+		return
+	}
+
+	principal, _ := common.PrincipalFromContext(c.Request.Context())
+	if req.MFAToken == "" && principal == nil {
+		c.Error(common.ErrAuthenticationRequired)
+		return
+	}
+
+	response, err := h.service.EnrollMFA(c.Request.Context(), req, principal)
+	if err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "MFA enrollment started")
+}
+
+// ConfirmMFA handles requests to confirm a TOTP enrollment with its first code
+//
+//	@Summary		Confirm MFA enrollment
+//	@Description	Enable MFA with the first TOTP code and receive recovery codes. Enrollments confirmed with an MFA token also complete the login.
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MFAConfirmRequest								true	"First TOTP code"
+//	@Success		200		{object}	common.BaseResponse{data=MFAConfirmResponse}	"MFA enabled"
+//	@Failure		400		{object}	common.Problem									"MFA enrollment has not been started"
+//	@Failure		401		{object}	common.Problem									"Unauthorized - invalid token or code"
+//	@Failure		409		{object}	common.Problem									"MFA is already enabled"
+//	@Failure		422		{object}	common.Problem									"Validation failed"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/auth/mfa/confirm [post]
+//	@Security		BearerAuth
+func (h *Handler) ConfirmMFA(c *gin.Context) {
+	var req MFAConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	principal, _ := common.PrincipalFromContext(c.Request.Context())
+	if req.MFAToken == "" && principal == nil {
+		c.Error(common.ErrAuthenticationRequired)
+		return
+	}
+
+	response, err := h.service.ConfirmMFA(c.Request.Context(), req, principal)
+	if err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "MFA enabled. Store the recovery codes somewhere safe.")
+}
+
+// DisableMFA handles requests to turn off MFA
+//
+//	@Summary		Disable MFA
+//	@Description	Remove the TOTP second factor and recovery codes, unless MFA is required for the user's role
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MFACodeRequest		true	"Current TOTP or recovery code"
+//	@Success		200		{object}	common.BaseResponse	"MFA disabled"
+//	@Failure		400		{object}	common.Problem		"MFA is not enabled"
+//	@Failure		401		{object}	common.Problem		"Unauthorized - invalid code"
+//	@Failure		403		{object}	common.Problem		"MFA is required for this role"
+//	@Failure		422		{object}	common.Problem		"Validation failed"
+//	@Failure		500		{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/auth/mfa/disable [post]
+//	@Security		BearerAuth
+func (h *Handler) DisableMFA(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.service.DisableMFA(c.Request.Context(), principal.UserID, req); err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "MFA disabled")
+}
+
+// RegenerateRecoveryCodes handles requests to replace the MFA recovery codes
+//
+//	@Summary		Regenerate recovery codes
+//	@Description	Replace all MFA recovery codes, invalidating the old ones
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MFACodeRequest										true	"Current TOTP or recovery code"
+//	@Success		200		{object}	common.BaseResponse{data=MFARecoveryCodesResponse}	"Recovery codes regenerated"
+//	@Failure		400		{object}	common.Problem										"MFA is not enabled"
+//	@Failure		401		{object}	common.Problem										"Unauthorized - invalid code"
+//	@Failure		422		{object}	common.Problem										"Validation failed"
+//	@Failure		500		{object}	common.Problem										"Internal server error"
+//	@Router			/api/v1/auth/mfa/recovery-codes [post]
+//	@Security		BearerAuth
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), principal.UserID, req)
+	if err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Recovery codes regenerated")
+}
+
+// recordFailure sets the audit reason of a failed login step and tells
+// locked out clients when to retry
+func (h *Handler) recordFailure(c *gin.Context, err error) {
+	var locked *LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Set("audit_reason", "locked_out")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	case errors.Is(err, common.ErrInvalidCredentials):
+		c.Set("audit_reason", "invalid_credentials")
+	case errors.Is(err, common.ErrInvalidMFAToken):
+		c.Set("audit_reason", "invalid_mfa_token")
+	case errors.Is(err, common.ErrInvalidMFACode):
+		c.Set("audit_reason", "invalid_mfa_code")
+	case errors.Is(err, common.ErrOIDCEmailNotVerified):
+		c.Set("audit_reason", "oidc_email_not_verified")
+	case errors.Is(err, common.ErrOIDCLoginFailed):
+		c.Set("audit_reason", "oidc_failed")
+	}
+}
+
+// Unlock handles requests to lift a login lockout
+//
+//	@Summary		Unlock an account
+//	@Description	Clear the failed login counters and lockout of an account, and optionally of an IP address (requires the user.unlock permission)
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		UnlockRequest		true	"Account to unlock"
+//	@Success		200		{object}	common.BaseResponse	"Account unlocked successfully"
+//	@Failure		401		{object}	common.Problem		"Unauthorized"
+//	@Failure		403		{object}	common.Problem		"Forbidden"
+//	@Failure		422		{object}	common.Problem		"Validation failed"
+//	@Failure		500		{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/auth/unlock [post]
+//	@Security		BearerAuth
+func (h *Handler) Unlock(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.service.Unlock(c.Request.Context(), req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "Account unlocked successfully")
+}
+
+// JWKS handles requests for the access token verification keys
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys that access tokens are signed with, for services that verify tokens themselves. Empty when tokens are signed with HS256.
+//	@Tags			Authentication
+//	@Produce		json
+//	@Success		200	{object}	utils.JWKS	"Verification keys"
+//	@Router			/.well-known/jwks.json [get]
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, h.service.JWKS())
+}
+
+// OIDCLogin handles requests to log in through the identity provider
+//
+//	@Summary		Single sign-on login
+//	@Description	Redirect to the OpenID Connect identity provider to log in with the authorization code flow and PKCE
+//	@Tags			Authentication
+//	@Success		302	"Redirect to the identity provider, setting the cookie the callback needs"
+//	@Failure		404	{object}	common.Problem	"Single sign-on is not configured"
+//	@Failure		502	{object}	common.Problem	"Identity provider unavailable"
+//	@Router			/api/v1/auth/oidc/login [get]
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	start, err := h.service.OIDCLoginURL(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setOIDCBindingCookie(c, start.Binding, int(oidcLoginTTL.Seconds()))
+	c.Redirect(302, start.AuthURL)
+}
+
+// OIDCCallback handles the redirect back from the identity provider
+//
+//	@Summary		Single sign-on callback
+//	@Description	Finish a single sign-on login in the browser that started it. Users are matched by their provider account, linked by verified email, or created on first login. Users with MFA enrolled, or whose role requires it, get an MFA challenge as with password logins.
+//	@Tags			Authentication
+//	@Produce		json
+//	@Param			code	query		string									false	"Authorization code"
+//	@Param			state	query		string									true	"State from the login redirect"
+//	@Param			error	query		string									false	"Error returned by the identity provider"
+//	@Success		200		{object}	common.BaseResponse{data=LoginResponse}	"Login successful"
+//	@Failure		400		{object}	common.Problem							"Invalid or expired state"
+//	@Failure		401		{object}	common.Problem							"Single sign-on login failed"
+//	@Failure		403		{object}	common.Problem							"Email address not verified by the identity provider"
+//	@Failure		404		{object}	common.Problem							"Single sign-on is not configured"
+//	@Failure		500		{object}	common.Problem							"Internal server error"
+//	@Router			/api/v1/auth/oidc/callback [get]
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The cookie is sent once; a failed callback needs a new login anyway
+	binding, _ := c.Cookie(oidcBindingCookie)
+	setOIDCBindingCookie(c, "", -1)
+
+	response, err := h.service.OIDCCallback(c.Request.Context(), req, binding)
+	if err != nil {
+		h.recordFailure(c, err)
+		c.Error(err)
+		return
+	}
+
+	if response.MFARequired {
+		common.SuccessResponse(c, response, "MFA code required")
+		return
+	}
+
+	common.SuccessResponse(c, response, "Login successful")
+}
 
-	validationErrors.Errors = append(validationErrors.Errors, ValidationError{
-		Field:   "general",
-		Message: err.Error(),
-	})
+// oidcBindingCookie holds the value tying a single sign-on login to the
+// browser that started it
+const oidcBindingCookie = "oidc_binding"
 
-	return validationErrors
+// setOIDCBindingCookie sets the binding cookie, or clears it with a negative
+// maxAge. It is only sent to the single sign-on routes, never to scripts,
+// and comes along with the top-level redirect back from the provider.
+func setOIDCBindingCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, value, maxAge, "/api/v1/auth/oidc", "", secure, true)
 }
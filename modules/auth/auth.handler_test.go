@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/middleware"
+)
+
+// loginIPService records the client IP logins are counted against
+type loginIPService struct {
+	Service
+	clientIPs []string
+}
+
+func (s *loginIPService) Login(ctx context.Context, req LoginRequest, clientIP string) (*LoginResponse, error) {
+	s.clientIPs = append(s.clientIPs, clientIP)
+	return nil, common.ErrInvalidCredentials
+}
+
+func TestLoginLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		want           []string
+	}{
+		{"no trusted proxies", "", []string{"198.51.100.9", "198.51.100.9"}},
+		{"peer is not a trusted proxy", "192.0.2.10", []string{"198.51.100.9", "198.51.100.9"}},
+		{"peer is a trusted proxy", "198.51.100.0/24", []string{"203.0.113.5", "203.0.113.6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &loginIPService{}
+			r := gin.New()
+			if err := middleware.SetupTrustedProxies(r, tt.trustedProxies); err != nil {
+				t.Fatalf("SetupTrustedProxies: %v", err)
+			}
+			r.POST("/login", NewHandler(service).Login)
+
+			// An attacker rotates X-Forwarded-For to get a fresh IP counter every time
+			for _, forwardedFor := range []string{"203.0.113.5", "203.0.113.6"} {
+				req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"jane@example.com","password":"wrong-password"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				req.RemoteAddr = "198.51.100.9:41234"
+				r.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if !reflect.DeepEqual(service.clientIPs, tt.want) {
+				t.Errorf("failures counted against %v, want %v", service.clientIPs, tt.want)
+			}
+		})
+	}
+}
+
+// oidcBindingService hands out a fixed binding and records the one the
+// callback came back with
+type oidcBindingService struct {
+	Service
+	bindings []string
+}
+
+func (s *oidcBindingService) OIDCLoginURL(ctx context.Context) (*OIDCLoginStart, error) {
+	return &OIDCLoginStart{AuthURL: "https://idp.example.com/authorize?state=s1", Binding: "binding-1"}, nil
+}
+
+func (s *oidcBindingService) OIDCCallback(ctx context.Context, req OIDCCallbackRequest, binding string) (*LoginResponse, error) {
+	s.bindings = append(s.bindings, binding)
+	return nil, common.ErrInvalidOIDCState
+}
+
+func TestOIDCLoginSetsBindingCookie(t *testing.T) {
+	r := gin.New()
+	r.GET("/api/v1/auth/oidc/login", NewHandler(&oidcBindingService{}).OIDCLogin)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://idp.example.com/authorize?state=s1" {
+		t.Fatalf("status = %d, Location = %q", w.Code, w.Header().Get("Location"))
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("set %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != oidcBindingCookie || cookie.Value != "binding-1" {
+		t.Errorf("cookie %s=%s", cookie.Name, cookie.Value)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/api/v1/auth/oidc" {
+		t.Errorf("cookie HttpOnly=%v SameSite=%v Path=%q, want HttpOnly, Lax, the SSO routes", cookie.HttpOnly, cookie.SameSite, cookie.Path)
+	}
+	if cookie.MaxAge != int(oidcLoginTTL.Seconds()) {
+		t.Errorf("cookie MaxAge = %d, want the login TTL", cookie.MaxAge)
+	}
+}
+
+func TestOIDCCallbackPassesBindingCookie(t *testing.T) {
+	service := &oidcBindingService{}
+	r := gin.New()
+	r.GET("/api/v1/auth/oidc/callback", NewHandler(service).OIDCCallback)
+
+	withCookie := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?state=s1&code=c1", nil)
+	withCookie.AddCookie(&http.Cookie{Name: oidcBindingCookie, Value: "binding-1"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, withCookie)
+
+	// Another browser opening the same link has no cookie
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?state=s1&code=c1", nil))
+
+	if want := []string{"binding-1", ""}; !reflect.DeepEqual(service.bindings, want) {
+		t.Errorf("bindings = %q, want %q", service.bindings, want)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcBindingCookie || cookies[0].MaxAge >= 0 {
+		t.Errorf("callback did not clear the binding cookie: %+v", cookies)
+	}
+}
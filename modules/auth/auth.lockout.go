@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/db"
+	"github.com/redis/go-redis/v9"
+)
+
+// lockoutStrikeMemory is how long earlier lockouts count towards the next,
+// longer one
+const lockoutStrikeMemory = 24 * time.Hour
+
+// failureWindowScript keeps one sorted set entry per failure, scored by Redis
+// server time, drops the ones older than the window and returns how many are
+// left
+var failureWindowScript = redis.NewScript(`
+local now = redis.call('TIME')
+local now_ms = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local window = tonumber(ARGV[1])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now_ms - window)
+redis.call('ZADD', KEYS[1], now_ms, ARGV[2])
+redis.call('PEXPIRE', KEYS[1], window)
+
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// LockoutPolicy configures login brute-force protection. A zero maximum
+// disables the corresponding counter.
+type LockoutPolicy struct {
+	MaxAccountFailures int64
+	MaxIPFailures      int64
+	FailureWindow      time.Duration
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+}
+
+// NewLockoutPolicy reads the lockout thresholds from the configuration
+func NewLockoutPolicy(cfg *config.Config) LockoutPolicy {
+	return LockoutPolicy{
+		MaxAccountFailures: int64(cfg.LoginMaxAccountFailures),
+		MaxIPFailures:      int64(cfg.LoginMaxIPFailures),
+		FailureWindow:      time.Duration(cfg.LoginFailureWindow) * time.Second,
+		BaseLockout:        time.Duration(cfg.LoginLockoutBase) * time.Second,
+		MaxLockout:         time.Duration(cfg.LoginLockoutMax) * time.Second,
+	}
+}
+
+// lockoutDuration doubles the base lockout for every earlier strike, up to the maximum
+func (p LockoutPolicy) lockoutDuration(strikes int64) time.Duration {
+	duration := p.BaseLockout
+	for i := int64(1); i < strikes && duration < p.MaxLockout; i++ {
+		duration *= 2
+	}
+	if p.MaxLockout > 0 && duration > p.MaxLockout {
+		duration = p.MaxLockout
+	}
+	return duration
+}
+
+// LockedError is returned while an account or IP address is locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return common.ErrTooManyLoginAttempts.Error()
+}
+
+func (e *LockedError) Unwrap() error {
+	return common.ErrTooManyLoginAttempts
+}
+
+// loginGuard keeps failure counters and locks in Redis. Redis errors are
+// left to the caller, which fails open so an outage doesn't block every login.
+type loginGuard struct {
+	cache  *db.RedisClient
+	policy LockoutPolicy
+}
+
+// accountKey builds the Redis key of an account counter or lock
+func accountKey(kind, email string) string {
+	return "login:" + kind + ":account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipKey builds the Redis key of an IP address counter or lock
+func ipKey(kind, ip string) string {
+	return "login:" + kind + ":ip:" + ip
+}
+
+// check returns a LockedError when the account or IP address is locked
+func (g *loginGuard) check(ctx context.Context, email, ip string) error {
+	keys := []string{accountKey("lock", email)}
+	if ip != "" {
+		keys = append(keys, ipKey("lock", ip))
+	}
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := g.cache.GetClient().PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and locks the account or IP address
+// once it reaches its threshold. It returns how long this attempt locked the
+// account and the IP address for, zero when it didn't lock them.
+func (g *loginGuard) recordFailure(ctx context.Context, email, ip string) (accountLock, ipLock time.Duration, err error) {
+	accountLock, err = g.fail(ctx, accountKey("failures", email), accountKey("lock", email), accountKey("strikes", email), g.policy.MaxAccountFailures)
+	if err != nil || ip == "" {
+		return accountLock, 0, err
+	}
+
+	ipLock, err = g.fail(ctx, ipKey("failures", ip), ipKey("lock", ip), ipKey("strikes", ip), g.policy.MaxIPFailures)
+	return accountLock, ipLock, err
+}
+
+// fail records a failure in a sliding window and sets the lock when the
+// failures within the window reach max
+func (g *loginGuard) fail(ctx context.Context, failKey, lockKey, strikesKey string, max int64) (time.Duration, error) {
+	if max <= 0 {
+		return 0, nil
+	}
+
+	client := g.cache.GetClient()
+
+	failures, err := failureWindowScript.Run(ctx, client, []string{failKey}, g.policy.FailureWindow.Milliseconds(), uuid.NewString()).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	if failures < max {
+		return 0, nil
+	}
+
+	pipe := client.TxPipeline()
+	strikes := pipe.Incr(ctx, strikesKey)
+	pipe.Expire(ctx, strikesKey, lockoutStrikeMemory)
+	pipe.Del(ctx, failKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	duration := g.policy.lockoutDuration(strikes.Val())
+	if err := client.Set(ctx, lockKey, strikes.Val(), duration).Err(); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+// reset clears the account failures after a successful login. IP counters
+// are kept, so one valid account doesn't hide guessing against others.
+func (g *loginGuard) reset(ctx context.Context, email string) error {
+	return g.cache.Delete(ctx, accountKey("failures", email), accountKey("strikes", email))
+}
+
+// unlock lifts the lock, failures and strikes of an account and, if given,
+// an IP address
+func (g *loginGuard) unlock(ctx context.Context, email, ip string) error {
+	keys := []string{accountKey("failures", email), accountKey("lock", email), accountKey("strikes", email)}
+	if ip != "" {
+		keys = append(keys, ipKey("failures", ip), ipKey("lock", ip), ipKey("strikes", ip))
+	}
+	return g.cache.Delete(ctx, keys...)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/db"
+	"github.com/mnizarzr/dot-test/policy"
+	"github.com/mnizarzr/dot-test/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// oidcLoginTTL is how long a user has to log in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcLogin is what a login started at the provider needs to be finished,
+// stored under its state parameter
+type oidcLogin struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+
+	// BindingHash is the hash of the value in the cookie of the browser that
+	// started the login, so nobody else can finish it
+	BindingHash string `json:"binding_hash"`
+}
+
+// hashOIDCBinding hashes the browser binding value of a login
+func hashOIDCBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+// startedBy reports whether the login was started by the browser holding
+// the binding value
+func (l *oidcLogin) startedBy(binding string) bool {
+	if binding == "" || l.BindingHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashOIDCBinding(binding)), []byte(l.BindingHash)) == 1
+}
+
+// NewOIDCClient creates the client for the configured provider, or returns
+// nil when single sign-on is not configured
+func NewOIDCClient(cfg *config.Config) *utils.OIDCClient {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(cfg.AppUrl, "/") + "/api/v1/auth/oidc/callback"
+	}
+
+	return utils.NewOIDCClient(cfg.OIDCIssuerURL, cfg.OIDCClientID, cfg.OIDCClientSecret, redirectURL, strings.Fields(cfg.OIDCScopes), cfg.OIDCGroupsClaim)
+}
+
+// OIDCRoleMapping maps provider groups to roles. Without any mapped groups
+// the roles of existing users are left alone and new users get "user".
+type OIDCRoleMapping struct {
+	AdminGroups   map[string]bool
+	ManagerGroups map[string]bool
+}
+
+// NewOIDCRoleMapping reads the group mapping from the configuration
+func NewOIDCRoleMapping(cfg *config.Config) OIDCRoleMapping {
+	return OIDCRoleMapping{
+		AdminGroups:   splitSet(cfg.OIDCAdminGroups),
+		ManagerGroups: splitSet(cfg.OIDCManagerGroups),
+	}
+}
+
+// enabled reports whether roles are managed by the provider
+func (m OIDCRoleMapping) enabled() bool {
+	return len(m.AdminGroups) > 0 || len(m.ManagerGroups) > 0
+}
+
+// role returns the highest role any of the groups maps to
+func (m OIDCRoleMapping) role(groups []string) string {
+	role := policy.RoleUser
+	for _, group := range groups {
+		if m.AdminGroups[group] {
+			return policy.RoleAdmin
+		}
+		if m.ManagerGroups[group] {
+			role = policy.RoleManager
+		}
+	}
+	return role
+}
+
+// oidcStateStore keeps logins started at the provider until the callback
+type oidcStateStore struct {
+	cache *db.RedisClient
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+// save stores a login under its state parameter
+func (s *oidcStateStore) save(ctx context.Context, state string, login oidcLogin) error {
+	return s.cache.Set(ctx, oidcStateKey(state), login, oidcLoginTTL)
+}
+
+// take returns and removes the login of a state parameter, so each callback
+// can only be used once. It returns nil for unknown or expired states.
+func (s *oidcStateStore) take(ctx context.Context, state string) (*oidcLogin, error) {
+	data, err := s.cache.GetClient().GetDel(ctx, oidcStateKey(state)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// splitSet splits a comma-separated list into a set
+func splitSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
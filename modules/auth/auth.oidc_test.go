@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+	"github.com/mnizarzr/dot-test/utils"
+)
+
+// fakeUserRepo keeps users in memory
+type fakeUserRepo struct {
+	users map[uuid.UUID]*entity.User
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *entity.User) error {
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return r.users[id], nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, user *entity.User) error {
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(r.users, id)
+	return nil
+}
+
+func (r *fakeUserRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	user, _ := r.GetByEmail(ctx, email)
+	return user != nil, nil
+}
+
+func (r *fakeUserRepo) GetPreferences(ctx context.Context, userID uuid.UUID) ([]entity.NotificationPreference, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepo) SavePreferences(ctx context.Context, prefs []entity.NotificationPreference) error {
+	return nil
+}
+
+// fakeAuthRepo keeps MFA enrollments and provider identities in memory
+type fakeAuthRepo struct {
+	users      *fakeUserRepo
+	mfa        map[uuid.UUID]*entity.UserMFA
+	identities []*entity.UserIdentity
+}
+
+func (r *fakeAuthRepo) GetMFA(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error) {
+	return r.mfa[userID], nil
+}
+
+func (r *fakeAuthRepo) SavePendingMFA(ctx context.Context, mfa *entity.UserMFA) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) EnableMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) DeleteMFA(ctx context.Context, userID uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeAuthRepo) GetIdentity(ctx context.Context, issuer, subject string) (*entity.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuthRepo) CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error {
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error {
+	r.users.users[user.ID] = user
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeAuthRepo) TouchIdentity(ctx context.Context, id uuid.UUID, email string) error {
+	return nil
+}
+
+func newTestOIDCService(t *testing.T, users ...*entity.User) (*service, *fakeAuthRepo) {
+	t.Helper()
+
+	jwtKeys, err := utils.NewJWTKeys(&config.Config{JWTAlgorithm: utils.JWTAlgorithmHS256, JWTSecret: "secret", AppUrl: "https://tasks.example.com", AppName: "Task Tracker"})
+	if err != nil {
+		t.Fatalf("NewJWTKeys: %v", err)
+	}
+
+	userRepo := &fakeUserRepo{users: make(map[uuid.UUID]*entity.User)}
+	for _, user := range users {
+		userRepo.users[user.ID] = user
+	}
+	repo := &fakeAuthRepo{users: userRepo, mfa: make(map[uuid.UUID]*entity.UserMFA)}
+
+	return &service{
+		userRepo:         userRepo,
+		repo:             repo,
+		jwtKeys:          jwtKeys,
+		mfaRequiredRoles: map[string]bool{policy.RoleAdmin: true},
+		permissions:      policy.NewEngine(testRoleStore),
+		oidcRoles: OIDCRoleMapping{
+			AdminGroups: map[string]bool{"admins": true},
+		},
+	}, repo
+}
+
+func testOIDCClaims(email string, verified bool, groups ...string) *utils.OIDCClaims {
+	return &utils.OIDCClaims{
+		Issuer:        "https://idp.example.com",
+		Subject:       "subject-1",
+		Email:         email,
+		EmailVerified: verified,
+		Name:          "Jane Doe",
+		Groups:        groups,
+	}
+}
+
+func TestOIDCUserRejectsUnverifiedEmail(t *testing.T) {
+	existing := &entity.User{ID: uuid.New(), Email: "jane@example.com", Role: policy.RoleUser}
+	s, repo := newTestOIDCService(t, existing)
+
+	for _, claims := range []*utils.OIDCClaims{
+		testOIDCClaims("jane@example.com", false),
+		testOIDCClaims("new@example.com", false),
+		testOIDCClaims("", true),
+	} {
+		_, err := s.oidcUser(context.Background(), claims)
+		if !errors.Is(err, common.ErrOIDCEmailNotVerified) {
+			t.Errorf("oidcUser(%q, verified=%v) error = %v, want ErrOIDCEmailNotVerified", claims.Email, claims.EmailVerified, err)
+		}
+	}
+
+	if len(repo.identities) != 0 || len(repo.users.users) != 1 {
+		t.Errorf("unverified logins created %d identities and %d users", len(repo.identities), len(repo.users.users)-1)
+	}
+}
+
+func TestOIDCUserLinksVerifiedEmail(t *testing.T) {
+	existing := &entity.User{ID: uuid.New(), Email: "jane@example.com", Role: policy.RoleUser}
+	s, repo := newTestOIDCService(t, existing)
+
+	userEntity, err := s.oidcUser(context.Background(), testOIDCClaims("jane@example.com", true))
+	if err != nil {
+		t.Fatalf("oidcUser: %v", err)
+	}
+	if userEntity.ID != existing.ID {
+		t.Errorf("linked user %s, want %s", userEntity.ID, existing.ID)
+	}
+	if len(repo.identities) != 1 || repo.identities[0].UserID != existing.ID {
+		t.Fatalf("identities = %+v", repo.identities)
+	}
+
+	// Later logins find the user by the provider account, whatever the email says
+	userEntity, err = s.oidcUser(context.Background(), testOIDCClaims("changed@example.com", false))
+	if err != nil || userEntity.ID != existing.ID {
+		t.Errorf("oidcUser by identity = %v, %v", userEntity, err)
+	}
+}
+
+func TestOIDCUserProvisionsWithMappedRole(t *testing.T) {
+	s, repo := newTestOIDCService(t)
+
+	userEntity, err := s.oidcUser(context.Background(), testOIDCClaims("jane@example.com", true, "admins"))
+	if err != nil {
+		t.Fatalf("oidcUser: %v", err)
+	}
+	if userEntity.Role != policy.RoleAdmin || userEntity.PasswordHash != "" || userEntity.Name != "Jane Doe" {
+		t.Errorf("provisioned user = %+v", userEntity)
+	}
+	if len(repo.identities) != 1 || repo.identities[0].UserID != userEntity.ID {
+		t.Errorf("identities = %+v", repo.identities)
+	}
+}
+
+func TestCompleteLoginChallengesMFARequiredRoles(t *testing.T) {
+	admin := &entity.User{ID: uuid.New(), Email: "admin@example.com", Role: policy.RoleAdmin}
+	s, _ := newTestOIDCService(t, admin)
+
+	response, err := s.completeLogin(context.Background(), admin)
+	if err != nil {
+		t.Fatalf("completeLogin: %v", err)
+	}
+	if !response.MFARequired || !response.MFAEnrollmentRequired || response.MFAToken == "" {
+		t.Errorf("response = %+v, want an MFA enrollment challenge", response)
+	}
+	if response.AccessToken != "" {
+		t.Error("an access token was issued without a second factor")
+	}
+
+	userID, err := utils.ValidateMFAChallengeToken(response.MFAToken, s.jwtKeys)
+	if err != nil || userID != admin.ID.String() {
+		t.Errorf("MFA token is for %q, %v", userID, err)
+	}
+}
+
+func TestOIDCLoginStartedBy(t *testing.T) {
+	login := &oidcLogin{BindingHash: hashOIDCBinding("binding-1")}
+
+	if !login.startedBy("binding-1") {
+		t.Error("the browser that started the login was rejected")
+	}
+	for _, binding := range []string{"", "binding-2", login.BindingHash} {
+		if login.startedBy(binding) {
+			t.Errorf("startedBy(%q) = true", binding)
+		}
+	}
+
+	// States stored before logins were bound cannot be finished
+	if (&oidcLogin{}).startedBy("") {
+		t.Error("an unbound login was accepted without a binding")
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository defines the interface for MFA and external identity data operations
+type Repository interface {
+	GetMFA(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error)
+	SavePendingMFA(ctx context.Context, mfa *entity.UserMFA) error
+	EnableMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+	DeleteMFA(ctx context.Context, userID uuid.UUID) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	GetIdentity(ctx context.Context, issuer, subject string) (*entity.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error
+	CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error
+	TouchIdentity(ctx context.Context, id uuid.UUID, email string) error
+}
+
+// repository implements the Repository interface
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new auth repository instance
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetMFA retrieves the MFA enrollment of a user, or nil if there is none
+func (r *repository) GetMFA(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error) {
+	var mfa entity.UserMFA
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// SavePendingMFA stores a new unconfirmed enrollment, replacing any earlier unconfirmed one
+func (r *repository) SavePendingMFA(ctx context.Context, mfa *entity.UserMFA) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled_at", "last_used_step", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "user_mfa.enabled_at IS NULL"}}},
+	}).Create(mfa).Error
+}
+
+// EnableMFA confirms an enrollment and stores its recovery codes
+func (r *repository) EnableMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.UserMFA{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"enabled_at":     now,
+				"last_used_step": step,
+				"updated_at":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+			return err
+		}
+
+		return entity.CreateAuditLog(tx, entity.AuditActionCreate, (&entity.UserMFA{}).TableName(), userID, map[string]interface{}{
+			"method": "totp",
+		}, nil)
+	})
+}
+
+// DeleteMFA removes the enrollment and recovery codes of a user
+func (r *repository) DeleteMFA(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ?", userID).Delete(&entity.UserMFA{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		return entity.CreateAuditLog(tx, entity.AuditActionDelete, (&entity.UserMFA{}).TableName(), userID, nil, map[string]interface{}{
+			"method": "totp",
+		})
+	})
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false if
+// that step or a later one was already used, so each code works only once.
+func (r *repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entity.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns false if
+// no unused code matches.
+func (r *repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entity.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and inserts new ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&entity.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	codes := make([]entity.MFARecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, entity.MFARecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: now,
+		})
+	}
+
+	return tx.Create(&codes).Error
+}
+
+// GetIdentity retrieves the identity of an external provider account, or nil if it isn't linked
+func (r *repository) GetIdentity(ctx context.Context, issuer, subject string) (*entity.UserIdentity, error) {
+	var identity entity.UserIdentity
+	err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateIdentity links an external provider account to an existing user
+func (r *repository) CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// CreateUserWithIdentity provisions a user together with their external provider account
+func (r *repository) CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(identity).Error
+	})
+}
+
+// TouchIdentity records a login through an external provider account and the email it last reported
+func (r *repository) TouchIdentity(ctx context.Context, id uuid.UUID, email string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&entity.UserIdentity{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"email":         email,
+			"last_login_at": now,
+			"updated_at":    now,
+		}).Error
+}
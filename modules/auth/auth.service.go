@@ -2,48 +2,97 @@ package auth
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/db"
 	"github.com/mnizarzr/dot-test/entity"
 	"github.com/mnizarzr/dot-test/jobs"
+	"github.com/mnizarzr/dot-test/modules/session"
 	"github.com/mnizarzr/dot-test/modules/user"
+	"github.com/mnizarzr/dot-test/policy"
 	"github.com/mnizarzr/dot-test/utils"
 )
 
 // Service defines the interface for auth business logic
 type Service interface {
-	Register(ctx context.Context, req RegisterRequest, requestingUserRole string) (*RegisterResponse, error)
-	Login(ctx context.Context, req LoginRequest) (*LoginResponse, error)
+	Register(ctx context.Context, req RegisterRequest, requester *common.Principal) (*RegisterResponse, error)
+	Login(ctx context.Context, req LoginRequest, clientIP string) (*LoginResponse, error)
+	Unlock(ctx context.Context, req UnlockRequest) error
+	JWKS() utils.JWKS
+	VerifyMFA(ctx context.Context, req MFAVerifyRequest, clientIP string) (*LoginResponse, error)
+	EnrollMFA(ctx context.Context, req MFAEnrollRequest, principal *common.Principal) (*MFAEnrollResponse, error)
+	ConfirmMFA(ctx context.Context, req MFAConfirmRequest, principal *common.Principal) (*MFAConfirmResponse, error)
+	DisableMFA(ctx context.Context, userID uuid.UUID, req MFACodeRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*MFARecoveryCodesResponse, error)
+	OIDCLoginURL(ctx context.Context) (*OIDCLoginStart, error)
+	OIDCCallback(ctx context.Context, req OIDCCallbackRequest, binding string) (*LoginResponse, error)
 }
 
 // service implements the Service interface
 type service struct {
-	userRepo  user.Repository
-	jobClient *asynq.Client
-	jwtSecret string
+	userRepo         user.Repository
+	repo             Repository
+	sessions         session.Service
+	guard            *loginGuard
+	jobClient        *asynq.Client
+	jwtKeys          *utils.JWTKeys
+	mfaKey           string
+	mfaIssuer        string
+	mfaRequiredRoles map[string]bool
+	oidc             *utils.OIDCClient
+	oidcStates       *oidcStateStore
+	oidcRoles        OIDCRoleMapping
+	permissions      *policy.Engine
 }
 
 // NewService creates a new auth service instance
-func NewService(userRepo user.Repository, jobClient *asynq.Client, jwtSecret string) Service {
+func NewService(userRepo user.Repository, repo Repository, sessions session.Service, cache *db.RedisClient, jobClient *asynq.Client, jwtKeys *utils.JWTKeys, permissions *policy.Engine, cfg *config.Config) Service {
+	mfaKey := cfg.MFAEncryptionKey
+	if mfaKey == "" {
+		mfaKey = cfg.JWTSecret
+	}
+
+	mfaRequiredRoles := make(map[string]bool)
+	for _, role := range strings.Split(cfg.MFARequiredRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			mfaRequiredRoles[role] = true
+		}
+	}
+
 	return &service{
-		userRepo:  userRepo,
-		jobClient: jobClient,
-		jwtSecret: jwtSecret,
+		userRepo: userRepo,
+		repo:     repo,
+		sessions: sessions,
+		guard: &loginGuard{
+			cache:  cache,
+			policy: NewLockoutPolicy(cfg),
+		},
+		jobClient:        jobClient,
+		jwtKeys:          jwtKeys,
+		mfaKey:           mfaKey,
+		mfaIssuer:        cfg.AppName,
+		mfaRequiredRoles: mfaRequiredRoles,
+		oidc:             NewOIDCClient(cfg),
+		oidcStates:       &oidcStateStore{cache: cache},
+		oidcRoles:        NewOIDCRoleMapping(cfg),
+		permissions:      permissions,
 	}
 }
 
 // Register handles user registration business logic
-func (s *service) Register(ctx context.Context, req RegisterRequest, requestingUserRole string) (*RegisterResponse, error) {
+func (s *service) Register(ctx context.Context, req RegisterRequest, requester *common.Principal) (*RegisterResponse, error) {
 	if err := s.validateRegisterRequest(req); err != nil {
 		return nil, err
 	}
 
-	userRole, err := s.determineUserRole(req.Role, requestingUserRole)
+	userRole, err := s.determineUserRole(ctx, req.Role, requester)
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +117,7 @@ func (s *service) Register(ctx context.Context, req RegisterRequest, requestingU
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
 		Role:         userRole,
+		Language:     req.Language,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -76,7 +126,7 @@ func (s *service) Register(ctx context.Context, req RegisterRequest, requestingU
 		return nil, common.ErrFailedToCreateUser
 	}
 
-	if err := s.enqueueWelcomeEmail(ctx, userEntity.Email, userEntity.Name, userEntity.Role, req.Password); err != nil {
+	if err := s.enqueueWelcomeEmail(ctx, userEntity.Email, userEntity.Name, userEntity.Role, req.Password, userEntity.Language); err != nil {
 		log.Printf("Failed to enqueue welcome email for user %s: %v", userEntity.Email, err)
 	}
 
@@ -98,24 +148,73 @@ func (s *service) Register(ctx context.Context, req RegisterRequest, requestingU
 }
 
 // Login handles user login business logic
-func (s *service) Login(ctx context.Context, req LoginRequest) (*LoginResponse, error) {
+func (s *service) Login(ctx context.Context, req LoginRequest, clientIP string) (*LoginResponse, error) {
 	if err := s.validateLoginRequest(req); err != nil {
 		return nil, err
 	}
 
+	if err := s.guard.check(ctx, req.Email, clientIP); err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return nil, err
+		}
+		log.Printf("Failed to check login lockout for %s: %v", clientIP, err)
+	}
+
 	userEntity, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, common.ErrFailedToRetrieveUser
 	}
-	if userEntity == nil {
-		return nil, common.ErrInvalidCredentials
+
+	// Unknown emails count as failures too, so lockouts don't reveal which accounts exist.
+	// Service accounts only authenticate with API keys.
+	if userEntity == nil || userEntity.ServiceAccount || !utils.CheckPasswordHash(req.Password, userEntity.PasswordHash) {
+		return nil, s.loginFailed(ctx, req.Email, clientIP, userEntity, common.ErrInvalidCredentials)
 	}
 
-	if !utils.CheckPasswordHash(req.Password, userEntity.PasswordHash) {
-		return nil, common.ErrInvalidCredentials
+	return s.completeLogin(ctx, userEntity)
+}
+
+// completeLogin logs in a user who passed the first factor, a password or
+// the identity provider. It returns an MFA challenge instead of tokens when a
+// second factor is enrolled or required for the user's role, so the MFA
+// policy covers single sign-on as well.
+func (s *service) completeLogin(ctx context.Context, userEntity *entity.User) (*LoginResponse, error) {
+	mfa, err := s.repo.GetMFA(ctx, userEntity.ID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveMFA
+	}
+
+	if mfa.Enabled() || s.mfaRequired(ctx, userEntity.Role) {
+		mfaToken, err := utils.GenerateMFAChallengeToken(userEntity.ID.String(), s.jwtKeys)
+		if err != nil {
+			return nil, common.ErrFailedToGenerateToken
+		}
+
+		return &LoginResponse{
+			MFARequired:           true,
+			MFAEnrollmentRequired: !mfa.Enabled(),
+			MFAToken:              mfaToken,
+			ExpiresIn:             int64(utils.MFAChallengeTTL.Seconds()),
+		}, nil
+	}
+
+	return s.issueLogin(ctx, userEntity)
+}
+
+// issueLogin clears the failed attempts of a user who fully authenticated,
+// starts a session and issues their access token
+func (s *service) issueLogin(ctx context.Context, userEntity *entity.User) (*LoginResponse, error) {
+	if err := s.guard.reset(ctx, userEntity.Email); err != nil {
+		log.Printf("Failed to reset login failures for user %s: %v", userEntity.ID, err)
 	}
 
-	token, expiresIn, err := utils.GenerateJWT(userEntity.ID.String(), userEntity.Email, userEntity.Role, s.jwtSecret)
+	userSession, err := s.sessions.Create(ctx, userEntity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresIn, err := utils.GenerateJWT(userEntity.ID.String(), userEntity.Email, userEntity.Role, userSession.ID.String(), s.jwtKeys)
 	if err != nil {
 		return nil, common.ErrFailedToGenerateToken
 	}
@@ -130,7 +229,7 @@ func (s *service) Login(ctx context.Context, req LoginRequest) (*LoginResponse,
 	}
 
 	response := &LoginResponse{
-		User:        userResponse,
+		User:        &userResponse,
 		AccessToken: token,
 		TokenType:   "Bearer",
 		ExpiresIn:   expiresIn,
@@ -139,28 +238,548 @@ func (s *service) Login(ctx context.Context, req LoginRequest) (*LoginResponse,
 	return response, nil
 }
 
-// determineUserRole determines the user role based on request and permissions
-func (s *service) determineUserRole(requestedRole, requestingUserRole string) (string, error) {
-	if requestedRole == "" {
-		return "user", nil
+// Unlock lifts a login lockout of an account and optionally an IP address
+func (s *service) Unlock(ctx context.Context, req UnlockRequest) error {
+	if err := s.guard.unlock(ctx, req.Email, req.IPAddress); err != nil {
+		return common.ErrFailedToUnlockAccount
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys access tokens can be verified with
+func (s *service) JWKS() utils.JWKS {
+	return s.jwtKeys.JWKS()
+}
+
+// VerifyMFA completes a login challenged for a second factor with a TOTP or recovery code
+func (s *service) VerifyMFA(ctx context.Context, req MFAVerifyRequest, clientIP string) (*LoginResponse, error) {
+	userEntity, err := s.userFromMFAToken(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.check(ctx, userEntity.Email, clientIP); err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return nil, err
+		}
+		log.Printf("Failed to check login lockout for %s: %v", clientIP, err)
+	}
+
+	mfa, err := s.repo.GetMFA(ctx, userEntity.ID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveMFA
+	}
+	if !mfa.Enabled() {
+		return nil, common.ErrMFANotEnabled
+	}
+
+	ok, err := s.checkSecondFactor(ctx, mfa, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Wrong codes count towards the lockout, or six digits would be guessable
+		return nil, s.loginFailed(ctx, userEntity.Email, clientIP, userEntity, common.ErrInvalidMFACode)
+	}
+
+	return s.issueLogin(ctx, userEntity)
+}
+
+// EnrollMFA starts a TOTP enrollment for the authenticated user, or for the
+// user of an MFA token when their role requires MFA before they can log in
+func (s *service) EnrollMFA(ctx context.Context, req MFAEnrollRequest, principal *common.Principal) (*MFAEnrollResponse, error) {
+	userEntity, err := s.mfaSubject(ctx, req.MFAToken, principal)
+	if err != nil {
+		return nil, err
 	}
 
-	validRoles := map[string]bool{
-		"user":    true,
-		"manager": true,
-		"admin":   true,
+	existing, err := s.repo.GetMFA(ctx, userEntity.ID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveMFA
+	}
+	if existing.Enabled() {
+		return nil, common.ErrMFAAlreadyEnabled
 	}
 
-	if !validRoles[requestedRole] {
-		return "", fmt.Errorf("invalid role: %s", requestedRole)
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, common.ErrFailedToUpdateMFA
 	}
 
-	if requestedRole == "user" {
-		return "user", nil
+	encrypted, err := utils.EncryptSecret(secret, s.mfaKey)
+	if err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	now := time.Now()
+	mfa := &entity.UserMFA{
+		UserID:    userEntity.ID,
+		Secret:    encrypted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.SavePendingMFA(ctx, mfa); err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	return &MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.mfaIssuer, userEntity.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables a pending enrollment with its first code and returns
+// the recovery codes. Enrollments started with an MFA token also log in.
+func (s *service) ConfirmMFA(ctx context.Context, req MFAConfirmRequest, principal *common.Principal) (*MFAConfirmResponse, error) {
+	userEntity, err := s.mfaSubject(ctx, req.MFAToken, principal)
+	if err != nil {
+		return nil, err
 	}
 
-	if requestingUserRole != "admin" {
-		return "", fmt.Errorf("insufficient permissions to assign role")
+	mfa, err := s.repo.GetMFA(ctx, userEntity.ID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveMFA
+	}
+	if mfa == nil {
+		return nil, common.ErrMFAEnrollmentNotStarted
+	}
+	if mfa.Enabled() {
+		return nil, common.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.DecryptSecret(mfa.Secret, s.mfaKey)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveMFA
+	}
+
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return nil, common.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	if err := s.repo.EnableMFA(ctx, userEntity.ID, step, hashes); err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	response := &MFAConfirmResponse{
+		RecoveryCodes: codes,
+	}
+
+	if req.MFAToken != "" {
+		response.Login, err = s.issueLogin(ctx, userEntity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// DisableMFA removes the second factor of a user after checking a current code
+func (s *service) DisableMFA(ctx context.Context, userID uuid.UUID, req MFACodeRequest) error {
+	userEntity, mfa, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if s.mfaRequired(ctx, userEntity.Role) {
+		return common.ErrMFARequiredByPolicy
+	}
+
+	ok, err := s.checkSecondFactor(ctx, mfa, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return common.ErrInvalidMFACode
+	}
+
+	if err := s.repo.DeleteMFA(ctx, userID); err != nil {
+		return common.ErrFailedToUpdateMFA
+	}
+
+	return nil
+}
+
+// mfaRequired reports whether users of a role must use MFA: roles listed in
+// MFA_REQUIRED_ROLES and every role holding an administrative permission, so
+// a custom role with admin-level permissions cannot skip it. It requires MFA
+// when the permissions of the role cannot be loaded.
+func (s *service) mfaRequired(ctx context.Context, role string) bool {
+	if s.mfaRequiredRoles[role] {
+		return true
+	}
+
+	permissions, err := s.permissions.Permissions(ctx, role)
+	if err != nil {
+		log.Printf("Failed to load permissions of role %s: %v", role, err)
+		return true
+	}
+	return permissions.HasAny(policy.Administrative...)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after checking a current code
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*MFARecoveryCodesResponse, error) {
+	_, mfa, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkSecondFactor(ctx, mfa, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, common.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, common.ErrFailedToUpdateMFA
+	}
+
+	return &MFARecoveryCodesResponse{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// OIDCLoginURL starts a single sign-on login. It returns the identity
+// provider URL to redirect to and the binding value the browser must send
+// back with the callback.
+func (s *service) OIDCLoginURL(ctx context.Context) (*OIDCLoginStart, error) {
+	if s.oidc == nil {
+		return nil, common.ErrOIDCNotConfigured
+	}
+
+	var login oidcLogin
+	var binding string
+	state, err := utils.GenerateOIDCState()
+	if err == nil {
+		login.Nonce, err = utils.GenerateOIDCState()
+	}
+	if err == nil {
+		login.CodeVerifier, err = utils.GenerateOIDCState()
+	}
+	if err == nil {
+		binding, err = utils.GenerateOIDCState()
+	}
+	if err != nil {
+		return nil, common.ErrInternal
+	}
+	login.BindingHash = hashOIDCBinding(binding)
+
+	authURL, err := s.oidc.AuthCodeURL(ctx, state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		return nil, common.ErrOIDCUnavailable
+	}
+
+	if err := s.oidcStates.save(ctx, state, login); err != nil {
+		log.Printf("Failed to store OIDC login state: %v", err)
+		return nil, common.ErrInternal
+	}
+
+	return &OIDCLoginStart{
+		AuthURL: authURL,
+		Binding: binding,
+	}, nil
+}
+
+// OIDCCallback finishes a single sign-on login in the browser that started
+// it, identified by its binding value. The provider account is matched by
+// its subject, then linked to an existing user by verified email, and
+// otherwise provisioned as a new user.
+func (s *service) OIDCCallback(ctx context.Context, req OIDCCallbackRequest, binding string) (*LoginResponse, error) {
+	if s.oidc == nil {
+		return nil, common.ErrOIDCNotConfigured
+	}
+
+	login, err := s.oidcStates.take(ctx, req.State)
+	if err != nil {
+		log.Printf("Failed to load OIDC login state: %v", err)
+		return nil, common.ErrOIDCLoginFailed
+	}
+	if login == nil {
+		return nil, common.ErrInvalidOIDCState
+	}
+
+	// A callback opened in another browser would log it in as whoever
+	// started the login
+	if !login.startedBy(binding) {
+		return nil, common.ErrInvalidOIDCState
+	}
+
+	if req.Error != "" || req.Code == "" {
+		log.Printf("OIDC provider returned error %q: %s", req.Error, req.ErrorDescription)
+		return nil, common.ErrOIDCLoginFailed
+	}
+
+	rawIDToken, err := s.oidc.Exchange(ctx, req.Code, login.CodeVerifier)
+	if err != nil {
+		log.Printf("Failed to exchange OIDC code: %v", err)
+		return nil, common.ErrOIDCLoginFailed
+	}
+
+	claims, err := s.oidc.VerifyIDToken(ctx, rawIDToken, login.Nonce)
+	if err != nil {
+		log.Printf("Failed to verify OIDC ID token: %v", err)
+		return nil, common.ErrOIDCLoginFailed
+	}
+
+	userEntity, err := s.oidcUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider is the source of truth for roles once groups are mapped
+	if s.oidcRoles.enabled() {
+		if role := s.oidcRoles.role(claims.Groups); role != userEntity.Role {
+			userEntity.Role = role
+			userEntity.UpdatedAt = time.Now()
+			if err := s.userRepo.Update(ctx, userEntity); err != nil {
+				return nil, common.ErrFailedToUpdateUser
+			}
+		}
+	}
+
+	return s.completeLogin(ctx, userEntity)
+}
+
+// oidcUser finds or provisions the user of a verified provider account
+func (s *service) oidcUser(ctx context.Context, claims *utils.OIDCClaims) (*entity.User, error) {
+	identity, err := s.repo.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+
+	if identity != nil {
+		userEntity, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, common.ErrFailedToRetrieveUser
+		}
+		if userEntity == nil {
+			return nil, common.ErrUserNotFound
+		}
+
+		if err := s.repo.TouchIdentity(ctx, identity.ID, claims.Email); err != nil {
+			log.Printf("Failed to record OIDC login of user %s: %v", userEntity.ID, err)
+		}
+		return userEntity, nil
+	}
+
+	// Unverified emails could be set to anyone's address at some providers,
+	// so they are never used to link or create accounts
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, common.ErrOIDCEmailNotVerified
+	}
+
+	now := time.Now()
+	identity = &entity.UserIdentity{
+		ID:          uuid.New(),
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	userEntity, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+
+	if userEntity != nil {
+		if userEntity.ServiceAccount {
+			return nil, common.ErrOIDCLoginFailed
+		}
+
+		identity.UserID = userEntity.ID
+		if err := s.repo.CreateIdentity(ctx, identity); err != nil {
+			return nil, common.ErrFailedToCreateUser
+		}
+		return userEntity, nil
+	}
+
+	name := claims.Name
+	if !utils.IsValidName(name) {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	// Provisioned users have no password and can only log in through the provider
+	userEntity = &entity.User{
+		ID:        uuid.New(),
+		Name:      name,
+		Email:     claims.Email,
+		Role:      s.oidcRoles.role(claims.Groups),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	identity.UserID = userEntity.ID
+
+	if err := s.repo.CreateUserWithIdentity(ctx, userEntity, identity); err != nil {
+		return nil, common.ErrFailedToCreateUser
+	}
+
+	return userEntity, nil
+}
+
+// userFromMFAToken resolves the user an MFA challenge token was issued to
+func (s *service) userFromMFAToken(ctx context.Context, mfaToken string) (*entity.User, error) {
+	userIDStr, err := utils.ValidateMFAChallengeToken(mfaToken, s.jwtKeys)
+	if err != nil {
+		return nil, common.ErrInvalidMFAToken
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, common.ErrInvalidMFAToken
+	}
+
+	userEntity, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if userEntity == nil {
+		return nil, common.ErrInvalidMFAToken
+	}
+
+	return userEntity, nil
+}
+
+// mfaSubject resolves the user enrolling, preferring the MFA token over the access token
+func (s *service) mfaSubject(ctx context.Context, mfaToken string, principal *common.Principal) (*entity.User, error) {
+	if mfaToken != "" {
+		return s.userFromMFAToken(ctx, mfaToken)
+	}
+	if principal == nil {
+		return nil, common.ErrAuthenticationRequired
+	}
+
+	userEntity, err := s.userRepo.GetByID(ctx, principal.UserID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if userEntity == nil {
+		return nil, common.ErrAuthenticationRequired
+	}
+
+	return userEntity, nil
+}
+
+// enabledMFA loads a user and their confirmed enrollment
+func (s *service) enabledMFA(ctx context.Context, userID uuid.UUID) (*entity.User, *entity.UserMFA, error) {
+	userEntity, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, common.ErrFailedToRetrieveUser
+	}
+	if userEntity == nil {
+		return nil, nil, common.ErrAuthenticationRequired
+	}
+
+	mfa, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, nil, common.ErrFailedToRetrieveMFA
+	}
+	if !mfa.Enabled() {
+		return nil, nil, common.ErrMFANotEnabled
+	}
+
+	return userEntity, mfa, nil
+}
+
+// checkSecondFactor accepts a TOTP code that wasn't used before or an unused
+// recovery code, consuming it either way
+func (s *service) checkSecondFactor(ctx context.Context, mfa *entity.UserMFA, code string) (bool, error) {
+	secret, err := utils.DecryptSecret(mfa.Secret, s.mfaKey)
+	if err != nil {
+		return false, common.ErrFailedToRetrieveMFA
+	}
+
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+		fresh, err := s.repo.UseTOTPStep(ctx, mfa.UserID, step)
+		if err != nil {
+			return false, common.ErrFailedToUpdateMFA
+		}
+		return fresh, nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, mfa.UserID, utils.HashRecoveryCode(code))
+	if err != nil {
+		return false, common.ErrFailedToUpdateMFA
+	}
+	return used, nil
+}
+
+// newRecoveryCodes generates recovery codes and their hashes for storage
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(utils.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// loginFailed records a failed attempt and returns the error for it, which
+// is a LockedError when the attempt locked the account or IP address
+func (s *service) loginFailed(ctx context.Context, email, clientIP string, userEntity *entity.User, failure error) error {
+	accountLock, ipLock, err := s.guard.recordFailure(ctx, email, clientIP)
+	if err != nil {
+		log.Printf("Failed to record login failure for %s: %v", clientIP, err)
+		return failure
+	}
+
+	if accountLock > 0 && userEntity != nil {
+		if err := s.enqueueAccountLockedEmail(ctx, userEntity, clientIP, accountLock); err != nil {
+			log.Printf("Failed to enqueue account locked email for user %s: %v", userEntity.ID, err)
+		}
+	}
+
+	if retryAfter := max(accountLock, ipLock); retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return failure
+}
+
+// determineUserRole determines the user role based on request and the
+// permissions of the requester, who is nil for a self-registration
+func (s *service) determineUserRole(ctx context.Context, requestedRole string, requester *common.Principal) (string, error) {
+	if requestedRole == "" || requestedRole == policy.RoleUser {
+		return policy.RoleUser, nil
+	}
+
+	exists, err := s.permissions.RoleExists(ctx, requestedRole)
+	if err != nil {
+		return "", common.ErrFailedToRetrieveRole
+	}
+	if !exists {
+		return "", common.ErrInvalidRole.WithParam("role", requestedRole)
+	}
+
+	if requester == nil || !s.permissions.Can(ctx, requester.Role, policy.UserAssignRole) {
+		return "", common.ErrCannotAssignRole
+	}
+	// Assigning a role must not hand out permissions the requester lacks,
+	// such as making someone an admin
+	if !s.permissions.CanGrant(ctx, requester.Role, requestedRole) {
+		return "", common.ErrCannotAssignRole
 	}
 
 	return requestedRole, nil
@@ -195,8 +814,8 @@ func (s *service) validateLoginRequest(req LoginRequest) error {
 }
 
 // enqueueWelcomeEmail adds a welcome email job to the queue
-func (s *service) enqueueWelcomeEmail(ctx context.Context, email, name, role, password string) error {
-	task, err := jobs.NewWelcomeEmailTask(email, name, role, password)
+func (s *service) enqueueWelcomeEmail(ctx context.Context, email, name, role, password, language string) error {
+	task, err := jobs.NewWelcomeEmailTask(email, name, role, password, language)
 	if err != nil {
 		return err
 	}
@@ -205,3 +824,14 @@ func (s *service) enqueueWelcomeEmail(ctx context.Context, email, name, role, pa
 	_, err = s.jobClient.Enqueue(task, asynq.Queue("default"), asynq.MaxRetry(3))
 	return err
 }
+
+// enqueueAccountLockedEmail tells a user that their account was locked
+func (s *service) enqueueAccountLockedEmail(ctx context.Context, userEntity *entity.User, clientIP string, lockedFor time.Duration) error {
+	task, err := jobs.NewAccountLockedEmailTask(userEntity.Email, userEntity.Name, userEntity.Language, clientIP, lockedFor)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.jobClient.Enqueue(task, asynq.Queue("critical"), asynq.MaxRetry(3))
+	return err
+}
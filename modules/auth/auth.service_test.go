@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// fakeRoleStore serves a fixed list of custom roles
+type fakeRoleStore []entity.Role
+
+func (s fakeRoleStore) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	return s, nil
+}
+
+// testRoleStore has a role that may assign roles, one holding an
+// administrative permission without being listed in MFA_REQUIRED_ROLES, and
+// one holding ordinary permissions only
+var testRoleStore = fakeRoleStore{
+	{Name: "onboarding", Permissions: []string{string(policy.UserAssignRole), string(policy.TaskCreate), string(policy.TaskRead)}},
+	{Name: "security", Permissions: []string{string(policy.RoleManage)}},
+	{Name: "reader", Permissions: []string{string(policy.TaskRead)}},
+}
+
+func TestDetermineUserRoleCannotGrantMorePermissions(t *testing.T) {
+	s, _ := newTestOIDCService(t)
+
+	tests := []struct {
+		requesterRole string
+		requestedRole string
+		wantErr       error
+	}{
+		{policy.RoleAdmin, policy.RoleAdmin, nil},
+		{policy.RoleAdmin, policy.RoleManager, nil},
+		{policy.RoleAdmin, "security", nil},
+		{"onboarding", "reader", nil},
+		{"onboarding", policy.RoleUser, nil},
+		{"onboarding", policy.RoleAdmin, common.ErrCannotAssignRole},
+		{"onboarding", policy.RoleManager, common.ErrCannotAssignRole},
+		{"onboarding", "security", common.ErrCannotAssignRole},
+		{policy.RoleManager, "reader", common.ErrCannotAssignRole},
+	}
+
+	for _, tt := range tests {
+		requester := &common.Principal{UserID: uuid.New(), Role: tt.requesterRole, AuthMethod: common.AuthMethodJWT}
+		role, err := s.determineUserRole(context.Background(), tt.requestedRole, requester)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s assigning %s: err = %v, want %v", tt.requesterRole, tt.requestedRole, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && role != tt.requestedRole {
+			t.Errorf("%s assigning %s: role = %s", tt.requesterRole, tt.requestedRole, role)
+		}
+	}
+}
+
+func TestMFARequiredFollowsPermissions(t *testing.T) {
+	s, _ := newTestOIDCService(t)
+
+	tests := map[string]bool{
+		policy.RoleAdmin:   true,
+		"security":         true,
+		"onboarding":       true,
+		policy.RoleManager: false,
+		policy.RoleUser:    false,
+		"reader":           false,
+	}
+
+	for role, want := range tests {
+		if got := s.mfaRequired(context.Background(), role); got != want {
+			t.Errorf("mfaRequired(%s) = %v, want %v", role, got, want)
+		}
+	}
+}
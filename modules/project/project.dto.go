@@ -16,6 +16,21 @@ type CreateProjectRequest struct {
 type UpdateProjectRequest struct {
 	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=255"`
 	Description *string `json:"description,omitempty" binding:"omitempty,max=1000"`
+
+	// Version is the version the client last read, for clients that cannot
+	// send If-Match. The header wins when both are set.
+	Version *int `json:"version,omitempty" binding:"omitempty,min=1" example:"3"`
+}
+
+// ProjectPatchDocument holds the fields of a project that PATCH requests
+// change. Patches apply to the project's current values, so a description the
+// patch sets to null is cleared.
+type ProjectPatchDocument struct {
+	Name        string `json:"name" binding:"required,min=1,max=255" example:"Website Redesign"`
+	Description string `json:"description" binding:"max=1000"`
+
+	// Version fails the patch unless it matches the stored version
+	Version int `json:"version" example:"3"`
 }
 
 // ProjectResponse represents a project in API responses
@@ -24,6 +39,7 @@ type ProjectResponse struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	CreatedBy   *uuid.UUID `json:"created_by"`
+	Version     int        `json:"version"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
@@ -1,11 +1,10 @@
 package project
 
 import (
-	"errors"
-
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/modules/audit"
 )
 
 // Handler handles HTTP requests for project operations
@@ -23,62 +22,38 @@ func NewHandler(service Service) *Handler {
 // CreateProject handles project creation requests
 //
 //	@Summary		Create a new project
-//	@Description	Create a new project (only managers and admins)
+//	@Description	Create a new project (requires the project.create permission)
 //	@Tags			Project
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			payload	body		CreateProjectRequest						true	"Project creation request"
-//	@Success		201		{object}	common.BaseResponse{data=ProjectResponse}	"Project created successfully"
-//	@Failure		400		{object}	common.BaseResponse							"Bad request"
-//	@Failure		401		{object}	common.BaseResponse							"Unauthorized"
-//	@Failure		403		{object}	common.BaseResponse							"Forbidden"
-//	@Failure		409		{object}	common.BaseResponse							"Project name already exists"
-//	@Failure		500		{object}	common.BaseResponse							"Internal server error"
+//	@Param			payload			body		CreateProjectRequest						true	"Project creation request"
+//	@Param			Idempotency-Key	header		string										false	"Key that makes retries of this request return the first response"
+//	@Success		201				{object}	common.BaseResponse{data=ProjectResponse}	"Project created successfully"
+//	@Failure		400				{object}	common.Problem								"Bad request"
+//	@Failure		401				{object}	common.Problem								"Unauthorized"
+//	@Failure		403				{object}	common.Problem								"Forbidden"
+//	@Failure		409				{object}	common.Problem								"Project name already exists, or a request with this key is still in progress"
+//	@Failure		422				{object}	common.Problem								"Validation failed, or Idempotency-Key reused for a different request"
+//	@Failure		500				{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/projects [post]
 func (h *Handler) CreateProject(c *gin.Context) {
-	// Check role permissions
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
-		return
-	}
-
-	role := userRole.(string)
-	if role != "admin" && role != "manager" {
-		common.ErrorResponse(c, 403, "Only managers and admins can create projects")
-		return
-	}
-
-	// Get user ID
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
+	// Get user info
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
 	var req CreateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.ValidationErrorResponse(c, err)
+		c.Error(err)
 		return
 	}
 
-	project, err := h.service.CreateProject(c.Request.Context(), req, userID)
+	project, err := h.service.CreateProject(c.Request.Context(), req, principal)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrProjectNameAlreadyExists):
-			common.ErrorResponse(c, 409, "Project name already exists")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to create project")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
 	common.SuccessResponse(c, project, "Project created successfully")
@@ -87,42 +62,88 @@ func (h *Handler) CreateProject(c *gin.Context) {
 // GetProject handles get project by ID requests
 //
 //	@Summary		Get project by ID
-//	@Description	Get a project by its ID
+//	@Description	Get a project by its ID. The response carries an ETag; send it in If-None-Match to get 304 when unchanged.
 //	@Tags			Project
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id	path		string										true	"Project ID"
-//	@Success		200	{object}	common.BaseResponse{data=ProjectResponse}	"Project retrieved successfully"
-//	@Failure		400	{object}	common.BaseResponse							"Bad request"
-//	@Failure		401	{object}	common.BaseResponse							"Unauthorized"
-//	@Failure		404	{object}	common.BaseResponse							"Project not found"
-//	@Failure		500	{object}	common.BaseResponse							"Internal server error"
+//	@Param			id				path		string										true	"Project ID"
+//	@Param			If-None-Match	header		string										false	"ETag of a cached copy"
+//	@Success		200				{object}	common.BaseResponse{data=ProjectResponse}	"Project retrieved successfully"
+//	@Success		304				"Project not modified"
+//	@Failure		400				{object}	common.Problem								"Bad request"
+//	@Failure		401				{object}	common.Problem								"Unauthorized"
+//	@Failure		404				{object}	common.Problem								"Project not found"
+//	@Failure		500				{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/projects/{id} [get]
 func (h *Handler) GetProject(c *gin.Context) {
 	// Parse project ID
 	projectIDStr := c.Param("id")
 	projectID, err := uuid.Parse(projectIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid project ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
 	project, err := h.service.GetProject(c.Request.Context(), projectID)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrProjectNotFound):
-			common.ErrorResponse(c, 404, "Project not found")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to retrieve project")
-			return
-		}
+		c.Error(err)
+		return
+	}
+
+	if common.NotModified(c, project.Version) {
+		return
 	}
 
 	common.SuccessResponse(c, project, "Project retrieved successfully")
 }
 
+// GetProjectHistory handles project audit trail requests
+//
+//	@Summary		Get project history
+//	@Description	Get the changes made to a project, newest first. Client IP addresses, user agents and request IDs are only included with the audit.read permission.
+//	@Tags			Project
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id		path		string													true	"Project ID"
+//	@Param			cursor	query		string													false	"Cursor from the previous page"
+//	@Param			limit	query		int														false	"Page size (default: 20, max: 100)"
+//	@Success		200		{object}	common.BaseResponse{data=audit.AuditLogListResponse}	"Project history retrieved successfully"
+//	@Failure		400		{object}	common.Problem											"Bad request"
+//	@Failure		401		{object}	common.Problem											"Unauthorized"
+//	@Failure		404		{object}	common.Problem											"Project not found"
+//	@Failure		500		{object}	common.Problem											"Internal server error"
+//	@Router			/api/v1/projects/{id}/history [get]
+func (h *Handler) GetProjectHistory(c *gin.Context) {
+	// Parse project ID
+	projectIDStr := c.Param("id")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var page audit.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err)
+		return
+	}
+
+	history, err := h.service.GetProjectHistory(c.Request.Context(), projectID, page, principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, history, "Project history retrieved successfully")
+}
+
 // GetAllProjects handles get all projects requests
 //
 //	@Summary		Get all projects
@@ -134,20 +155,20 @@ func (h *Handler) GetProject(c *gin.Context) {
 //	@Param			page	query		int												false	"Page number (default: 1)"
 //	@Param			limit	query		int												false	"Page size (default: 10, max: 100)"
 //	@Success		200		{object}	common.BaseResponse{data=ProjectListResponse}	"Projects retrieved successfully"
-//	@Failure		400		{object}	common.BaseResponse								"Bad request"
-//	@Failure		401		{object}	common.BaseResponse								"Unauthorized"
-//	@Failure		500		{object}	common.BaseResponse								"Internal server error"
+//	@Failure		400		{object}	common.Problem									"Bad request"
+//	@Failure		401		{object}	common.Problem									"Unauthorized"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
 //	@Router			/api/v1/projects [get]
 func (h *Handler) GetAllProjects(c *gin.Context) {
 	var pagination PaginationRequest
 	if err := c.ShouldBindQuery(&pagination); err != nil {
-		common.ValidationErrorResponse(c, err)
+		c.Error(err)
 		return
 	}
 
 	response, err := h.service.GetAllProjects(c.Request.Context(), pagination)
 	if err != nil {
-		common.InternalServerErrorResponse(c, "Failed to retrieve projects")
+		c.Error(err)
 		return
 	}
 
@@ -157,72 +178,119 @@ func (h *Handler) GetAllProjects(c *gin.Context) {
 // UpdateProject handles project update requests
 //
 //	@Summary		Update project
-//	@Description	Update an existing project
+//	@Description	Update an existing project. Send the ETag from GET in If-Match, or the version in the body; a stale version gets 412.
 //	@Tags			Project
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id		path		string										true	"Project ID"
-//	@Param			request	body		UpdateProjectRequest						true	"Project update request"
-//	@Success		200		{object}	common.BaseResponse{data=ProjectResponse}	"Project updated successfully"
-//	@Failure		400		{object}	common.BaseResponse							"Bad request"
-//	@Failure		401		{object}	common.BaseResponse							"Unauthorized"
-//	@Failure		403		{object}	common.BaseResponse							"Forbidden"
-//	@Failure		404		{object}	common.BaseResponse							"Project not found"
-//	@Failure		409		{object}	common.BaseResponse							"Project name already exists"
-//	@Failure		500		{object}	common.BaseResponse							"Internal server error"
+//	@Param			id			path		string										true	"Project ID"
+//	@Param			request		body		UpdateProjectRequest						true	"Project update request"
+//	@Param			If-Match	header		string										false	"ETag of the project being edited, required unless the body has a version"
+//	@Success		200			{object}	common.BaseResponse{data=ProjectResponse}	"Project updated successfully"
+//	@Failure		400			{object}	common.Problem								"Bad request"
+//	@Failure		401			{object}	common.Problem								"Unauthorized"
+//	@Failure		403			{object}	common.Problem								"Forbidden"
+//	@Failure		404			{object}	common.Problem								"Project not found"
+//	@Failure		409			{object}	common.Problem								"Project name already exists"
+//	@Failure		412			{object}	common.Problem								"Project changed since it was read"
+//	@Failure		428			{object}	common.Problem								"If-Match or version missing"
+//	@Failure		500			{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/projects/{id} [put]
 func (h *Handler) UpdateProject(c *gin.Context) {
 	projectIDStr := c.Param("id")
 	projectID, err := uuid.Parse(projectIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid project ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	// Parse request
+	var req UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Only apply the update to the version the client has seen
+	req.Version, err = common.IfMatch(c, req.Version)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
+		c.Error(err)
 		return
 	}
 
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	project, err := h.service.UpdateProject(c.Request.Context(), projectID, req, principal)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Parse request
-	var req UpdateProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		common.ValidationErrorResponse(c, err)
+	c.Header("ETag", common.ETag(project.Version))
+
+	common.SuccessResponse(c, project, "Project updated successfully")
+}
+
+// PatchProject handles partial project update requests
+//
+//	@Summary		Patch project
+//	@Description	Change some fields of a project with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902). Unlike PUT, null clears the description. Only the changed columns are written, and If-Match is optional.
+//	@Tags			Project
+//	@Accept			application/merge-patch+json,application/json-patch+json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id			path		string										true	"Project ID"
+//	@Param			request		body		ProjectPatchDocument						true	"Merge patch of these fields, or a JSON Patch against them"
+//	@Param			If-Match	header		string										false	"ETag of the project being edited"
+//	@Success		200			{object}	common.BaseResponse{data=ProjectResponse}	"Project updated successfully"
+//	@Failure		400			{object}	common.Problem								"Bad request"
+//	@Failure		401			{object}	common.Problem								"Unauthorized"
+//	@Failure		403			{object}	common.Problem								"Forbidden"
+//	@Failure		404			{object}	common.Problem								"Project not found"
+//	@Failure		409			{object}	common.Problem								"Project name taken or a test operation failed"
+//	@Failure		412			{object}	common.Problem								"Project changed since it was read"
+//	@Failure		415			{object}	common.Problem								"Unsupported patch format"
+//	@Failure		422			{object}	common.Problem								"Validation failed"
+//	@Failure		500			{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/projects/{id} [patch]
+func (h *Handler) PatchProject(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	project, err := h.service.UpdateProject(c.Request.Context(), projectID, req, userID, userRole.(string))
+	patch, err := common.ParsePatch(c)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrProjectNotFound):
-			common.ErrorResponse(c, 404, "Project not found")
-			return
-		case errors.Is(err, common.ErrForbidden):
-			common.ErrorResponse(c, 403, "Insufficient permissions to update project")
-			return
-		case errors.Is(err, common.ErrProjectNameAlreadyExists):
-			common.ErrorResponse(c, 409, "Project name already exists")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to update project")
+		c.Error(err)
+		return
+	}
+
+	// If-Match is optional, since a patch only writes the fields it names
+	var version *int
+	if c.GetHeader("If-Match") != "" {
+		version, err = common.IfMatch(c, nil)
+		if err != nil {
+			c.Error(err)
 			return
 		}
 	}
 
+	project, err := h.service.PatchProject(c.Request.Context(), projectID, patch, version, principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", common.ETag(project.Version))
 	common.SuccessResponse(c, project, "Project updated successfully")
 }
 
@@ -236,52 +304,30 @@ func (h *Handler) UpdateProject(c *gin.Context) {
 //	@Security		ApiKeyAuth
 //	@Param			id	path		string				true	"Project ID"
 //	@Success		200	{object}	common.BaseResponse	"Project deleted successfully"
-//	@Failure		400	{object}	common.BaseResponse	"Bad request"
-//	@Failure		401	{object}	common.BaseResponse	"Unauthorized"
-//	@Failure		403	{object}	common.BaseResponse	"Forbidden"
-//	@Failure		404	{object}	common.BaseResponse	"Project not found"
-//	@Failure		500	{object}	common.BaseResponse	"Internal server error"
+//	@Failure		400	{object}	common.Problem		"Bad request"
+//	@Failure		401	{object}	common.Problem		"Unauthorized"
+//	@Failure		403	{object}	common.Problem		"Forbidden"
+//	@Failure		404	{object}	common.Problem		"Project not found"
+//	@Failure		500	{object}	common.Problem		"Internal server error"
 //	@Router			/api/v1/projects/{id} [delete]
 func (h *Handler) DeleteProject(c *gin.Context) {
 	projectIDStr := c.Param("id")
 	projectID, err := uuid.Parse(projectIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid project ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	err = h.service.DeleteProject(c.Request.Context(), projectID, principal)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
-		return
-	}
-
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+		c.Error(err)
 		return
 	}
 
-	err = h.service.DeleteProject(c.Request.Context(), projectID, userID, userRole.(string))
-	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrProjectNotFound):
-			common.ErrorResponse(c, 404, "Project not found")
-			return
-		case errors.Is(err, common.ErrForbidden):
-			common.ErrorResponse(c, 403, "Insufficient permissions to delete project")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to delete project")
-			return
-		}
-	}
-
 	common.SuccessResponse(c, nil, "Project deleted successfully")
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository defines the interface for project data operations
@@ -13,7 +14,7 @@ type Repository interface {
 	Create(ctx context.Context, project *entity.Project) error
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Project, error)
 	GetAll(ctx context.Context, offset, limit int) ([]*entity.Project, int64, error)
-	Update(ctx context.Context, project *entity.Project) error
+	Update(ctx context.Context, project *entity.Project, columns ...string) (bool, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	ExistsByName(ctx context.Context, name string) (bool, error)
 	ExistsByNameExcludingID(ctx context.Context, name string, excludeID uuid.UUID) (bool, error)
@@ -69,9 +70,44 @@ func (r *repository) GetAll(ctx context.Context, offset, limit int) ([]*entity.P
 	return projects, total, err
 }
 
-// Update updates an existing project
-func (r *repository) Update(ctx context.Context, project *entity.Project) error {
-	return r.db.WithContext(ctx).Save(project).Error
+// Update saves a project if the stored one is still at project.Version, and bumps
+// the version. Only the given columns are written when there are any. It
+// returns false if another update or a delete came first.
+func (r *repository) Update(ctx context.Context, project *entity.Project, columns ...string) (bool, error) {
+	updated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the row so no update lands between the check and the save
+		var stored entity.Project
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("version").
+			Where("id = ?", project.ID).
+			First(&stored).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if stored.Version != project.Version {
+			return nil
+		}
+
+		project.Version++
+		if len(columns) == 0 {
+			err = tx.Save(project).Error
+		} else {
+			// Copy the columns, so appending cannot write into the caller's slice
+			cols := append(append([]string{}, columns...), "version", "updated_at")
+			err = tx.Model(project).Select(cols).Updates(project).Error
+		}
+		if err != nil {
+			project.Version--
+			return err
+		}
+		updated = true
+		return nil
+	})
+	return updated, err
 }
 
 // Delete deletes a project (which will cascade delete tasks due to foreign key)
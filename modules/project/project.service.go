@@ -2,36 +2,45 @@ package project
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
 	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/audit"
+	"github.com/mnizarzr/dot-test/policy"
 )
 
 // Service defines the interface for project business logic
 type Service interface {
-	CreateProject(ctx context.Context, req CreateProjectRequest, createdBy uuid.UUID) (*ProjectResponse, error)
+	CreateProject(ctx context.Context, req CreateProjectRequest, principal *common.Principal) (*ProjectResponse, error)
 	GetProject(ctx context.Context, id uuid.UUID) (*ProjectResponse, error)
 	GetAllProjects(ctx context.Context, pagination PaginationRequest) (*ProjectListResponse, error)
-	UpdateProject(ctx context.Context, id uuid.UUID, req UpdateProjectRequest, userID uuid.UUID, userRole string) (*ProjectResponse, error)
-	DeleteProject(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error
+	UpdateProject(ctx context.Context, id uuid.UUID, req UpdateProjectRequest, principal *common.Principal) (*ProjectResponse, error)
+	PatchProject(ctx context.Context, id uuid.UUID, patch common.Patch, version *int, principal *common.Principal) (*ProjectResponse, error)
+	DeleteProject(ctx context.Context, id uuid.UUID, principal *common.Principal) error
+	GetProjectHistory(ctx context.Context, id uuid.UUID, page audit.PageRequest, principal *common.Principal) (*audit.AuditLogListResponse, error)
 }
 
 // service implements the Service interface
 type service struct {
-	repo Repository
+	repo         Repository
+	auditService audit.Service
+	permissions  *policy.Engine
 }
 
 // NewService creates a new project service instance
-func NewService(repo Repository) Service {
+func NewService(repo Repository, auditService audit.Service, permissions *policy.Engine) Service {
 	return &service{
-		repo: repo,
+		repo:         repo,
+		auditService: auditService,
+		permissions:  permissions,
 	}
 }
 
-// CreateProject creates a new project (only managers and admins can create projects)
-func (s *service) CreateProject(ctx context.Context, req CreateProjectRequest, createdBy uuid.UUID) (*ProjectResponse, error) {
+// CreateProject creates a new project (requires project.create, checked by the route)
+func (s *service) CreateProject(ctx context.Context, req CreateProjectRequest, principal *common.Principal) (*ProjectResponse, error) {
 	// Check if project with same name already exists
 	exists, err := s.repo.ExistsByName(ctx, req.Name)
 	if err != nil {
@@ -43,6 +52,7 @@ func (s *service) CreateProject(ctx context.Context, req CreateProjectRequest, c
 
 	// Create project entity
 	now := time.Now()
+	createdBy := principal.UserID
 	project := &entity.Project{
 		ID:          uuid.New(),
 		Name:        req.Name,
@@ -73,6 +83,19 @@ func (s *service) GetProject(ctx context.Context, id uuid.UUID) (*ProjectRespons
 	return s.entityToResponse(project), nil
 }
 
+// GetProjectHistory retrieves the changes made to a project
+func (s *service) GetProjectHistory(ctx context.Context, id uuid.UUID, page audit.PageRequest, principal *common.Principal) (*audit.AuditLogListResponse, error) {
+	project, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveProject
+	}
+	if project == nil {
+		return nil, common.ErrProjectNotFound
+	}
+
+	return s.auditService.GetHistory(ctx, project.TableName(), project.ID, page, audit.CanReadClientMetadata(ctx, s.permissions, principal))
+}
+
 // GetAllProjects retrieves all projects with pagination
 func (s *service) GetAllProjects(ctx context.Context, pagination PaginationRequest) (*ProjectListResponse, error) {
 	pagination.SetDefaults()
@@ -96,8 +119,8 @@ func (s *service) GetAllProjects(ctx context.Context, pagination PaginationReque
 	}, nil
 }
 
-// UpdateProject updates an existing project (only managers, admins, or creator can update)
-func (s *service) UpdateProject(ctx context.Context, id uuid.UUID, req UpdateProjectRequest, userID uuid.UUID, userRole string) (*ProjectResponse, error) {
+// UpdateProject updates an existing project (only its creator or users with project.update)
+func (s *service) UpdateProject(ctx context.Context, id uuid.UUID, req UpdateProjectRequest, principal *common.Principal) (*ProjectResponse, error) {
 	// Get existing project
 	project, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -107,11 +130,15 @@ func (s *service) UpdateProject(ctx context.Context, id uuid.UUID, req UpdatePro
 		return nil, common.ErrProjectNotFound
 	}
 
-	// Check permissions: only admin, manager, or creator can update
-	if !s.canManageProject(userRole, userID, project.CreatedBy) {
+	if !s.canManageProject(ctx, principal, policy.ProjectUpdate, project.CreatedBy) {
 		return nil, common.ErrForbidden
 	}
 
+	// The client edited an older version than the stored one
+	if req.Version != nil && *req.Version != project.Version {
+		return nil, common.ErrPreconditionFailed
+	}
+
 	// Update fields if provided
 	if req.Name != nil {
 		// Check if new name conflicts with existing projects
@@ -132,15 +159,85 @@ func (s *service) UpdateProject(ctx context.Context, id uuid.UUID, req UpdatePro
 	project.UpdatedAt = time.Now()
 
 	// Save changes
-	if err := s.repo.Update(ctx, project); err != nil {
+	updated, err := s.repo.Update(ctx, project)
+	if err != nil {
+		return nil, common.ErrFailedToUpdateProject
+	}
+	if !updated {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	return s.entityToResponse(project), nil
+}
+
+// PatchProject applies a merge patch or JSON patch to a project with the
+// permission rules of UpdateProject, and writes only the columns the patch
+// changed. A non-nil version must match the stored one.
+func (s *service) PatchProject(ctx context.Context, id uuid.UUID, patch common.Patch, version *int, principal *common.Principal) (*ProjectResponse, error) {
+	project, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveProject
+	}
+	if project == nil {
+		return nil, common.ErrProjectNotFound
+	}
+
+	if !s.canManageProject(ctx, principal, policy.ProjectUpdate, project.CreatedBy) {
+		return nil, common.ErrForbidden
+	}
+
+	if version != nil && *version != project.Version {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	doc := ProjectPatchDocument{
+		Name:        project.Name,
+		Description: project.Description,
+		Version:     project.Version,
+	}
+	changed, err := common.ApplyPatch(patch, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(changed, "version") {
+		return nil, common.ErrPreconditionFailed
+	}
+	if len(changed) == 0 {
+		return s.entityToResponse(project), nil
+	}
+
+	for _, field := range changed {
+		switch field {
+		case "name":
+			exists, err := s.repo.ExistsByNameExcludingID(ctx, doc.Name, id)
+			if err != nil {
+				return nil, common.ErrFailedToCheckProject
+			}
+			if exists {
+				return nil, common.ErrProjectNameAlreadyExists
+			}
+			project.Name = doc.Name
+		case "description":
+			project.Description = doc.Description
+		}
+	}
+
+	project.UpdatedAt = time.Now()
+
+	// The patch document's JSON names are the column names
+	updated, err := s.repo.Update(ctx, project, changed...)
+	if err != nil {
 		return nil, common.ErrFailedToUpdateProject
 	}
+	if !updated {
+		return nil, common.ErrPreconditionFailed
+	}
 
 	return s.entityToResponse(project), nil
 }
 
-// DeleteProject deletes a project (only managers, admins, or creator can delete)
-func (s *service) DeleteProject(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error {
+// DeleteProject deletes a project (only its creator or users with project.delete)
+func (s *service) DeleteProject(ctx context.Context, id uuid.UUID, principal *common.Principal) error {
 	// Get existing project
 	project, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -150,8 +247,7 @@ func (s *service) DeleteProject(ctx context.Context, id uuid.UUID, userID uuid.U
 		return common.ErrProjectNotFound
 	}
 
-	// Check permissions: only admin, manager, or creator can delete
-	if !s.canManageProject(userRole, userID, project.CreatedBy) {
+	if !s.canManageProject(ctx, principal, policy.ProjectDelete, project.CreatedBy) {
 		return common.ErrForbidden
 	}
 
@@ -163,24 +259,14 @@ func (s *service) DeleteProject(ctx context.Context, id uuid.UUID, userID uuid.U
 	return nil
 }
 
-// canManageProject checks if user can manage (update/delete) a project
-func (s *service) canManageProject(userRole string, userID uuid.UUID, createdBy *uuid.UUID) bool {
-	// Admin can manage any project
-	if userRole == "admin" {
-		return true
-	}
-
-	// Manager can manage any project
-	if userRole == "manager" {
-		return true
-	}
-
-	// Creator can manage their own project
-	if createdBy != nil && *createdBy == userID {
+// canManageProject checks if user can update or delete a project: creators
+// can always manage their own, everyone else needs the permission
+func (s *service) canManageProject(ctx context.Context, principal *common.Principal, permission policy.Permission, createdBy *uuid.UUID) bool {
+	if createdBy != nil && *createdBy == principal.UserID {
 		return true
 	}
 
-	return false
+	return s.permissions.Can(ctx, principal.Role, permission)
 }
 
 // entityToResponse converts a project entity to response DTO
@@ -190,6 +276,7 @@ func (s *service) entityToResponse(project *entity.Project) *ProjectResponse {
 		Name:        project.Name,
 		Description: project.Description,
 		CreatedBy:   project.CreatedBy,
+		Version:     project.Version,
 		CreatedAt:   project.CreatedAt,
 		UpdatedAt:   project.UpdatedAt,
 	}
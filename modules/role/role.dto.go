@@ -0,0 +1,29 @@
+package role
+
+// CreateRoleRequest represents the request to create a custom role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required" example:"reviewer"`
+	Description string   `json:"description" binding:"max=255" example:"Can read every task and the audit log"`
+	Permissions []string `json:"permissions" binding:"required,dive,required" example:"task.read,audit.read"`
+}
+
+// UpdateRoleRequest represents the request to update a custom role. The name
+// cannot be changed, since users refer to the role by it.
+type UpdateRoleRequest struct {
+	Description *string  `json:"description,omitempty" binding:"omitempty,max=255" example:"Can read every task"`
+	Permissions []string `json:"permissions,omitempty" binding:"omitempty,dive,required" example:"task.read"`
+}
+
+// RoleResponse represents a built-in or custom role in API responses
+type RoleResponse struct {
+	Name        string   `json:"name" example:"reviewer"`
+	Description string   `json:"description" example:"Can read every task and the audit log"`
+	Permissions []string `json:"permissions" example:"audit.read,task.read"`
+	BuiltIn     bool     `json:"built_in" example:"false"`
+}
+
+// PermissionsResponse represents the permissions of the current user
+type PermissionsResponse struct {
+	Role        string   `json:"role" example:"manager"`
+	Permissions []string `json:"permissions" example:"project.create,task.assign"`
+}
@@ -0,0 +1,175 @@
+package role
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// Handler handles HTTP requests for role operations
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new role handler instance
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetMyPermissions handles requests for the current user's permissions
+//
+//	@Summary		Get my permissions
+//	@Description	List the permissions granted by the current user's role, so clients can show only the actions the user may take
+//	@Tags			Roles
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=PermissionsResponse}	"Permissions retrieved successfully"
+//	@Failure		401	{object}	common.Problem									"Unauthorized"
+//	@Failure		500	{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/user/me/permissions [get]
+func (h *Handler) GetMyPermissions(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.GetPermissions(c.Request.Context(), principal.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Permissions retrieved successfully")
+}
+
+// ListPermissions handles requests for every permission a role can be granted
+//
+//	@Summary		List permissions
+//	@Description	List every permission a custom role can be granted
+//	@Tags			Roles
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=[]string}	"Permissions retrieved successfully"
+//	@Failure		401	{object}	common.Problem						"Unauthorized"
+//	@Failure		403	{object}	common.Problem						"Forbidden"
+//	@Router			/api/v1/roles/permissions [get]
+func (h *Handler) ListPermissions(c *gin.Context) {
+	common.SuccessResponse(c, policy.NewSet(policy.All...).List(), "Permissions retrieved successfully")
+}
+
+// ListRoles handles requests to list roles
+//
+//	@Summary		List roles
+//	@Description	List the built-in roles and the custom roles with their permissions
+//	@Tags			Roles
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=[]RoleResponse}	"Roles retrieved successfully"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		403	{object}	common.Problem								"Forbidden"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/roles [get]
+func (h *Handler) ListRoles(c *gin.Context) {
+	response, err := h.service.ListRoles(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Roles retrieved successfully")
+}
+
+// CreateRole handles custom role creation requests
+//
+//	@Summary		Create a custom role
+//	@Description	Create a role with a chosen set of permissions, which can then be given to users on registration
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload			body		CreateRoleRequest						true	"Role name, description and permissions"
+//	@Param			Idempotency-Key	header		string									false	"Key that makes retries of this request return the first response"
+//	@Success		201				{object}	common.BaseResponse{data=RoleResponse}	"Role created successfully"
+//	@Failure		400				{object}	common.Problem							"Invalid role name or unknown permission"
+//	@Failure		401				{object}	common.Problem							"Unauthorized"
+//	@Failure		403				{object}	common.Problem							"Forbidden"
+//	@Failure		409				{object}	common.Problem							"Role already exists, or a request with this key is still in progress"
+//	@Failure		422				{object}	common.Problem							"Validation failed, or Idempotency-Key reused for a different request"
+//	@Failure		500				{object}	common.Problem							"Internal server error"
+//	@Router			/api/v1/roles [post]
+func (h *Handler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.CreateRole(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.CreatedResponse(c, response, "Role created successfully")
+}
+
+// UpdateRole handles custom role update requests
+//
+//	@Summary		Update a custom role
+//	@Description	Change the description or permissions of a custom role. Users holding it get the new permissions within a minute.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			name	path		string									true	"Role name"
+//	@Param			payload	body		UpdateRoleRequest						true	"Role changes"
+//	@Success		200		{object}	common.BaseResponse{data=RoleResponse}	"Role updated successfully"
+//	@Failure		400		{object}	common.Problem							"Unknown permission or built-in role"
+//	@Failure		401		{object}	common.Problem							"Unauthorized"
+//	@Failure		403		{object}	common.Problem							"Forbidden"
+//	@Failure		404		{object}	common.Problem							"Role not found"
+//	@Failure		422		{object}	common.Problem							"Validation failed"
+//	@Failure		500		{object}	common.Problem							"Internal server error"
+//	@Router			/api/v1/roles/{name} [put]
+func (h *Handler) UpdateRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.service.UpdateRole(c.Request.Context(), c.Param("name"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Role updated successfully")
+}
+
+// DeleteRole handles custom role deletion requests
+//
+//	@Summary		Delete a custom role
+//	@Description	Delete a custom role that is no longer held by any user
+//	@Tags			Roles
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			name	path		string				true	"Role name"
+//	@Success		200		{object}	common.BaseResponse	"Role deleted successfully"
+//	@Failure		400		{object}	common.Problem		"Built-in role"
+//	@Failure		401		{object}	common.Problem		"Unauthorized"
+//	@Failure		403		{object}	common.Problem		"Forbidden"
+//	@Failure		404		{object}	common.Problem		"Role not found"
+//	@Failure		409		{object}	common.Problem		"Role is still assigned to users"
+//	@Failure		500		{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/roles/{name} [delete]
+func (h *Handler) DeleteRole(c *gin.Context) {
+	if err := h.service.DeleteRole(c.Request.Context(), c.Param("name")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "Role deleted successfully")
+}
@@ -0,0 +1,73 @@
+package role
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for custom role data operations
+type Repository interface {
+	ListRoles(ctx context.Context) ([]entity.Role, error)
+	GetByName(ctx context.Context, name string) (*entity.Role, error)
+	Create(ctx context.Context, role *entity.Role) error
+	Update(ctx context.Context, role *entity.Role) error
+	Delete(ctx context.Context, role *entity.Role) error
+	CountUsers(ctx context.Context, name string) (int64, error)
+}
+
+// repository implements the Repository interface
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new role repository instance
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// ListRoles retrieves all custom roles ordered by name
+func (r *repository) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	var roles []entity.Role
+	err := r.db.WithContext(ctx).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// GetByName retrieves a custom role by name, or nil if there is none
+func (r *repository) GetByName(ctx context.Context, name string) (*entity.Role, error) {
+	var role entity.Role
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Create creates a new custom role
+func (r *repository) Create(ctx context.Context, role *entity.Role) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+// Update updates an existing custom role
+func (r *repository) Update(ctx context.Context, role *entity.Role) error {
+	return r.db.WithContext(ctx).Save(role).Error
+}
+
+// Delete deletes a custom role
+func (r *repository) Delete(ctx context.Context, role *entity.Role) error {
+	return r.db.WithContext(ctx).Delete(role).Error
+}
+
+// CountUsers counts the users holding a role
+func (r *repository) CountUsers(ctx context.Context, name string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,202 @@
+package role
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// roleNamePattern fits the users.role column
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// Service defines the interface for role business logic
+type Service interface {
+	ListRoles(ctx context.Context) ([]RoleResponse, error)
+	CreateRole(ctx context.Context, req CreateRoleRequest) (*RoleResponse, error)
+	UpdateRole(ctx context.Context, name string, req UpdateRoleRequest) (*RoleResponse, error)
+	DeleteRole(ctx context.Context, name string) error
+	GetPermissions(ctx context.Context, role string) (*PermissionsResponse, error)
+}
+
+// service implements the Service interface
+type service struct {
+	repo   Repository
+	engine *policy.Engine
+}
+
+// NewService creates a new role service instance
+func NewService(repo Repository, engine *policy.Engine) Service {
+	return &service{
+		repo:   repo,
+		engine: engine,
+	}
+}
+
+// ListRoles lists the built-in roles followed by the custom roles
+func (s *service) ListRoles(ctx context.Context) ([]RoleResponse, error) {
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveRole
+	}
+
+	responses := make([]RoleResponse, 0, len(policy.BuiltInRoles)+len(roles))
+	for _, name := range policy.BuiltInRoles {
+		permissions, _ := s.engine.Permissions(ctx, name)
+		responses = append(responses, RoleResponse{
+			Name:        name,
+			Permissions: permissions.List(),
+			BuiltIn:     true,
+		})
+	}
+	for i := range roles {
+		responses = append(responses, *toRoleResponse(&roles[i]))
+	}
+
+	return responses, nil
+}
+
+// CreateRole creates a custom role
+func (s *service) CreateRole(ctx context.Context, req CreateRoleRequest) (*RoleResponse, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, common.ErrInvalidRoleName
+	}
+	if policy.IsBuiltIn(req.Name) {
+		return nil, common.ErrRoleAlreadyExists
+	}
+	permissions, err := validPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByName(ctx, req.Name)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveRole
+	}
+	if existing != nil {
+		return nil, common.ErrRoleAlreadyExists
+	}
+
+	now := time.Now()
+	role := &entity.Role{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.repo.Create(ctx, role); err != nil {
+		return nil, common.ErrFailedToCreateRole
+	}
+	s.engine.Invalidate()
+
+	return toRoleResponse(role), nil
+}
+
+// UpdateRole changes the description or permissions of a custom role
+func (s *service) UpdateRole(ctx context.Context, name string, req UpdateRoleRequest) (*RoleResponse, error) {
+	role, err := s.getCustomRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		permissions, err := validPermissions(req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		role.Permissions = permissions
+	}
+	role.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(ctx, role); err != nil {
+		return nil, common.ErrFailedToUpdateRole
+	}
+	s.engine.Invalidate()
+
+	return toRoleResponse(role), nil
+}
+
+// DeleteRole deletes a custom role that no user holds anymore
+func (s *service) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.getCustomRole(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	users, err := s.repo.CountUsers(ctx, name)
+	if err != nil {
+		return common.ErrFailedToRetrieveRole
+	}
+	if users > 0 {
+		return common.ErrRoleInUse
+	}
+
+	if err := s.repo.Delete(ctx, role); err != nil {
+		return common.ErrFailedToDeleteRole
+	}
+	s.engine.Invalidate()
+
+	return nil
+}
+
+// GetPermissions returns the permissions of a role
+func (s *service) GetPermissions(ctx context.Context, role string) (*PermissionsResponse, error) {
+	permissions, err := s.engine.Permissions(ctx, role)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveRole
+	}
+
+	return &PermissionsResponse{
+		Role:        role,
+		Permissions: permissions.List(),
+	}, nil
+}
+
+// getCustomRole retrieves a custom role for changing it
+func (s *service) getCustomRole(ctx context.Context, name string) (*entity.Role, error) {
+	if policy.IsBuiltIn(name) {
+		return nil, common.ErrBuiltInRole
+	}
+
+	role, err := s.repo.GetByName(ctx, name)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveRole
+	}
+	if role == nil {
+		return nil, common.ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// validPermissions checks that every permission exists and returns them
+// without duplicates in a stable order
+func validPermissions(permissions []string) ([]string, error) {
+	set := make(policy.Set, len(permissions))
+	for _, p := range permissions {
+		if !policy.Valid(policy.Permission(p)) {
+			return nil, common.ErrUnknownPermission
+		}
+		set[policy.Permission(p)] = true
+	}
+	return set.List(), nil
+}
+
+// toRoleResponse converts a custom role to its response
+func toRoleResponse(role *entity.Role) *RoleResponse {
+	return &RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		BuiltIn:     false,
+	}
+}
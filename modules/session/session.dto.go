@@ -0,0 +1,23 @@
+package session
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionResponse represents an active login of the current user
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15"`
+	IPAddress  string    `json:"ip_address" example:"203.0.113.7"`
+	Current    bool      `json:"current" example:"true"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-07-19T10:30:00Z"`
+	LastSeenAt time.Time `json:"last_seen_at" example:"2025-07-19T12:05:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2025-07-20T10:30:00Z"`
+}
+
+// RevokeSessionsResponse represents the result of revoking all sessions of a user
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked" example:"3"`
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+)
+
+// Handler handles HTTP requests for session operations
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new session handler instance
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListSessions handles requests to list the current user's sessions
+//
+//	@Summary		List active sessions
+//	@Description	List where the current user is logged in. The session making the request is marked as current.
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=[]SessionResponse}	"Sessions retrieved successfully"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
+//	@Router			/api/v1/user/me/sessions [get]
+func (h *Handler) ListSessions(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.List(c.Request.Context(), principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Sessions retrieved successfully")
+}
+
+// RevokeSession handles requests to revoke one of the current user's sessions
+//
+//	@Summary		Revoke a session
+//	@Description	Log out one of the current user's sessions. Revoking the current session logs out the caller.
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string				true	"Session ID"
+//	@Success		200	{object}	common.BaseResponse	"Session revoked successfully"
+//	@Failure		400	{object}	common.Problem		"Invalid session ID"
+//	@Failure		401	{object}	common.Problem		"Unauthorized"
+//	@Failure		404	{object}	common.Problem		"Session not found"
+//	@Failure		500	{object}	common.Problem		"Internal server error"
+//	@Router			/api/v1/user/me/sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), principal.UserID, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, nil, "Session revoked successfully")
+}
+
+// RevokeUserSessions handles requests to revoke all sessions of a user
+//
+//	@Summary		Revoke all sessions of a user
+//	@Description	Log a user out everywhere (requires the session.revoke permission). API keys are not affected.
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string												true	"User ID"
+//	@Success		200	{object}	common.BaseResponse{data=RevokeSessionsResponse}	"Sessions revoked successfully"
+//	@Failure		400	{object}	common.Problem										"Invalid user ID"
+//	@Failure		401	{object}	common.Problem										"Unauthorized"
+//	@Failure		403	{object}	common.Problem										"Forbidden"
+//	@Failure		404	{object}	common.Problem										"User not found"
+//	@Failure		500	{object}	common.Problem										"Internal server error"
+//	@Router			/api/v1/users/{id}/sessions [delete]
+func (h *Handler) RevokeUserSessions(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
+		return
+	}
+
+	response, err := h.service.RevokeAll(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, response, "Sessions revoked successfully")
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/entity"
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for session data operations
+type Repository interface {
+	Create(ctx context.Context, session *entity.UserSession) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.UserSession, error)
+	ListActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]entity.UserSession, error)
+	TouchLastSeen(ctx context.Context, id uuid.UUID, ip string, now time.Time) error
+	Revoke(ctx context.Context, session *entity.UserSession, now time.Time) error
+	RevokeAllByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]uuid.UUID, error)
+}
+
+// repository implements the Repository interface
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new session repository instance
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// Create creates a new session
+func (r *repository) Create(ctx context.Context, session *entity.UserSession) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// GetByID retrieves a session by ID, or nil if it doesn't exist
+func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*entity.UserSession, error) {
+	var session entity.UserSession
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveByUser retrieves the unrevoked, unexpired sessions of a user, most recently seen first
+func (r *repository) ListActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]entity.UserSession, error) {
+	var sessions []entity.UserSession
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// TouchLastSeen records activity on a session without running hooks, so
+// every request isn't audited
+func (r *repository) TouchLastSeen(ctx context.Context, id uuid.UUID, ip string, now time.Time) error {
+	return r.db.WithContext(ctx).Model(&entity.UserSession{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   ip,
+		}).Error
+}
+
+// Revoke revokes a session
+func (r *repository) Revoke(ctx context.Context, session *entity.UserSession, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.UserSession{}).
+			Where("id = ? AND revoked_at IS NULL", session.ID).
+			Update("revoked_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		return entity.CreateAuditLog(tx, entity.AuditActionDelete, session.TableName(), session.ID, nil, map[string]interface{}{
+			"user_id":    session.UserID,
+			"user_agent": session.UserAgent,
+			"ip_address": session.IPAddress,
+		})
+	})
+}
+
+// RevokeAllByUser revokes every active session of a user and returns their IDs
+func (r *repository) RevokeAllByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entity.UserSession{}).
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		if err := tx.Model(&entity.UserSession{}).Where("id IN ?", ids).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return entity.CreateAuditLog(tx, entity.AuditActionDelete, (&entity.UserSession{}).TableName(), userID, nil, map[string]interface{}{
+			"revoked_sessions": len(ids),
+		})
+	})
+	return ids, err
+}
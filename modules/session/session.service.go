@@ -0,0 +1,197 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/db"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/modules/user"
+	"github.com/mnizarzr/dot-test/utils"
+)
+
+const (
+	// activeCacheTTL is how long a validated session is trusted without
+	// reading it again, which is also how often last seen is updated
+	activeCacheTTL = 5 * time.Minute
+
+	maxUserAgentLength = 512
+)
+
+// Service defines the interface for session business logic
+type Service interface {
+	Create(ctx context.Context, userID uuid.UUID) (*entity.UserSession, error)
+	ValidateSession(ctx context.Context, sessionID, userID, clientIP string) error
+	List(ctx context.Context, principal *common.Principal) ([]SessionResponse, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) (*RevokeSessionsResponse, error)
+}
+
+// service implements the Service interface
+type service struct {
+	repo     Repository
+	userRepo user.Repository
+	cache    *db.RedisClient
+}
+
+// NewService creates a new session service instance
+func NewService(repo Repository, userRepo user.Repository, cache *db.RedisClient) Service {
+	return &service{
+		repo:     repo,
+		userRepo: userRepo,
+		cache:    cache,
+	}
+}
+
+// Create starts a session for a login. The client is taken from the audit
+// information of the request context.
+func (s *service) Create(ctx context.Context, userID uuid.UUID) (*entity.UserSession, error) {
+	ipAddress, _ := ctx.Value(entity.AuditIPKey).(string)
+	userAgent, _ := ctx.Value(entity.AuditUserAgent).(string)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := &entity.UserSession{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.AccessTokenTTL),
+	}
+
+	if err := s.repo.Create(ctx, session); err != nil {
+		return nil, common.ErrFailedToCreateSession
+	}
+
+	return session, nil
+}
+
+// ValidateSession checks that the session of an access token was not revoked.
+// Revocations are checked in Redis first; sessions seen recently are trusted
+// for activeCacheTTL, and everything else is read from the database.
+func (s *service) ValidateSession(ctx context.Context, sessionID, userID, clientIP string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return common.ErrSessionRevoked
+	}
+
+	if revoked, err := s.cache.Exists(ctx, revokedKey(id)); err == nil && revoked > 0 {
+		return common.ErrSessionRevoked
+	} else if err == nil {
+		if active, err := s.cache.Exists(ctx, activeKey(id)); err == nil && active > 0 {
+			return nil
+		}
+	}
+
+	session, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return common.ErrFailedToRetrieveSessions
+	}
+
+	now := time.Now()
+	if session == nil || session.UserID.String() != userID || !session.Active(now) {
+		return common.ErrSessionRevoked
+	}
+
+	if err := s.repo.TouchLastSeen(ctx, id, clientIP, now); err != nil {
+		log.Printf("Failed to record activity of session %s: %v", id, err)
+	}
+	if err := s.cache.Set(ctx, activeKey(id), true, activeCacheTTL); err != nil {
+		log.Printf("Failed to cache session %s: %v", id, err)
+	}
+
+	return nil
+}
+
+// List lists the active sessions of a user, marking the one making the request
+func (s *service) List(ctx context.Context, principal *common.Principal) ([]SessionResponse, error) {
+	sessions, err := s.repo.ListActiveByUser(ctx, principal.UserID, time.Now())
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveSessions
+	}
+
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID.String() == principal.SessionID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// Revoke revokes an active session of a user
+func (s *service) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	session, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return common.ErrFailedToRetrieveSessions
+	}
+	if session == nil || session.UserID != userID || !session.Active(time.Now()) {
+		return common.ErrSessionNotFound
+	}
+
+	if err := s.repo.Revoke(ctx, session, time.Now()); err != nil {
+		return common.ErrFailedToRevokeSession
+	}
+
+	s.forget(ctx, id)
+	return nil
+}
+
+// RevokeAll revokes every active session of a user
+func (s *service) RevokeAll(ctx context.Context, userID uuid.UUID) (*RevokeSessionsResponse, error) {
+	userEntity, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if userEntity == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	ids, err := s.repo.RevokeAllByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, common.ErrFailedToRevokeSession
+	}
+
+	for _, id := range ids {
+		s.forget(ctx, id)
+	}
+
+	return &RevokeSessionsResponse{
+		Revoked: int64(len(ids)),
+	}, nil
+}
+
+// forget marks a revoked session in Redis, so instances that trust it from
+// the cache stop doing so immediately. The marker outlives every token of
+// the session.
+func (s *service) forget(ctx context.Context, id uuid.UUID) {
+	if err := s.cache.Set(ctx, revokedKey(id), true, utils.AccessTokenTTL); err != nil {
+		log.Printf("Failed to mark session %s as revoked: %v", id, err)
+	}
+	if err := s.cache.Delete(ctx, activeKey(id)); err != nil {
+		log.Printf("Failed to uncache session %s: %v", id, err)
+	}
+}
+
+func activeKey(id uuid.UUID) string {
+	return fmt.Sprintf("session:active:%s", id)
+}
+
+func revokedKey(id uuid.UUID) string {
+	return fmt.Sprintf("session:revoked:%s", id)
+}
@@ -39,6 +39,25 @@ type UpdateTaskRequest struct {
 	Priority    *string    `json:"priority,omitempty" binding:"omitempty,oneof=low medium high"`
 	DueDate     *time.Time `json:"due_date,omitempty"`
 	AssignedTo  *uuid.UUID `json:"assigned_to,omitempty"`
+
+	// Version is the version the client last read, for clients that cannot
+	// send If-Match. The header wins when both are set.
+	Version *int `json:"version,omitempty" binding:"omitempty,min=1" example:"3"`
+}
+
+// TaskPatchDocument holds the fields of a task that PATCH requests change.
+// Patches apply to the task's current values, so unlike UpdateTaskRequest a
+// patch can clear the due date or the assignee by setting them to null.
+type TaskPatchDocument struct {
+	Title       string     `json:"title" binding:"required,min=1,max=100" example:"Prepare release notes"`
+	Description string     `json:"description" binding:"max=1000"`
+	Status      string     `json:"status" binding:"required,oneof=pending in_progress completed" example:"in_progress"`
+	Priority    string     `json:"priority" binding:"required,oneof=low medium high" example:"high"`
+	DueDate     *time.Time `json:"due_date"`
+	AssignedTo  *uuid.UUID `json:"assigned_to"`
+
+	// Version fails the patch unless it matches the stored version
+	Version int `json:"version" example:"3"`
 }
 
 // AssignTaskRequest represents the request to assign a task to a user
@@ -57,6 +76,7 @@ type TaskResponse struct {
 	ProjectID   *uuid.UUID `json:"project_id"`
 	CreatedBy   *uuid.UUID `json:"created_by"`
 	AssignedTo  *uuid.UUID `json:"assigned_to"`
+	Version     int        `json:"version"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
@@ -1,11 +1,10 @@
 package task
 
 import (
-	"errors"
-
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/modules/audit"
 )
 
 // Handler handles HTTP requests for task operations
@@ -28,58 +27,36 @@ func NewHandler(service Service) *Handler {
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			request	body		CreateTaskRequest						true	"Task creation request"
-//	@Success		201		{object}	common.BaseResponse{data=TaskResponse}	"Task created successfully"
-//	@Failure		400		{object}	common.BaseResponse						"Bad request"
-//	@Failure		401		{object}	common.BaseResponse						"Unauthorized"
-//	@Failure		403		{object}	common.BaseResponse						"Forbidden"
-//	@Failure		404		{object}	common.BaseResponse						"Project or user not found"
-//	@Failure		500		{object}	common.BaseResponse						"Internal server error"
+//	@Param			request			body		CreateTaskRequest						true	"Task creation request"
+//	@Param			Idempotency-Key	header		string									false	"Key that makes retries of this request return the first response"
+//	@Success		201				{object}	common.BaseResponse{data=TaskResponse}	"Task created successfully"
+//	@Failure		400				{object}	common.Problem							"Bad request"
+//	@Failure		401				{object}	common.Problem							"Unauthorized"
+//	@Failure		403				{object}	common.Problem							"Forbidden"
+//	@Failure		404				{object}	common.Problem							"Project or user not found"
+//	@Failure		409				{object}	common.Problem							"Request with this key still in progress"
+//	@Failure		422				{object}	common.Problem							"Idempotency-Key reused for a different request"
+//	@Failure		500				{object}	common.Problem							"Internal server error"
 //	@Router			/api/v1/tasks [post]
 func (h *Handler) CreateTask(c *gin.Context) {
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
-		return
-	}
-
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
 	// Parse request
 	var req CreateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.ValidationErrorResponse(c, err)
+		c.Error(err)
 		return
 	}
 
 	// Create task
-	task, err := h.service.CreateTask(c.Request.Context(), req, userID, userRole.(string))
+	task, err := h.service.CreateTask(c.Request.Context(), req, principal)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrProjectNotFound):
-			common.ErrorResponse(c, 404, "Project not found")
-			return
-		case errors.Is(err, common.ErrUserNotFound):
-			common.ErrorResponse(c, 404, "Assigned user not found")
-			return
-		case errors.Is(err, common.ErrCannotAssignTask):
-			common.ErrorResponse(c, 403, "Insufficient permissions to assign tasks")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to create task")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
 	common.SuccessResponse(c, task, "Task created successfully")
@@ -88,64 +65,98 @@ func (h *Handler) CreateTask(c *gin.Context) {
 // GetTask handles get task by ID requests
 //
 //	@Summary		Get task by ID
-//	@Description	Get a task by its ID (with permission checks)
+//	@Description	Get a task by its ID (with permission checks). The response carries an ETag; send it in If-None-Match to get 304 when unchanged.
 //	@Tags			Task
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id	path		string									true	"Task ID"
-//	@Success		200	{object}	common.BaseResponse{data=TaskResponse}	"Task retrieved successfully"
-//	@Failure		400	{object}	common.BaseResponse						"Bad request"
-//	@Failure		401	{object}	common.BaseResponse						"Unauthorized"
-//	@Failure		403	{object}	common.BaseResponse						"Forbidden"
-//	@Failure		404	{object}	common.BaseResponse						"Task not found"
-//	@Failure		500	{object}	common.BaseResponse						"Internal server error"
+//	@Param			id				path		string									true	"Task ID"
+//	@Param			If-None-Match	header		string									false	"ETag of a cached copy"
+//	@Success		200				{object}	common.BaseResponse{data=TaskResponse}	"Task retrieved successfully"
+//	@Success		304				"Task not modified"
+//	@Failure		400				{object}	common.Problem							"Bad request"
+//	@Failure		401				{object}	common.Problem							"Unauthorized"
+//	@Failure		403				{object}	common.Problem							"Forbidden"
+//	@Failure		404				{object}	common.Problem							"Task not found"
+//	@Failure		500				{object}	common.Problem							"Internal server error"
 //	@Router			/api/v1/tasks/{id} [get]
 func (h *Handler) GetTask(c *gin.Context) {
 	// Parse task ID
 	taskIDStr := c.Param("id")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid task ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	// Get task
+	task, err := h.service.GetTask(c.Request.Context(), taskID, principal)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
+		c.Error(err)
 		return
 	}
 
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	if common.NotModified(c, task.Version) {
 		return
 	}
 
-	// Get task
-	task, err := h.service.GetTask(c.Request.Context(), taskID, userID, userRole.(string))
+	common.SuccessResponse(c, task, "Task retrieved successfully")
+}
+
+// GetTaskHistory handles task audit trail requests
+//
+//	@Summary		Get task history
+//	@Description	Get the changes made to a task, newest first (with permission checks). Client IP addresses, user agents and request IDs are only included with the audit.read permission.
+//	@Tags			Task
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id		path		string													true	"Task ID"
+//	@Param			cursor	query		string													false	"Cursor from the previous page"
+//	@Param			limit	query		int														false	"Page size (default: 20, max: 100)"
+//	@Success		200		{object}	common.BaseResponse{data=audit.AuditLogListResponse}	"Task history retrieved successfully"
+//	@Failure		400		{object}	common.Problem											"Bad request"
+//	@Failure		401		{object}	common.Problem											"Unauthorized"
+//	@Failure		403		{object}	common.Problem											"Forbidden"
+//	@Failure		404		{object}	common.Problem											"Task not found"
+//	@Failure		500		{object}	common.Problem											"Internal server error"
+//	@Router			/api/v1/tasks/{id}/history [get]
+func (h *Handler) GetTaskHistory(c *gin.Context) {
+	// Parse task ID
+	taskIDStr := c.Param("id")
+	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrTaskNotFound):
-			common.ErrorResponse(c, 404, "Task not found")
-			return
-		case errors.Is(err, common.ErrForbidden):
-			common.ErrorResponse(c, 403, "Insufficient permissions to view task")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to retrieve task")
-			return
-		}
+		c.Error(common.ErrInvalidID)
+		return
 	}
 
-	common.SuccessResponse(c, task, "Task retrieved successfully")
+	// Get user info
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	// Parse pagination
+	var page audit.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Get history
+	history, err := h.service.GetTaskHistory(c.Request.Context(), taskID, page, principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, history, "Task history retrieved successfully")
 }
 
 // GetTasksWithFilters handles get tasks with filters requests
@@ -163,41 +174,28 @@ func (h *Handler) GetTask(c *gin.Context) {
 //	@Param			page		query		int											false	"Page number (default: 1)"
 //	@Param			limit		query		int											false	"Page size (default: 10, max: 100)"
 //	@Success		200			{object}	common.BaseResponse{data=TaskListResponse}	"Tasks retrieved successfully"
-//	@Failure		400			{object}	common.BaseResponse							"Bad request"
-//	@Failure		401			{object}	common.BaseResponse							"Unauthorized"
-//	@Failure		500			{object}	common.BaseResponse							"Internal server error"
+//	@Failure		400			{object}	common.Problem								"Bad request"
+//	@Failure		401			{object}	common.Problem								"Unauthorized"
+//	@Failure		500			{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/tasks [get]
 func (h *Handler) GetTasksWithFilters(c *gin.Context) {
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
-		return
-	}
-
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
 	// Parse filter parameters
 	var filters TaskFilterRequest
 	if err := c.ShouldBindQuery(&filters); err != nil {
-		common.ValidationErrorResponse(c, err)
+		c.Error(err)
 		return
 	}
 
 	// Get tasks
-	response, err := h.service.GetTasksWithFilters(c.Request.Context(), filters, userID, userRole.(string))
+	response, err := h.service.GetTasksWithFilters(c.Request.Context(), filters, principal)
 	if err != nil {
-		common.InternalServerErrorResponse(c, "Failed to retrieve tasks")
+		c.Error(err)
 		return
 	}
 
@@ -207,84 +205,128 @@ func (h *Handler) GetTasksWithFilters(c *gin.Context) {
 // UpdateTask handles task update requests
 //
 //	@Summary		Update task
-//	@Description	Update an existing task (with permission checks)
+//	@Description	Update an existing task (with permission checks). Send the ETag from GET in If-Match, or the version in the body; a stale version gets 412.
 //	@Tags			Task
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id		path		string									true	"Task ID"
-//	@Param			request	body		UpdateTaskRequest						true	"Task update request"
-//	@Success		200		{object}	common.BaseResponse{data=TaskResponse}	"Task updated successfully"
-//	@Failure		400		{object}	common.BaseResponse						"Bad request"
-//	@Failure		401		{object}	common.BaseResponse						"Unauthorized"
-//	@Failure		403		{object}	common.BaseResponse						"Forbidden"
-//	@Failure		404		{object}	common.BaseResponse						"Task not found"
-//	@Failure		500		{object}	common.BaseResponse						"Internal server error"
+//	@Param			id			path		string									true	"Task ID"
+//	@Param			request		body		UpdateTaskRequest						true	"Task update request"
+//	@Param			If-Match	header		string									false	"ETag of the task being edited, required unless the body has a version"
+//	@Success		200			{object}	common.BaseResponse{data=TaskResponse}	"Task updated successfully"
+//	@Failure		400			{object}	common.Problem							"Bad request"
+//	@Failure		401			{object}	common.Problem							"Unauthorized"
+//	@Failure		403			{object}	common.Problem							"Forbidden"
+//	@Failure		404			{object}	common.Problem							"Task not found"
+//	@Failure		412			{object}	common.Problem							"Task changed since it was read"
+//	@Failure		428			{object}	common.Problem							"If-Match or version missing"
+//	@Failure		500			{object}	common.Problem							"Internal server error"
 //	@Router			/api/v1/tasks/{id} [put]
 func (h *Handler) UpdateTask(c *gin.Context) {
 	// Parse task ID
 	taskIDStr := c.Param("id")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid task ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	// Parse request
+	var req UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Only apply the update to the version the client has seen
+	req.Version, err = common.IfMatch(c, req.Version)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
+		c.Error(err)
 		return
 	}
 
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	// Update task
+	task, err := h.service.UpdateTask(c.Request.Context(), taskID, req, principal)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Parse request
-	var req UpdateTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		common.ValidationErrorResponse(c, err)
+	c.Header("ETag", common.ETag(task.Version))
+
+	common.SuccessResponse(c, task, "Task updated successfully")
+}
+
+// PatchTask handles partial task update requests
+//
+//	@Summary		Patch task
+//	@Description	Change some fields of a task with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902). Unlike PUT, null clears the due date or the assignee. Only the changed columns are written, and If-Match is optional.
+//	@Tags			Task
+//	@Accept			application/merge-patch+json,application/json-patch+json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id			path		string									true	"Task ID"
+//	@Param			request		body		TaskPatchDocument						true	"Merge patch of these fields, or a JSON Patch against them"
+//	@Param			If-Match	header		string									false	"ETag of the task being edited"
+//	@Success		200			{object}	common.BaseResponse{data=TaskResponse}	"Task updated successfully"
+//	@Failure		400			{object}	common.Problem							"Bad request"
+//	@Failure		401			{object}	common.Problem							"Unauthorized"
+//	@Failure		403			{object}	common.Problem							"Forbidden"
+//	@Failure		404			{object}	common.Problem							"Task or user not found"
+//	@Failure		409			{object}	common.Problem							"A test operation failed"
+//	@Failure		412			{object}	common.Problem							"Task changed since it was read"
+//	@Failure		415			{object}	common.Problem							"Unsupported patch format"
+//	@Failure		422			{object}	common.Problem							"Validation failed"
+//	@Failure		500			{object}	common.Problem							"Internal server error"
+//	@Router			/api/v1/tasks/{id} [patch]
+func (h *Handler) PatchTask(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
-	// Update task
-	task, err := h.service.UpdateTask(c.Request.Context(), taskID, req, userID, userRole.(string))
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	patch, err := common.ParsePatch(c)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrTaskNotFound):
-			common.ErrorResponse(c, 404, "Task not found")
-			return
-		case errors.Is(err, common.ErrForbidden):
-			common.ErrorResponse(c, 403, "Insufficient permissions to update task")
-			return
-		case errors.Is(err, common.ErrCannotAssignTask):
-			common.ErrorResponse(c, 403, "Insufficient permissions to assign tasks")
-			return
-		case errors.Is(err, common.ErrUserNotFound):
-			common.ErrorResponse(c, 404, "Assigned user not found")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to update task")
+		c.Error(err)
+		return
+	}
+
+	// If-Match is optional, since a patch only writes the fields it names
+	var version *int
+	if c.GetHeader("If-Match") != "" {
+		version, err = common.IfMatch(c, nil)
+		if err != nil {
+			c.Error(err)
 			return
 		}
 	}
 
+	task, err := h.service.PatchTask(c.Request.Context(), taskID, patch, version, principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", common.ETag(task.Version))
 	common.SuccessResponse(c, task, "Task updated successfully")
 }
 
 // AssignTask handles task assignment requests
 //
 //	@Summary		Assign task to user
-//	@Description	Assign a task to a user (managers and admins only)
+//	@Description	Assign a task to a user (requires the task.assign permission)
 //	@Tags			Task
 //	@Accept			json
 //	@Produce		json
@@ -292,127 +334,83 @@ func (h *Handler) UpdateTask(c *gin.Context) {
 //	@Param			id		path		string									true	"Task ID"
 //	@Param			request	body		AssignTaskRequest						true	"Task assignment request"
 //	@Success		200		{object}	common.BaseResponse{data=TaskResponse}	"Task assigned successfully"
-//	@Failure		400		{object}	common.BaseResponse						"Bad request"
-//	@Failure		401		{object}	common.BaseResponse						"Unauthorized"
-//	@Failure		403		{object}	common.BaseResponse						"Forbidden"
-//	@Failure		404		{object}	common.BaseResponse						"Task or user not found"
-//	@Failure		500		{object}	common.BaseResponse						"Internal server error"
+//	@Failure		400		{object}	common.Problem							"Bad request"
+//	@Failure		401		{object}	common.Problem							"Unauthorized"
+//	@Failure		403		{object}	common.Problem							"Forbidden"
+//	@Failure		404		{object}	common.Problem							"Task or user not found"
+//	@Failure		412		{object}	common.Problem							"Task changed while it was assigned"
+//	@Failure		500		{object}	common.Problem							"Internal server error"
 //	@Router			/api/v1/tasks/{id}/assign [put]
 func (h *Handler) AssignTask(c *gin.Context) {
 	// Parse task ID
 	taskIDStr := c.Param("id")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid task ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
-		return
-	}
-
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
 	// Parse request
 	var req AssignTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.ValidationErrorResponse(c, err)
+		c.Error(err)
 		return
 	}
 
 	// Assign task
-	task, err := h.service.AssignTask(c.Request.Context(), taskID, req, userID, userRole.(string))
+	task, err := h.service.AssignTask(c.Request.Context(), taskID, req, principal)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrTaskNotFound):
-			common.ErrorResponse(c, 404, "Task not found")
-			return
-		case errors.Is(err, common.ErrCannotAssignTask):
-			common.ErrorResponse(c, 403, "Insufficient permissions to assign tasks")
-			return
-		case errors.Is(err, common.ErrUserNotFound):
-			common.ErrorResponse(c, 404, "Assigned user not found")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to assign task")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
+	c.Header("ETag", common.ETag(task.Version))
+
 	common.SuccessResponse(c, task, "Task assigned successfully")
 }
 
 // DeleteTask handles task deletion requests
 //
 //	@Summary		Delete task
-//	@Description	Delete an existing task (creator, manager, or admin only)
+//	@Description	Delete an existing task (its creator or users with the task.delete permission)
 //	@Tags			Task
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
 //	@Param			id	path		string				true	"Task ID"
 //	@Success		200	{object}	common.BaseResponse	"Task deleted successfully"
-//	@Failure		400	{object}	common.BaseResponse	"Bad request"
-//	@Failure		401	{object}	common.BaseResponse	"Unauthorized"
-//	@Failure		403	{object}	common.BaseResponse	"Forbidden"
-//	@Failure		404	{object}	common.BaseResponse	"Task not found"
-//	@Failure		500	{object}	common.BaseResponse	"Internal server error"
+//	@Failure		400	{object}	common.Problem		"Bad request"
+//	@Failure		401	{object}	common.Problem		"Unauthorized"
+//	@Failure		403	{object}	common.Problem		"Forbidden"
+//	@Failure		404	{object}	common.Problem		"Task not found"
+//	@Failure		500	{object}	common.Problem		"Internal server error"
 //	@Router			/api/v1/tasks/{id} [delete]
 func (h *Handler) DeleteTask(c *gin.Context) {
 	// Parse task ID
 	taskIDStr := c.Param("id")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid task ID")
+		c.Error(common.ErrInvalidID)
 		return
 	}
 
 	// Get user info
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found")
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID")
-		return
-	}
-
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		common.ErrorResponse(c, 401, "User role not found")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
 	// Delete task
-	err = h.service.DeleteTask(c.Request.Context(), taskID, userID, userRole.(string))
+	err = h.service.DeleteTask(c.Request.Context(), taskID, principal)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrTaskNotFound):
-			common.ErrorResponse(c, 404, "Task not found")
-			return
-		case errors.Is(err, common.ErrForbidden):
-			common.ErrorResponse(c, 403, "Insufficient permissions to delete task")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to delete task")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
 	common.SuccessResponse(c, nil, "Task deleted successfully")
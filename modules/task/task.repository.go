@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository defines the interface for task data operations
@@ -15,7 +16,7 @@ type Repository interface {
 	GetByProjectID(ctx context.Context, projectID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error)
 	GetByAssignedUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error)
 	GetWithFilters(ctx context.Context, filters TaskFilterRequest) ([]*entity.Task, int64, error)
-	Update(ctx context.Context, task *entity.Task) error
+	Update(ctx context.Context, task *entity.Task, columns ...string) (bool, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetUserTasksInProject(ctx context.Context, userID, projectID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error)
 }
@@ -128,9 +129,44 @@ func (r *repository) GetWithFilters(ctx context.Context, filters TaskFilterReque
 	return tasks, total, err
 }
 
-// Update updates an existing task
-func (r *repository) Update(ctx context.Context, task *entity.Task) error {
-	return r.db.WithContext(ctx).Save(task).Error
+// Update saves a task if the stored one is still at task.Version, and bumps
+// the version. Only the given columns are written when there are any. It
+// returns false if another update or a delete came first.
+func (r *repository) Update(ctx context.Context, task *entity.Task, columns ...string) (bool, error) {
+	updated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the row so no update lands between the check and the save
+		var stored entity.Task
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("version").
+			Where("id = ?", task.ID).
+			First(&stored).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if stored.Version != task.Version {
+			return nil
+		}
+
+		task.Version++
+		if len(columns) == 0 {
+			err = tx.Save(task).Error
+		} else {
+			// Copy the columns, so appending cannot write into the caller's slice
+			cols := append(append([]string{}, columns...), "version", "updated_at")
+			err = tx.Model(task).Select(cols).Updates(task).Error
+		}
+		if err != nil {
+			task.Version--
+			return err
+		}
+		updated = true
+		return nil
+	})
+	return updated, err
 }
 
 // Delete deletes a task
@@ -2,24 +2,32 @@ package task
 
 import (
 	"context"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
 	"github.com/mnizarzr/dot-test/common"
 	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/jobs"
+	"github.com/mnizarzr/dot-test/modules/audit"
 	"github.com/mnizarzr/dot-test/modules/project"
 	"github.com/mnizarzr/dot-test/modules/user"
+	"github.com/mnizarzr/dot-test/policy"
 	"github.com/mnizarzr/dot-test/utils"
 )
 
 // Service defines the interface for task business logic
 type Service interface {
-	CreateTask(ctx context.Context, req CreateTaskRequest, createdBy uuid.UUID, userRole string) (*TaskResponse, error)
-	GetTask(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) (*TaskResponse, error)
-	GetTasksWithFilters(ctx context.Context, filters TaskFilterRequest, userID uuid.UUID, userRole string) (*TaskListResponse, error)
-	UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRequest, userID uuid.UUID, userRole string) (*TaskResponse, error)
-	AssignTask(ctx context.Context, taskID uuid.UUID, req AssignTaskRequest, userID uuid.UUID, userRole string) (*TaskResponse, error)
-	DeleteTask(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error
+	CreateTask(ctx context.Context, req CreateTaskRequest, principal *common.Principal) (*TaskResponse, error)
+	GetTask(ctx context.Context, id uuid.UUID, principal *common.Principal) (*TaskResponse, error)
+	GetTasksWithFilters(ctx context.Context, filters TaskFilterRequest, principal *common.Principal) (*TaskListResponse, error)
+	UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRequest, principal *common.Principal) (*TaskResponse, error)
+	PatchTask(ctx context.Context, id uuid.UUID, patch common.Patch, version *int, principal *common.Principal) (*TaskResponse, error)
+	AssignTask(ctx context.Context, taskID uuid.UUID, req AssignTaskRequest, principal *common.Principal) (*TaskResponse, error)
+	DeleteTask(ctx context.Context, id uuid.UUID, principal *common.Principal) error
+	GetTaskHistory(ctx context.Context, id uuid.UUID, page audit.PageRequest, principal *common.Principal) (*audit.AuditLogListResponse, error)
 }
 
 // service implements the Service interface
@@ -27,19 +35,25 @@ type service struct {
 	repo           Repository
 	projectService project.Service
 	userService    user.Service
+	auditService   audit.Service
+	jobClient      *asynq.Client
+	permissions    *policy.Engine
 }
 
 // NewService creates a new task service instance
-func NewService(repo Repository, projectService project.Service, userService user.Service) Service {
+func NewService(repo Repository, projectService project.Service, userService user.Service, auditService audit.Service, jobClient *asynq.Client, permissions *policy.Engine) Service {
 	return &service{
 		repo:           repo,
 		projectService: projectService,
 		userService:    userService,
+		auditService:   auditService,
+		jobClient:      jobClient,
+		permissions:    permissions,
 	}
 }
 
 // CreateTask creates a new task (must belong to a project)
-func (s *service) CreateTask(ctx context.Context, req CreateTaskRequest, createdBy uuid.UUID, userRole string) (*TaskResponse, error) {
+func (s *service) CreateTask(ctx context.Context, req CreateTaskRequest, principal *common.Principal) (*TaskResponse, error) {
 	// Verify project exists
 	_, err := s.projectService.GetProject(ctx, req.ProjectID)
 	if err != nil {
@@ -51,8 +65,7 @@ func (s *service) CreateTask(ctx context.Context, req CreateTaskRequest, created
 
 	// If assigning to someone, verify user exists and check permissions
 	if req.AssignedTo != nil {
-		// Only managers and admins can assign tasks
-		if userRole != "admin" && userRole != "manager" {
+		if !s.permissions.Can(ctx, principal.Role, policy.TaskAssign) {
 			return nil, common.ErrCannotAssignTask
 		}
 
@@ -75,14 +88,11 @@ func (s *service) CreateTask(ctx context.Context, req CreateTaskRequest, created
 	}
 
 	// Adjust due date if it falls on a holiday
-	dueDate := req.DueDate
-	if dueDate != nil {
-		adjustedDate := s.adjustForHolidays(*dueDate)
-		dueDate = &adjustedDate
-	}
+	dueDate := s.adjustDueDate(req.DueDate)
 
 	// Create task entity
 	now := time.Now()
+	createdBy := principal.UserID
 	task := &entity.Task{
 		ID:          uuid.New(),
 		Title:       req.Title,
@@ -102,11 +112,15 @@ func (s *service) CreateTask(ctx context.Context, req CreateTaskRequest, created
 		return nil, common.ErrFailedToCreateTask
 	}
 
+	if task.AssignedTo != nil {
+		s.notifyAssignee(ctx, task, principal.UserID)
+	}
+
 	return s.entityToResponse(task), nil
 }
 
 // GetTask retrieves a task by ID with permission checks
-func (s *service) GetTask(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) (*TaskResponse, error) {
+func (s *service) GetTask(ctx context.Context, id uuid.UUID, principal *common.Principal) (*TaskResponse, error) {
 	task, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, common.ErrFailedToRetrieveTask
@@ -116,24 +130,38 @@ func (s *service) GetTask(ctx context.Context, id uuid.UUID, userID uuid.UUID, u
 	}
 
 	// Check permissions
-	if !s.canViewTask(task, userID, userRole) {
+	if !s.canViewTask(ctx, task, principal) {
 		return nil, common.ErrForbidden
 	}
 
 	return s.entityToResponse(task), nil
 }
 
+// GetTaskHistory retrieves the changes made to a task for users who can view it
+func (s *service) GetTaskHistory(ctx context.Context, id uuid.UUID, page audit.PageRequest, principal *common.Principal) (*audit.AuditLogListResponse, error) {
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveTask
+	}
+	if task == nil {
+		return nil, common.ErrTaskNotFound
+	}
+
+	if !s.canViewTask(ctx, task, principal) {
+		return nil, common.ErrForbidden
+	}
+
+	return s.auditService.GetHistory(ctx, task.TableName(), task.ID, page, audit.CanReadClientMetadata(ctx, s.permissions, principal))
+}
+
 // GetTasksWithFilters retrieves tasks with filters and permission checks
-func (s *service) GetTasksWithFilters(ctx context.Context, filters TaskFilterRequest, userID uuid.UUID, userRole string) (*TaskListResponse, error) {
+func (s *service) GetTasksWithFilters(ctx context.Context, filters TaskFilterRequest, principal *common.Principal) (*TaskListResponse, error) {
 	filters.SetDefaults()
 
-	switch userRole {
-	case "admin":
-	case "manager":
-	case "user":
-		filters.AssignedTo = &userID
-	default:
-		filters.AssignedTo = &userID
+	// Without task.read users only see the tasks assigned to them
+	if !s.permissions.Can(ctx, principal.Role, policy.TaskRead) {
+		assignedTo := principal.UserID
+		filters.AssignedTo = &assignedTo
 	}
 
 	tasks, total, err := s.repo.GetWithFilters(ctx, filters)
@@ -155,7 +183,7 @@ func (s *service) GetTasksWithFilters(ctx context.Context, filters TaskFilterReq
 }
 
 // UpdateTask updates an existing task with permission checks
-func (s *service) UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRequest, userID uuid.UUID, userRole string) (*TaskResponse, error) {
+func (s *service) UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRequest, principal *common.Principal) (*TaskResponse, error) {
 	task, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, common.ErrFailedToRetrieveTask
@@ -164,11 +192,20 @@ func (s *service) UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRe
 		return nil, common.ErrTaskNotFound
 	}
 
-	if !s.canUpdateTask(task, userID, userRole) {
+	canUpdateAny := s.permissions.Can(ctx, principal.Role, policy.TaskUpdate)
+	if !canUpdateAny && !isTaskCreator(task, principal.UserID) && !isTaskAssignee(task, principal.UserID) {
 		return nil, common.ErrForbidden
 	}
 
-	if userRole == "user" && task.AssignedTo != nil && *task.AssignedTo == userID {
+	// The client edited an older version than the stored one
+	if req.Version != nil && *req.Version != task.Version {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	previousAssignee := task.AssignedTo
+
+	// Assignees without task.update can only move their task along
+	if !canUpdateAny && isTaskAssignee(task, principal.UserID) {
 		if req.Status != nil {
 			task.Status = *req.Status
 		}
@@ -186,10 +223,10 @@ func (s *service) UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRe
 			task.Priority = *req.Priority
 		}
 		if req.DueDate != nil {
-			task.DueDate = req.DueDate
+			task.DueDate = s.adjustDueDate(req.DueDate)
 		}
 		if req.AssignedTo != nil {
-			if userRole != "admin" && userRole != "manager" {
+			if !s.permissions.Can(ctx, principal.Role, policy.TaskAssign) {
 				return nil, common.ErrCannotAssignTask
 			}
 			_, err := s.userService.GetUserByID(ctx, *req.AssignedTo)
@@ -202,16 +239,114 @@ func (s *service) UpdateTask(ctx context.Context, id uuid.UUID, req UpdateTaskRe
 
 	task.UpdatedAt = time.Now()
 
-	if err := s.repo.Update(ctx, task); err != nil {
+	updated, err := s.repo.Update(ctx, task)
+	if err != nil {
 		return nil, common.ErrFailedToUpdateTask
 	}
+	if !updated {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	if task.AssignedTo != nil && (previousAssignee == nil || *previousAssignee != *task.AssignedTo) {
+		s.notifyAssignee(ctx, task, principal.UserID)
+	}
 
 	return s.entityToResponse(task), nil
 }
 
-// AssignTask assigns a task to a user (managers and admins only)
-func (s *service) AssignTask(ctx context.Context, taskID uuid.UUID, req AssignTaskRequest, userID uuid.UUID, userRole string) (*TaskResponse, error) {
-	if userRole != "admin" && userRole != "manager" {
+// PatchTask applies a merge patch or JSON patch to a task with the permission
+// rules of UpdateTask, and writes only the columns the patch changed. A
+// non-nil version must match the stored one.
+func (s *service) PatchTask(ctx context.Context, id uuid.UUID, patch common.Patch, version *int, principal *common.Principal) (*TaskResponse, error) {
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveTask
+	}
+	if task == nil {
+		return nil, common.ErrTaskNotFound
+	}
+
+	canUpdateAny := s.permissions.Can(ctx, principal.Role, policy.TaskUpdate)
+	if !canUpdateAny && !isTaskCreator(task, principal.UserID) && !isTaskAssignee(task, principal.UserID) {
+		return nil, common.ErrForbidden
+	}
+
+	if version != nil && *version != task.Version {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	doc := TaskPatchDocument{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		Priority:    task.Priority,
+		DueDate:     task.DueDate,
+		AssignedTo:  task.AssignedTo,
+		Version:     task.Version,
+	}
+	changed, err := common.ApplyPatch(patch, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(changed, "version") {
+		return nil, common.ErrPreconditionFailed
+	}
+	if len(changed) == 0 {
+		return s.entityToResponse(task), nil
+	}
+
+	// Assignees without task.update can only move their task along
+	if !canUpdateAny && isTaskAssignee(task, principal.UserID) && !slices.Equal(changed, []string{"status"}) {
+		return nil, common.ErrForbidden
+	}
+
+	previousAssignee := task.AssignedTo
+	for _, field := range changed {
+		switch field {
+		case "title":
+			task.Title = doc.Title
+		case "description":
+			task.Description = doc.Description
+		case "status":
+			task.Status = doc.Status
+		case "priority":
+			task.Priority = doc.Priority
+		case "due_date":
+			task.DueDate = s.adjustDueDate(doc.DueDate)
+		case "assigned_to":
+			if !s.permissions.Can(ctx, principal.Role, policy.TaskAssign) {
+				return nil, common.ErrCannotAssignTask
+			}
+			if doc.AssignedTo != nil {
+				if _, err := s.userService.GetUserByID(ctx, *doc.AssignedTo); err != nil {
+					return nil, common.ErrUserNotFound
+				}
+			}
+			task.AssignedTo = doc.AssignedTo
+		}
+	}
+
+	task.UpdatedAt = time.Now()
+
+	// The patch document's JSON names are the column names
+	updated, err := s.repo.Update(ctx, task, changed...)
+	if err != nil {
+		return nil, common.ErrFailedToUpdateTask
+	}
+	if !updated {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	if task.AssignedTo != nil && (previousAssignee == nil || *previousAssignee != *task.AssignedTo) {
+		s.notifyAssignee(ctx, task, principal.UserID)
+	}
+
+	return s.entityToResponse(task), nil
+}
+
+// AssignTask assigns a task to a user (requires task.assign)
+func (s *service) AssignTask(ctx context.Context, taskID uuid.UUID, req AssignTaskRequest, principal *common.Principal) (*TaskResponse, error) {
+	if !s.permissions.Can(ctx, principal.Role, policy.TaskAssign) {
 		return nil, common.ErrCannotAssignTask
 	}
 
@@ -228,18 +363,27 @@ func (s *service) AssignTask(ctx context.Context, taskID uuid.UUID, req AssignTa
 		return nil, common.ErrUserNotFound
 	}
 
+	previousAssignee := task.AssignedTo
 	task.AssignedTo = &req.AssignedTo
 	task.UpdatedAt = time.Now()
 
-	if err := s.repo.Update(ctx, task); err != nil {
+	updated, err := s.repo.Update(ctx, task)
+	if err != nil {
 		return nil, common.ErrFailedToUpdateTask
 	}
+	if !updated {
+		return nil, common.ErrPreconditionFailed
+	}
+
+	if previousAssignee == nil || *previousAssignee != req.AssignedTo {
+		s.notifyAssignee(ctx, task, principal.UserID)
+	}
 
 	return s.entityToResponse(task), nil
 }
 
-// DeleteTask deletes a task (only creator, manager, or admin can delete)
-func (s *service) DeleteTask(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error {
+// DeleteTask deletes a task (only its creator or users with task.delete)
+func (s *service) DeleteTask(ctx context.Context, id uuid.UUID, principal *common.Principal) error {
 	task, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return common.ErrFailedToRetrieveTask
@@ -248,7 +392,7 @@ func (s *service) DeleteTask(ctx context.Context, id uuid.UUID, userID uuid.UUID
 		return common.ErrTaskNotFound
 	}
 
-	if !s.canDeleteTask(task, userID, userRole) {
+	if !isTaskCreator(task, principal.UserID) && !s.permissions.Can(ctx, principal.Role, policy.TaskDelete) {
 		return common.ErrForbidden
 	}
 
@@ -260,62 +404,22 @@ func (s *service) DeleteTask(ctx context.Context, id uuid.UUID, userID uuid.UUID
 }
 
 // canViewTask checks if user can view a task
-func (s *service) canViewTask(task *entity.Task, userID uuid.UUID, userRole string) bool {
-	if userRole == "admin" {
-		return true
-	}
-
-	if userRole == "manager" {
-		return true
-	}
-
-	if task.AssignedTo != nil && *task.AssignedTo == userID {
-		return true
-	}
-
-	if task.CreatedBy != nil && *task.CreatedBy == userID {
+func (s *service) canViewTask(ctx context.Context, task *entity.Task, principal *common.Principal) bool {
+	if isTaskAssignee(task, principal.UserID) || isTaskCreator(task, principal.UserID) {
 		return true
 	}
 
-	return false
+	return s.permissions.Can(ctx, principal.Role, policy.TaskRead)
 }
 
-// canUpdateTask checks if user can update a task
-func (s *service) canUpdateTask(task *entity.Task, userID uuid.UUID, userRole string) bool {
-	if userRole == "admin" {
-		return true
-	}
-
-	if userRole == "manager" {
-		return true
-	}
-
-	if task.CreatedBy != nil && *task.CreatedBy == userID {
-		return true
-	}
-
-	if task.AssignedTo != nil && *task.AssignedTo == userID {
-		return true
-	}
-
-	return false
+// isTaskCreator checks if user created a task
+func isTaskCreator(task *entity.Task, userID uuid.UUID) bool {
+	return task.CreatedBy != nil && *task.CreatedBy == userID
 }
 
-// canDeleteTask checks if user can delete a task
-func (s *service) canDeleteTask(task *entity.Task, userID uuid.UUID, userRole string) bool {
-	if userRole == "admin" {
-		return true
-	}
-
-	if userRole == "manager" {
-		return true
-	}
-
-	if task.CreatedBy != nil && *task.CreatedBy == userID {
-		return true
-	}
-
-	return false
+// isTaskAssignee checks if a task is assigned to user
+func isTaskAssignee(task *entity.Task, userID uuid.UUID) bool {
+	return task.AssignedTo != nil && *task.AssignedTo == userID
 }
 
 // entityToResponse converts a task entity to response DTO
@@ -330,11 +434,37 @@ func (s *service) entityToResponse(task *entity.Task) *TaskResponse {
 		ProjectID:   task.ProjectID,
 		CreatedBy:   task.CreatedBy,
 		AssignedTo:  task.AssignedTo,
+		Version:     task.Version,
 		CreatedAt:   task.CreatedAt,
 		UpdatedAt:   task.UpdatedAt,
 	}
 }
 
+// notifyAssignee enqueues a task assigned notification unless the actor assigned the task to themselves
+func (s *service) notifyAssignee(ctx context.Context, task *entity.Task, actorID uuid.UUID) {
+	if *task.AssignedTo == actorID {
+		return
+	}
+
+	job, err := jobs.NewTaskAssignedEmailTask(*task.AssignedTo, task.ID)
+	if err == nil {
+		_, err = s.jobClient.Enqueue(job, asynq.Queue("default"), asynq.MaxRetry(3))
+	}
+	if err != nil {
+		log.Printf("Failed to enqueue task assigned email for task %s: %v", task.ID, err)
+	}
+}
+
+// adjustDueDate moves a due date off holidays and weekends. Creating,
+// updating and patching a task all go through it.
+func (s *service) adjustDueDate(dueDate *time.Time) *time.Time {
+	if dueDate == nil {
+		return nil
+	}
+	adjustedDate := s.adjustForHolidays(*dueDate)
+	return &adjustedDate
+}
+
 // adjustForHolidays adjusts the date to the next business day if it's a holiday
 func (s *service) adjustForHolidays(date time.Time) time.Time {
 	adjustedDate := date
@@ -0,0 +1,195 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/policy"
+)
+
+// fakeTaskRepo holds one task. Update reports whether the stored version
+// still matches, as the conditional update of the real repository does.
+type fakeTaskRepo struct {
+	task          *entity.Task
+	storedVersion int
+	updates       int
+}
+
+func (r *fakeTaskRepo) Create(ctx context.Context, task *entity.Task) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeTaskRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Task, error) {
+	if r.task == nil || r.task.ID != id {
+		return nil, nil
+	}
+	copied := *r.task
+	return &copied, nil
+}
+
+func (r *fakeTaskRepo) GetByProjectID(ctx context.Context, projectID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *fakeTaskRepo) GetByAssignedUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *fakeTaskRepo) GetWithFilters(ctx context.Context, filters TaskFilterRequest) ([]*entity.Task, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *fakeTaskRepo) Update(ctx context.Context, task *entity.Task, columns ...string) (bool, error) {
+	r.updates++
+	if task.Version != r.storedVersion {
+		return false, nil
+	}
+	task.Version++
+	r.storedVersion = task.Version
+	return true, nil
+}
+
+func (r *fakeTaskRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeTaskRepo) GetUserTasksInProject(ctx context.Context, userID, projectID uuid.UUID, offset, limit int) ([]*entity.Task, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func newTestPatchService() (*service, *fakeTaskRepo, *common.Principal) {
+	creator := uuid.New()
+	repo := &fakeTaskRepo{
+		task: &entity.Task{
+			ID:        uuid.New(),
+			Title:     "Write report",
+			Status:    StatusPending,
+			Priority:  PriorityMedium,
+			CreatedBy: &creator,
+			Version:   3,
+		},
+		storedVersion: 3,
+	}
+	s := &service{repo: repo, permissions: policy.NewEngine(nil)}
+	return s, repo, &common.Principal{UserID: creator, Role: policy.RoleUser}
+}
+
+func parseTestPatch(t *testing.T, contentType, body string) common.Patch {
+	t.Helper()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+
+	patch, err := common.ParsePatch(c)
+	if err != nil {
+		t.Fatalf("ParsePatch: %v", err)
+	}
+	return patch
+}
+
+func TestPatchTaskAppliesAtCurrentVersion(t *testing.T) {
+	s, repo, principal := newTestPatchService()
+	version := 3
+
+	response, err := s.PatchTask(context.Background(), repo.task.ID, parseTestPatch(t, common.MergePatchContentType, `{"status":"in_progress"}`), &version, principal)
+	if err != nil {
+		t.Fatalf("PatchTask: %v", err)
+	}
+	if response.Status != StatusInProgress || response.Version != 4 {
+		t.Errorf("response status=%s version=%d, want in_progress at 4", response.Status, response.Version)
+	}
+}
+
+func TestPatchTaskVersionChangeFailsPrecondition(t *testing.T) {
+	stale := 2
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		version     *int
+		concurrent  bool
+	}{
+		{"stale If-Match", common.MergePatchContentType, `{"status":"in_progress"}`, &stale, false},
+		{"merge patch changes version", common.MergePatchContentType, `{"status":"in_progress","version":2}`, nil, false},
+		{"JSON patch changes version", common.JSONPatchContentType, `[{"op":"replace","path":"/version","value":4}]`, nil, false},
+		{"updated concurrently", common.JSONPatchContentType, `[{"op":"replace","path":"/status","value":"in_progress"}]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, principal := newTestPatchService()
+			if tt.concurrent {
+				// Another request saved a new version after this one read the task
+				repo.storedVersion = 4
+			}
+
+			_, err := s.PatchTask(context.Background(), repo.task.ID, parseTestPatch(t, tt.contentType, tt.patch), tt.version, principal)
+			var appErr *common.AppError
+			if !errors.As(err, &appErr) || appErr.Status != http.StatusPreconditionFailed {
+				t.Fatalf("PatchTask error = %v, want 412 Precondition Failed", err)
+			}
+			if !tt.concurrent && repo.updates != 0 {
+				t.Error("the task was written despite the version mismatch")
+			}
+		})
+	}
+}
+
+func TestPatchTaskMatchingVersionInBody(t *testing.T) {
+	s, repo, principal := newTestPatchService()
+
+	patch := parseTestPatch(t, common.JSONPatchContentType, `[{"op":"test","path":"/version","value":3},{"op":"replace","path":"/priority","value":"high"}]`)
+	response, err := s.PatchTask(context.Background(), repo.task.ID, patch, nil, principal)
+	if err != nil {
+		t.Fatalf("PatchTask: %v", err)
+	}
+	if response.Priority != PriorityHigh {
+		t.Errorf("priority = %s, want high", response.Priority)
+	}
+}
+
+func TestDueDateMovesOffWeekendsOnUpdateAndPatch(t *testing.T) {
+	// Without a holiday API key only weekends are skipped
+	previous := config.Configs
+	config.Configs = &config.Config{}
+	t.Cleanup(func() { config.Configs = previous })
+
+	saturday := time.Date(2026, time.October, 24, 17, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, time.October, 26, 17, 0, 0, 0, time.UTC)
+
+	s, repo, principal := newTestPatchService()
+	response, err := s.UpdateTask(context.Background(), repo.task.ID, UpdateTaskRequest{DueDate: &saturday}, principal)
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if response.DueDate == nil || !response.DueDate.Equal(monday) {
+		t.Errorf("UpdateTask due date = %v, want %v", response.DueDate, monday)
+	}
+
+	for _, contentType := range []string{common.MergePatchContentType, common.JSONPatchContentType} {
+		s, repo, principal := newTestPatchService()
+		body := `{"due_date":"2026-10-24T17:00:00Z"}`
+		if contentType == common.JSONPatchContentType {
+			body = `[{"op":"replace","path":"/due_date","value":"2026-10-24T17:00:00Z"}]`
+		}
+
+		response, err := s.PatchTask(context.Background(), repo.task.ID, parseTestPatch(t, contentType, body), nil, principal)
+		if err != nil {
+			t.Fatalf("%s: PatchTask: %v", contentType, err)
+		}
+		if response.DueDate == nil || !response.DueDate.Equal(monday) {
+			t.Errorf("%s: PatchTask due date = %v, want %v", contentType, response.DueDate, monday)
+		}
+	}
+}
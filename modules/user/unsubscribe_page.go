@@ -0,0 +1,82 @@
+package user
+
+import (
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// unsubscribePage is the page shown to people who open an unsubscribe link
+// in a browser. The form posts the same one-click request mail clients send.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>body{font-family:Arial,sans-serif;max-width:480px;margin:64px auto;padding:0 16px;color:#333}button{background:#2563eb;color:#fff;border:0;border-radius:4px;padding:10px 20px;font-size:16px;cursor:pointer}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Action}}<form method="post" action="{{.Action}}">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">{{.Button}}</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+// unsubscribePageData is the text of the unsubscribe page. The form is only
+// shown when Action is set.
+type unsubscribePageData struct {
+	Lang    string
+	Title   string
+	Message string
+	Action  string
+	Button  string
+}
+
+// unsubscribeEventNames are the English names of the notification event
+// types, translated through the message catalogs
+var unsubscribeEventNames = map[string]string{
+	entity.NotificationEventAssigned:  "task assignment",
+	entity.NotificationEventMentioned: "mention",
+	entity.NotificationEventComment:   "comment",
+	entity.NotificationEventDueSoon:   "due date reminder",
+	entity.NotificationEventDigest:    "digest",
+}
+
+// renderUnsubscribePage writes the unsubscribe page in the request's language
+func renderUnsubscribePage(c *gin.Context, status int, data unsubscribePageData) {
+	lang := i18n.Language(c.Request.Context())
+	data.Lang = lang
+	data.Title = i18n.Message(lang, data.Title)
+	data.Button = i18n.Message(lang, data.Button)
+
+	c.Header("Content-Language", lang)
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := unsubscribePage.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
+
+// unsubscribeMessage translates a message about the emails of an event type
+func unsubscribeMessage(c *gin.Context, message, eventType string) string {
+	lang := i18n.Language(c.Request.Context())
+	name, ok := unsubscribeEventNames[eventType]
+	if !ok {
+		name = eventType
+	}
+	return i18n.Format(i18n.Message(lang, message), map[string]string{"event": i18n.Message(lang, name)})
+}
+
+// wantsHTML reports whether a request came from a browser rather than a mail
+// client or API client
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
@@ -8,15 +8,39 @@ import (
 
 // UserResponse represents the user data in API responses
 type UserResponse struct {
-	ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Name      string    `json:"name" example:"John Doe"`
-	Email     string    `json:"email" example:"john.doe@example.com"`
-	Role      string    `json:"role" example:"user"`
-	CreatedAt time.Time `json:"created_at" example:"2025-07-19T10:30:00Z"`
-	UpdatedAt time.Time `json:"updated_at" example:"2025-07-19T10:30:00Z"`
+	ID            uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name          string    `json:"name" example:"John Doe"`
+	Email         string    `json:"email" example:"john.doe@example.com"`
+	Role          string    `json:"role" example:"user"`
+	DigestCadence string    `json:"digest_cadence" example:"none"`
+	Language      string    `json:"language" example:"en"`
+	CreatedAt     time.Time `json:"created_at" example:"2025-07-19T10:30:00Z"`
+	UpdatedAt     time.Time `json:"updated_at" example:"2025-07-19T10:30:00Z"`
 }
 
 // ProfileResponse represents the response for user profile
 type ProfileResponse struct {
 	User UserResponse `json:"user"`
 }
+
+// PreferencesResponse represents a user's notification preferences
+type PreferencesResponse struct {
+	Preferences   map[string]string `json:"preferences" example:"assigned:email,digest:none"`
+	DigestCadence string            `json:"digest_cadence" example:"weekly"`
+	Language      string            `json:"language" example:"en"`
+}
+
+// UpdatePreferencesRequest represents the request to update notification preferences
+// and the language emails are sent in.
+// Preferences maps an event type to a channel; omitted event types are left unchanged.
+type UpdatePreferencesRequest struct {
+	Preferences   map[string]string `json:"preferences" binding:"omitempty,dive,keys,oneof=assigned mentioned comment due_soon digest,endkeys,oneof=email in_app webhook none"`
+	DigestCadence *string           `json:"digest_cadence,omitempty" binding:"omitempty,oneof=none daily weekly" example:"weekly"`
+	Language      *string           `json:"language,omitempty" binding:"omitempty,oneof=en id" example:"id"`
+}
+
+// UnsubscribeResponse represents the response for a one-click unsubscribe
+type UnsubscribeResponse struct {
+	EventType string `json:"event_type" example:"digest"`
+	Channel   string `json:"channel" example:"none"`
+}
@@ -1,11 +1,11 @@
 package user
 
 import (
-	"errors"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
+	"github.com/mnizarzr/dot-test/i18n"
 )
 
 // Handler handles HTTP requests for user operations
@@ -29,36 +29,152 @@ func NewHandler(service Service) *Handler {
 //	@Produce		json
 //	@Security		BearerAuth
 //	@Success		200	{object}	common.BaseResponse{data=ProfileResponse}	"User profile retrieved successfully"
-//	@Failure		401	{object}	common.BaseResponse							"Unauthorized"
-//	@Failure		404	{object}	common.BaseResponse							"User not found"
-//	@Failure		500	{object}	common.BaseResponse							"Internal server error"
+//	@Failure		401	{object}	common.Problem								"Unauthorized"
+//	@Failure		404	{object}	common.Problem								"User not found"
+//	@Failure		500	{object}	common.Problem								"Internal server error"
 //	@Router			/api/v1/user/me [get]
 func (h *Handler) GetProfile(c *gin.Context) {
-	// Get user ID from JWT middleware context
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		common.ErrorResponse(c, 401, "User ID not found in token")
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	// Get user profile
+	profile, err := h.service.GetProfile(c.Request.Context(), principal.UserID)
 	if err != nil {
-		common.ErrorResponse(c, 400, "Invalid user ID format")
+		c.Error(err)
 		return
 	}
 
-	// Get user profile
-	profile, err := h.service.GetProfile(c.Request.Context(), userID)
+	common.SuccessResponse(c, profile, "Profile retrieved successfully")
+}
+
+// GetPreferences handles get notification preferences requests
+//
+//	@Summary		Get notification preferences
+//	@Description	Get the channel chosen for each notification event type and the digest cadence
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	common.BaseResponse{data=PreferencesResponse}	"Preferences retrieved successfully"
+//	@Failure		401	{object}	common.Problem									"Unauthorized"
+//	@Failure		404	{object}	common.Problem									"User not found"
+//	@Failure		500	{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/user/me/preferences [get]
+func (h *Handler) GetPreferences(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.service.GetPreferences(c.Request.Context(), principal.UserID)
 	if err != nil {
-		switch {
-		case errors.Is(err, common.ErrUserNotFound):
-			common.ErrorResponse(c, 404, "User not found")
-			return
-		default:
-			common.InternalServerErrorResponse(c, "Failed to retrieve profile")
-			return
-		}
+		c.Error(err)
+		return
 	}
 
-	common.SuccessResponse(c, profile, "Profile retrieved successfully")
+	common.SuccessResponse(c, prefs, "Preferences retrieved successfully")
+}
+
+// UpdatePreferences handles notification preference update requests
+//
+//	@Summary		Update notification preferences
+//	@Description	Set the channel (email, in_app, webhook, none) per event type (assigned, mentioned, comment, due_soon, digest) and the digest cadence
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		UpdatePreferencesRequest						true	"Notification preferences"
+//	@Success		200		{object}	common.BaseResponse{data=PreferencesResponse}	"Preferences updated successfully"
+//	@Failure		401		{object}	common.Problem									"Unauthorized"
+//	@Failure		404		{object}	common.Problem									"User not found"
+//	@Failure		422		{object}	common.Problem									"Validation failed"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/user/me/preferences [put]
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	principal, ok := common.CurrentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	prefs, err := h.service.UpdatePreferences(c.Request.Context(), principal.UserID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	common.SuccessResponse(c, prefs, "Preferences updated successfully")
+}
+
+// ConfirmUnsubscribe handles unsubscribe links opened from notification emails
+//
+//	@Summary		Confirm unsubscribing from notification emails
+//	@Description	Show a page asking to confirm the unsubscribe link from an email footer. It changes nothing, since mail scanners and link prefetchers open links on their own; the page's form posts to the one-click endpoint.
+//	@Tags			User
+//	@Produce		html
+//	@Param			token	query		string	true	"Unsubscribe token"
+//	@Success		200		{string}	string	"Confirmation page"
+//	@Failure		400		{string}	string	"Invalid unsubscribe link page"
+//	@Router			/api/v1/unsubscribe [get]
+func (h *Handler) ConfirmUnsubscribe(c *gin.Context) {
+	eventType, err := h.service.CheckUnsubscribeToken(c.Query("token"))
+	if err != nil {
+		renderUnsubscribePage(c, http.StatusBadRequest, unsubscribePageData{
+			Title:   "Invalid unsubscribe link",
+			Message: i18n.Message(i18n.Language(c.Request.Context()), "This unsubscribe link is invalid. Use the link from your most recent email, or change your preferences in the app."),
+		})
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, unsubscribePageData{
+		Title:   "Unsubscribe",
+		Message: unsubscribeMessage(c, "Stop receiving {event} emails?", eventType),
+		Action:  c.Request.URL.RequestURI(),
+		Button:  "Unsubscribe",
+	})
+}
+
+// Unsubscribe handles one-click unsubscribe requests from mail clients (RFC 8058)
+// and the confirmation page. Browsers get a page, other clients JSON.
+//
+//	@Summary		Unsubscribe from notification emails
+//	@Description	Turn off one notification event type using the signed token from an email footer. Supports RFC 8058 one-click unsubscribe.
+//	@Tags			User
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			token	query		string											true	"Unsubscribe token"
+//	@Success		200		{object}	common.BaseResponse{data=UnsubscribeResponse}	"Unsubscribed successfully"
+//	@Failure		400		{object}	common.Problem									"Invalid unsubscribe token"
+//	@Failure		404		{object}	common.Problem									"User not found"
+//	@Failure		500		{object}	common.Problem									"Internal server error"
+//	@Router			/api/v1/unsubscribe [post]
+func (h *Handler) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.Error(common.ErrInvalidUnsubscribeToken)
+		return
+	}
+
+	response, err := h.service.Unsubscribe(c.Request.Context(), token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if wantsHTML(c) {
+		renderUnsubscribePage(c, http.StatusOK, unsubscribePageData{
+			Title:   "Unsubscribed",
+			Message: unsubscribeMessage(c, "You will no longer receive {event} emails. You can turn them back on in your notification preferences.", response.EventType),
+		})
+		return
+	}
+
+	common.SuccessResponse(c, response, "Unsubscribed successfully")
 }
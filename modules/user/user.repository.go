@@ -10,6 +10,7 @@ import (
 	"github.com/mnizarzr/dot-test/db"
 	"github.com/mnizarzr/dot-test/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository defines the interface for user data operations
@@ -20,6 +21,8 @@ type Repository interface {
 	Update(ctx context.Context, user *entity.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	EmailExists(ctx context.Context, email string) (bool, error)
+	GetPreferences(ctx context.Context, userID uuid.UUID) ([]entity.NotificationPreference, error)
+	SavePreferences(ctx context.Context, prefs []entity.NotificationPreference) error
 }
 
 // repository implements the Repository interface
@@ -145,3 +148,18 @@ func (r *repository) EmailExists(ctx context.Context, email string) (bool, error
 	}
 	return count > 0, nil
 }
+
+// GetPreferences retrieves the stored notification preferences of a user
+func (r *repository) GetPreferences(ctx context.Context, userID uuid.UUID) ([]entity.NotificationPreference, error) {
+	var prefs []entity.NotificationPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&prefs).Error
+	return prefs, err
+}
+
+// SavePreferences inserts or updates notification preferences
+func (r *repository) SavePreferences(ctx context.Context, prefs []entity.NotificationPreference) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"channel", "updated_at"}),
+	}).Create(&prefs).Error
+}
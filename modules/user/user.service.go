@@ -2,10 +2,12 @@ package user
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/common"
 	"github.com/mnizarzr/dot-test/entity"
+	"github.com/mnizarzr/dot-test/utils"
 )
 
 // Service defines the interface for user business logic
@@ -13,17 +15,23 @@ type Service interface {
 	GetProfile(ctx context.Context, id uuid.UUID) (*ProfileResponse, error)
 	GetUserByEmail(ctx context.Context, email string) (*UserResponse, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*UserResponse, error)
+	GetPreferences(ctx context.Context, id uuid.UUID) (*PreferencesResponse, error)
+	UpdatePreferences(ctx context.Context, id uuid.UUID, req UpdatePreferencesRequest) (*PreferencesResponse, error)
+	CheckUnsubscribeToken(token string) (string, error)
+	Unsubscribe(ctx context.Context, token string) (*UnsubscribeResponse, error)
 }
 
 // service implements the Service interface
 type service struct {
-	repo Repository
+	repo              Repository
+	unsubscribeSecret string
 }
 
 // NewService creates a new user service instance
-func NewService(repo Repository) Service {
+func NewService(repo Repository, unsubscribeSecret string) Service {
 	return &service{
-		repo: repo,
+		repo:              repo,
+		unsubscribeSecret: unsubscribeSecret,
 	}
 }
 
@@ -71,14 +79,137 @@ func (s *service) GetUserByID(ctx context.Context, id uuid.UUID) (*UserResponse,
 	return s.entityToResponse(user), nil
 }
 
+// GetPreferences retrieves a user's notification preferences, filling in defaults
+func (s *service) GetPreferences(ctx context.Context, id uuid.UUID) (*PreferencesResponse, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	prefs, err := s.repo.GetPreferences(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrievePreferences
+	}
+
+	return s.preferencesToResponse(user, prefs), nil
+}
+
+// UpdatePreferences stores the channels chosen per event type, the digest cadence and the email language
+func (s *service) UpdatePreferences(ctx context.Context, id uuid.UUID, req UpdatePreferencesRequest) (*PreferencesResponse, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	now := time.Now()
+	if len(req.Preferences) > 0 {
+		prefs := make([]entity.NotificationPreference, 0, len(req.Preferences))
+		for eventType, channel := range req.Preferences {
+			prefs = append(prefs, entity.NotificationPreference{
+				UserID:    id,
+				EventType: eventType,
+				Channel:   channel,
+				UpdatedAt: now,
+			})
+		}
+		if err := s.repo.SavePreferences(ctx, prefs); err != nil {
+			return nil, common.ErrFailedToUpdatePreferences
+		}
+	}
+
+	changed := false
+	if req.DigestCadence != nil && *req.DigestCadence != user.DigestCadence {
+		user.DigestCadence = *req.DigestCadence
+		changed = true
+	}
+	if req.Language != nil && *req.Language != user.Language {
+		user.Language = *req.Language
+		changed = true
+	}
+	if changed {
+		user.UpdatedAt = now
+		if err := s.repo.Update(ctx, user); err != nil {
+			return nil, common.ErrFailedToUpdateUser
+		}
+	}
+
+	return s.GetPreferences(ctx, id)
+}
+
+// CheckUnsubscribeToken verifies an unsubscribe token without changing any
+// preference and returns the event type it turns off
+func (s *service) CheckUnsubscribeToken(token string) (string, error) {
+	_, eventType, err := utils.ParseUnsubscribeToken(token, s.unsubscribeSecret)
+	if err != nil {
+		return "", common.ErrInvalidUnsubscribeToken
+	}
+	return eventType, nil
+}
+
+// Unsubscribe turns off a notification event type using a signed unsubscribe token
+func (s *service) Unsubscribe(ctx context.Context, token string) (*UnsubscribeResponse, error) {
+	userID, eventType, err := utils.ParseUnsubscribeToken(token, s.unsubscribeSecret)
+	if err != nil {
+		return nil, common.ErrInvalidUnsubscribeToken
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrFailedToRetrieveUser
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	pref := entity.NotificationPreference{
+		UserID:    userID,
+		EventType: eventType,
+		Channel:   entity.NotificationChannelNone,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.repo.SavePreferences(ctx, []entity.NotificationPreference{pref}); err != nil {
+		return nil, common.ErrFailedToUpdatePreferences
+	}
+
+	return &UnsubscribeResponse{
+		EventType: pref.EventType,
+		Channel:   pref.Channel,
+	}, nil
+}
+
+// preferencesToResponse merges stored preferences over the defaults
+func (s *service) preferencesToResponse(user *entity.User, prefs []entity.NotificationPreference) *PreferencesResponse {
+	channels := make(map[string]string, len(entity.NotificationEvents))
+	for _, eventType := range entity.NotificationEvents {
+		channels[eventType] = entity.DefaultNotificationChannel
+	}
+	for _, pref := range prefs {
+		channels[pref.EventType] = pref.Channel
+	}
+
+	return &PreferencesResponse{
+		Preferences:   channels,
+		DigestCadence: user.DigestCadence,
+		Language:      user.Language,
+	}
+}
+
 // entityToResponse converts a user entity to response DTO
 func (s *service) entityToResponse(user *entity.User) *UserResponse {
 	return &UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		Role:          user.Role,
+		DigestCadence: user.DigestCadence,
+		Language:      user.Language,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
 	}
 }
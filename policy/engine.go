@@ -0,0 +1,134 @@
+package policy
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mnizarzr/dot-test/entity"
+)
+
+// roleReloadInterval is how often the custom roles are read again, so changes
+// made on another instance are picked up without a restart
+const roleReloadInterval = time.Minute
+
+// RoleStore loads the custom roles
+type RoleStore interface {
+	ListRoles(ctx context.Context) ([]entity.Role, error)
+}
+
+// Engine resolves roles to their permissions. Built-in roles are defined in
+// code; custom roles are read from the store and cached.
+type Engine struct {
+	store RoleStore
+
+	mu       sync.Mutex
+	custom   map[string]Set
+	loadedAt time.Time
+}
+
+// NewEngine creates a policy engine reading custom roles from the store
+func NewEngine(store RoleStore) *Engine {
+	return &Engine{
+		store: store,
+	}
+}
+
+// Permissions returns the permissions of a role. Unknown roles have none.
+func (e *Engine) Permissions(ctx context.Context, role string) (Set, error) {
+	if permissions, ok := builtInPermissions[role]; ok {
+		return permissions, nil
+	}
+
+	custom, err := e.customRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return custom[role], nil
+}
+
+// Can reports whether a role has a permission. It denies when the custom
+// roles cannot be loaded.
+func (e *Engine) Can(ctx context.Context, role string, permission Permission) bool {
+	permissions, err := e.Permissions(ctx, role)
+	if err != nil {
+		log.Printf("Failed to load permissions of role %s: %v", role, err)
+		return false
+	}
+	return permissions.Has(permission)
+}
+
+// CanGrant reports whether a role holds every permission of another role, so
+// giving that role to someone does not hand out more than the granter has. It
+// denies when the custom roles cannot be loaded.
+func (e *Engine) CanGrant(ctx context.Context, granterRole, role string) bool {
+	granted, err := e.Permissions(ctx, role)
+	if err != nil {
+		log.Printf("Failed to load permissions of role %s: %v", role, err)
+		return false
+	}
+	permissions, err := e.Permissions(ctx, granterRole)
+	if err != nil {
+		log.Printf("Failed to load permissions of role %s: %v", granterRole, err)
+		return false
+	}
+	return permissions.Includes(granted)
+}
+
+// RoleExists reports whether a role is built in or a custom role
+func (e *Engine) RoleExists(ctx context.Context, role string) (bool, error) {
+	if IsBuiltIn(role) {
+		return true, nil
+	}
+
+	custom, err := e.customRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := custom[role]
+	return ok, nil
+}
+
+// Invalidate makes the next check read the custom roles again. It is called
+// after a role was changed on this instance.
+func (e *Engine) Invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.loadedAt = time.Time{}
+}
+
+// customRoles returns the cached custom roles, reading them again when they
+// were loaded more than roleReloadInterval ago. A failed reload keeps the
+// loaded roles until the next interval.
+func (e *Engine) customRoles(ctx context.Context) (map[string]Set, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.custom != nil && time.Since(e.loadedAt) < roleReloadInterval {
+		return e.custom, nil
+	}
+
+	roles, err := e.store.ListRoles(ctx)
+	if err != nil {
+		if e.custom != nil {
+			e.loadedAt = time.Now()
+			log.Printf("Failed to reload custom roles: %v", err)
+			return e.custom, nil
+		}
+		return nil, err
+	}
+
+	custom := make(map[string]Set, len(roles))
+	for _, role := range roles {
+		permissions := make(Set, len(role.Permissions))
+		for _, p := range role.Permissions {
+			permissions[Permission(p)] = true
+		}
+		custom[role.Name] = permissions
+	}
+
+	e.custom = custom
+	e.loadedAt = time.Now()
+	return custom, nil
+}
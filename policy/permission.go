@@ -0,0 +1,153 @@
+package policy
+
+import "sort"
+
+// Permission is an action a role allows
+type Permission string
+
+// Permission constants. Creators and assignees can always work with their own
+// tasks and projects; the read, update and delete permissions extend that to
+// everyone's.
+const (
+	TaskCreate Permission = "task.create"
+	TaskRead   Permission = "task.read"
+	TaskUpdate Permission = "task.update"
+	TaskDelete Permission = "task.delete"
+	TaskAssign Permission = "task.assign"
+
+	ProjectCreate Permission = "project.create"
+	ProjectUpdate Permission = "project.update"
+	ProjectDelete Permission = "project.delete"
+	ProjectReport Permission = "project.report" // project progress in digest emails
+
+	AuditRead Permission = "audit.read"
+
+	UserAssignRole       Permission = "user.assign_role" // register users with a role other than user
+	UserUnlock           Permission = "user.unlock"
+	SessionRevoke        Permission = "session.revoke" // log other users out
+	ServiceAccountManage Permission = "service_account.manage"
+	RoleManage           Permission = "role.manage"
+)
+
+// All lists every permission a role can be granted
+var All = []Permission{
+	TaskCreate,
+	TaskRead,
+	TaskUpdate,
+	TaskDelete,
+	TaskAssign,
+	ProjectCreate,
+	ProjectUpdate,
+	ProjectDelete,
+	ProjectReport,
+	AuditRead,
+	UserAssignRole,
+	UserUnlock,
+	SessionRevoke,
+	ServiceAccountManage,
+	RoleManage,
+}
+
+// Administrative lists the permissions over other users, roles and the audit
+// trail. Roles holding any of them must use MFA.
+var Administrative = []Permission{
+	AuditRead,
+	UserAssignRole,
+	UserUnlock,
+	SessionRevoke,
+	ServiceAccountManage,
+	RoleManage,
+}
+
+// Valid reports whether a permission exists
+func Valid(permission Permission) bool {
+	for _, p := range All {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is the set of permissions of a role
+type Set map[Permission]bool
+
+// NewSet creates a set of permissions
+func NewSet(permissions ...Permission) Set {
+	set := make(Set, len(permissions))
+	for _, p := range permissions {
+		set[p] = true
+	}
+	return set
+}
+
+// Has reports whether the set contains a permission
+func (s Set) Has(permission Permission) bool {
+	return s[permission]
+}
+
+// Includes reports whether the set contains every permission of another set
+func (s Set) Includes(other Set) bool {
+	for p := range other {
+		if !s[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAny reports whether the set contains at least one of the permissions
+func (s Set) HasAny(permissions ...Permission) bool {
+	for _, p := range permissions {
+		if s[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the permissions of the set in a stable order
+func (s Set) List() []string {
+	list := make([]string, 0, len(s))
+	for p := range s {
+		list = append(list, string(p))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Built-in role names
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleUser    = "user"
+)
+
+// BuiltInRoles lists the roles every installation has, from most to least privileged
+var BuiltInRoles = []string{RoleAdmin, RoleManager, RoleUser}
+
+// builtInPermissions are the permissions of the built-in roles. They are
+// defined here rather than in the database, so they cannot be edited.
+var builtInPermissions = map[string]Set{
+	RoleAdmin: NewSet(All...),
+	RoleManager: NewSet(
+		TaskCreate,
+		TaskRead,
+		TaskUpdate,
+		TaskDelete,
+		TaskAssign,
+		ProjectCreate,
+		ProjectUpdate,
+		ProjectDelete,
+		ProjectReport,
+	),
+	RoleUser: NewSet(
+		TaskCreate,
+	),
+}
+
+// IsBuiltIn reports whether a role is one of the built-in roles
+func IsBuiltIn(role string) bool {
+	_, ok := builtInPermissions[role]
+	return ok
+}
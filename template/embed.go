@@ -0,0 +1,21 @@
+// Package template bundles the email templates into the binary so rendering
+// does not depend on the working directory.
+package template
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:email
+var files embed.FS
+
+// Email returns the email templates rooted at the email directory
+func Email() fs.FS {
+	sub, err := fs.Sub(files, "email")
+	if err != nil {
+		// The directory is embedded at build time, so this cannot happen
+		panic(err)
+	}
+	return sub
+}
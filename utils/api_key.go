@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// API key prefixes. They make keys recognizable to secret scanners and let
+// the auth middleware tell them apart from JWTs.
+const (
+	APIKeyPrefix         = "dtt_"
+	PersonalTokenPrefix  = APIKeyPrefix + "pat_"
+	ServiceAPIKeyPrefix  = APIKeyPrefix + "sk_"
+	apiKeyVisibleRandom  = 6
+	apiKeyRandomByteSize = 24
+)
+
+// GenerateAPIKey generates a key with the given prefix. It returns the key
+// to show once, the visible prefix to list it by, and the hash to store.
+func GenerateAPIKey(prefix string) (key, visiblePrefix, hash string, err error) {
+	random := make([]byte, apiKeyRandomByteSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", err
+	}
+
+	key = prefix + base64.RawURLEncoding.EncodeToString(random)
+	return key, key[:len(prefix)+apiKeyVisibleRandom], HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes an API key for storage and lookup. Keys carry 192 random
+// bits, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mnizarzr/dot-test/config"
+)
+
+// Audit sink driver constants
+const (
+	AuditSinkNone   = "none"
+	AuditSinkSyslog = "syslog"
+	AuditSinkFile   = "file"
+	AuditSinkHTTP   = "http"
+)
+
+// AuditSink ships batches of audit events, each a JSON document, to an
+// external log pipeline
+type AuditSink interface {
+	Send(events []json.RawMessage) error
+	Close() error
+}
+
+// NewAuditSink creates the sink selected by the AUDIT_SINK setting, or nil when
+// streaming is disabled
+func NewAuditSink(cfg *config.Config) (AuditSink, error) {
+	switch cfg.AuditSink {
+	case AuditSinkNone, "":
+		return nil, nil
+	case AuditSinkSyslog:
+		return NewSyslogAuditSink(cfg.AuditSinkTarget, cfg.AppName)
+	case AuditSinkFile:
+		return NewFileAuditSink(cfg.AuditSinkTarget)
+	case AuditSinkHTTP:
+		return NewHTTPAuditSink(cfg.AuditSinkTarget, cfg.AuditSinkToken), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink: %s", cfg.AuditSink)
+	}
+}
+
+// SyslogAuditSink sends each event as an RFC 5424 message over UDP or TCP.
+// TCP messages use octet-counting framing (RFC 6587).
+type SyslogAuditSink struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	appName  string
+	hostname string
+	conn     net.Conn
+}
+
+// syslogPriority is facility local0 (16) with severity notice (5)
+const syslogPriority = 16*8 + 5
+
+// NewSyslogAuditSink creates a syslog sink for a target such as udp://host:514 or tcp://host:601
+func NewSyslogAuditSink(target, appName string) (*SyslogAuditSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog target %q, expected udp://host:port or tcp://host:port", target)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogAuditSink{
+		network:  u.Scheme,
+		address:  u.Host,
+		appName:  syslogHeaderField(appName),
+		hostname: syslogHeaderField(hostname),
+	}, nil
+}
+
+// Send writes one syslog message per event, redialing once if the connection was dropped
+func (s *SyslogAuditSink) Send(events []json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		msg := s.format(event)
+		if err := s.write(msg); err != nil {
+			s.reset()
+			if err := s.write(msg); err != nil {
+				s.reset()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// format builds an RFC 5424 message with the event as its MSG part
+func (s *SyslogAuditSink) format(event json.RawMessage) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %d audit - ",
+		syslogPriority, time.Now().UTC().Format(time.RFC3339Nano), s.hostname, s.appName, os.Getpid())
+	msg := append([]byte(header), event...)
+
+	if s.network == "tcp" {
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return msg
+}
+
+// write sends a message, dialing first if needed
+func (s *SyslogAuditSink) write(msg []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// reset drops the current connection so the next write redials
+func (s *SyslogAuditSink) reset() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Close closes the connection
+func (s *SyslogAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
+
+// syslogHeaderField makes a value safe for a syslog header field, which may
+// only contain printable ASCII without spaces
+func syslogHeaderField(value string) string {
+	out := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(out) < 48; i++ {
+		if c := value[i]; c > 32 && c < 127 {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return string(out)
+}
+
+// FileAuditSink appends events to a file as JSON lines
+type FileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink creates a JSON-lines sink, creating the directory if needed
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit sink directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit sink file: %w", err)
+	}
+
+	return &FileAuditSink{
+		file: file,
+	}, nil
+}
+
+// Send appends one line per event and syncs the file
+func (f *FileAuditSink) Send(events []json.RawMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := bufio.NewWriter(f.file)
+	for _, event := range events {
+		var line bytes.Buffer
+		if err := json.Compact(&line, event); err != nil {
+			return err
+		}
+		line.WriteByte('\n')
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file
+func (f *FileAuditSink) Close() error {
+	return f.file.Close()
+}
+
+// HTTPAuditSink posts each batch as a JSON array
+type HTTPAuditSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPAuditSink creates an HTTP sink, authenticating with a bearer token when given
+func NewHTTPAuditSink(url, token string) *HTTPAuditSink {
+	return &HTTPAuditSink{
+		url:   url,
+		token: token,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Send posts the batch and fails on any non-2xx response so the job is retried
+func (h *HTTPAuditSink) Send(events []json.RawMessage) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close is a no-op for the HTTP sink
+func (h *HTTPAuditSink) Close() error {
+	return nil
+}
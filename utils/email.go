@@ -1,85 +1,198 @@
 package utils
 
 import (
-	"bytes"
-	"html/template"
-	"path/filepath"
+	"math"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/mnizarzr/dot-test/config"
-	"gopkg.in/gomail.v2"
 )
 
 type EmailService struct {
-	config *config.Config
+	config   *config.Config
+	mailer   Mailer
+	renderer *TemplateRenderer
 }
 
 type EmailData struct {
 	Name     string
 	Email    string
 	AppName  string
-	Subject  string
 	Content  string
 	Role     string
 	Password string
+
+	TaskTitle   string
+	ProjectName string
+	DueDate     *time.Time
+
+	IPAddress      string
+	LockoutMinutes int
+
+	UnsubscribeURL string
+}
+
+// DigestEmailData represents the data rendered into digest emails
+type DigestEmailData struct {
+	Name          string
+	AppName       string
+	Period        string
+	AssignedTasks []DigestTask
+	DueSoonTasks  []DigestTask
+	OverdueTasks  []DigestTask
+	Projects      []DigestProject
+
+	UnsubscribeURL string
 }
 
-// NewEmailService creates a new email service instance
-func NewEmailService(cfg *config.Config) *EmailService {
+// DigestTask represents a task line in a digest email
+type DigestTask struct {
+	Title       string
+	ProjectName string
+	Status      string
+	Priority    string
+	DueDate     *time.Time
+}
+
+// DigestProject represents a project progress line in a digest email
+type DigestProject struct {
+	Name      string
+	Total     int64
+	Completed int64
+	Percent   int64
+}
+
+// NewEmailService creates a new email service instance that renders with the
+// given renderer and delivers through the given mailer
+func NewEmailService(cfg *config.Config, mailer Mailer, renderer *TemplateRenderer) *EmailService {
 	return &EmailService{
-		config: cfg,
+		config:   cfg,
+		mailer:   mailer,
+		renderer: renderer,
 	}
 }
 
-// SendEmail sends an email using the configured SMTP settings
+// SendEmail sends an email through the configured mailer
 func (e *EmailService) SendEmail(to, subject, htmlBody, textBody string) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", e.config.SmtpFrom)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/plain", textBody)
-	m.AddAlternative("text/html", htmlBody)
+	return e.mailer.Send(e.newMessage(to, subject, htmlBody, textBody))
+}
 
-	d := gomail.NewDialer(e.config.SmtpHost, e.config.SmtpPort, e.config.SmtpUser, e.config.SmtpPassword)
+// SendNotificationEmail sends an email that carries one-click unsubscribe headers (RFC 8058)
+func (e *EmailService) SendNotificationEmail(to, subject, htmlBody, textBody, unsubscribeURL string) error {
+	msg := e.newMessage(to, subject, htmlBody, textBody)
+	msg.Headers = map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
 
-	return d.DialAndSend(m)
+	return e.mailer.Send(msg)
 }
 
-// RenderTemplate renders an HTML email template with the provided data
-func (e *EmailService) RenderTemplate(templateName string, data EmailData) (string, error) {
-	templatePath := filepath.Join("template", "email", templateName+".html")
+// UnsubscribeURL builds the one-click unsubscribe link for a user and event type
+func (e *EmailService) UnsubscribeURL(userID uuid.UUID, eventType string) string {
+	token := GenerateUnsubscribeToken(userID, eventType, e.config.JWTSecret)
+	return e.config.AppUrl + "/api/v1/unsubscribe?token=" + token
+}
 
-	tmpl, err := template.ParseFiles(templatePath)
-	if err != nil {
-		return "", err
+// newMessage builds a message with plain text and HTML bodies
+func (e *EmailService) newMessage(to, subject, htmlBody, textBody string) *Message {
+	return &Message{
+		From:     e.config.SmtpFrom,
+		To:       to,
+		Subject:  subject,
+		TextBody: textBody,
+		HTMLBody: htmlBody,
 	}
+}
 
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, data)
+// send renders a template in the recipient's locale and delivers it,
+// adding unsubscribe headers when an unsubscribe URL is given
+func (e *EmailService) send(to, locale, templateName string, data interface{}, unsubscribeURL string) error {
+	rendered, err := e.renderer.Render(templateName, locale, data)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return buf.String(), nil
+	if unsubscribeURL == "" {
+		return e.SendEmail(to, rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+	}
+	return e.SendNotificationEmail(to, rendered.Subject, rendered.HTMLBody, rendered.TextBody, unsubscribeURL)
 }
 
 // SendWelcomeEmail sends a welcome email to a new user
-func (e *EmailService) SendWelcomeEmail(userEmail, userName, userRole, userPlainPassword string) error {
+func (e *EmailService) SendWelcomeEmail(userEmail, userName, locale, userRole, userPlainPassword string) error {
 	data := EmailData{
-		Name:    userName,
-		Email:   userEmail,
-		Role:	userRole,
+		Name:     userName,
+		Email:    userEmail,
+		Role:     userRole,
 		Password: userPlainPassword,
-		AppName: e.config.AppName,
-		Subject: "Welcome to " + e.config.AppName,
+		AppName:  e.config.AppName,
 	}
 
-	htmlBody, err := e.RenderTemplate("welcome", data)
-	if err != nil {
-		return err
+	return e.send(userEmail, locale, "welcome", data, "")
+}
+
+// SendTaskAssignedEmail notifies a user that a task has been assigned to them
+func (e *EmailService) SendTaskAssignedEmail(userEmail, userName, locale, taskTitle, projectName string, dueDate *time.Time, unsubscribeURL string) error {
+	data := EmailData{
+		Name:           userName,
+		Email:          userEmail,
+		AppName:        e.config.AppName,
+		TaskTitle:      taskTitle,
+		ProjectName:    projectName,
+		DueDate:        dueDate,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	return e.send(userEmail, locale, "task_assigned", data, unsubscribeURL)
+}
+
+// SendTaskDueSoonEmail reminds an assignee that a task is due soon
+func (e *EmailService) SendTaskDueSoonEmail(userEmail, userName, locale, taskTitle, projectName string, dueDate time.Time, unsubscribeURL string) error {
+	data := EmailData{
+		Name:           userName,
+		Email:          userEmail,
+		AppName:        e.config.AppName,
+		TaskTitle:      taskTitle,
+		ProjectName:    projectName,
+		DueDate:        &dueDate,
+		UnsubscribeURL: unsubscribeURL,
 	}
 
-	// Simple text fallback
-	textBody := "Welcome to " + e.config.AppName + "!\n\nHi " + userName + ",\n\nThank you for registering with us. We're excited to have you on board!\n\nBest regards,\nThe " + e.config.AppName + " Team"
+	return e.send(userEmail, locale, "task_due_soon", data, unsubscribeURL)
+}
+
+// SendTaskOverdueEmail notifies an assignee that a task is past its due date
+func (e *EmailService) SendTaskOverdueEmail(userEmail, userName, locale, taskTitle, projectName string, dueDate time.Time, unsubscribeURL string) error {
+	data := EmailData{
+		Name:           userName,
+		Email:          userEmail,
+		AppName:        e.config.AppName,
+		TaskTitle:      taskTitle,
+		ProjectName:    projectName,
+		DueDate:        &dueDate,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	return e.send(userEmail, locale, "task_overdue", data, unsubscribeURL)
+}
+
+// SendAccountLockedEmail tells a user that their account was locked after repeated failed logins
+func (e *EmailService) SendAccountLockedEmail(userEmail, userName, locale, ipAddress string, lockedFor time.Duration) error {
+	data := EmailData{
+		Name:           userName,
+		Email:          userEmail,
+		AppName:        e.config.AppName,
+		IPAddress:      ipAddress,
+		LockoutMinutes: int(math.Ceil(lockedFor.Minutes())),
+	}
+
+	return e.send(userEmail, locale, "account_locked", data, "")
+}
+
+// SendDigestEmail sends a daily or weekly task digest
+func (e *EmailService) SendDigestEmail(userEmail, locale string, data DigestEmailData) error {
+	data.AppName = e.config.AppName
 
-	return e.SendEmail(userEmail, data.Subject, htmlBody, textBody)
+	return e.send(userEmail, locale, "digest", data, data.UnsubscribeURL)
 }
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/mnizarzr/dot-test/i18n"
+)
+
+// Email template layout. Each locale directory holds a _common file with the
+// shared sentences of that language and one .html/.txt pair per email. Labels
+// shared by every language come from the i18n catalogs through the t function. Pages
+// define a "content" block rendered inside the base layout, and the .txt page
+// also defines the "subject".
+const (
+	emailLayoutDir   = "layouts"
+	emailPartialDir  = "partials"
+	emailCommonName  = "_common"
+	emailSubjectName = "subject"
+)
+
+var indonesianDays = [...]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+
+var indonesianMonths = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// RenderedEmail holds the output of rendering an email template
+type RenderedEmail struct {
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// emailTemplate is a parsed HTML and plain text template pair
+type emailTemplate struct {
+	html *htmlTemplate.Template
+	text *textTemplate.Template
+}
+
+// TemplateRenderer renders localized email templates parsed once at startup
+type TemplateRenderer struct {
+	defaultLocale string
+	templates     map[string]map[string]*emailTemplate
+}
+
+// NewTemplateRenderer parses every locale found in fsys. Templates missing
+// from a locale fall back to the default locale when rendering.
+func NewTemplateRenderer(fsys fs.FS, defaultLocale string) (*TemplateRenderer, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email templates: %w", err)
+	}
+
+	r := &TemplateRenderer{
+		defaultLocale: defaultLocale,
+		templates:     make(map[string]map[string]*emailTemplate),
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == emailLayoutDir || entry.Name() == emailPartialDir {
+			continue
+		}
+
+		locale := entry.Name()
+		templates, err := parseLocaleTemplates(fsys, locale)
+		if err != nil {
+			return nil, err
+		}
+		r.templates[locale] = templates
+	}
+
+	if _, ok := r.templates[defaultLocale]; !ok {
+		return nil, fmt.Errorf("email templates for default locale %q not found", defaultLocale)
+	}
+
+	return r, nil
+}
+
+// parseLocaleTemplates parses all pages of one locale together with the layout and partials
+func parseLocaleTemplates(fsys fs.FS, locale string) (map[string]*emailTemplate, error) {
+	pages, err := fs.Glob(fsys, path.Join(locale, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := emailTemplateFuncs(locale)
+	templates := make(map[string]*emailTemplate)
+
+	for _, page := range pages {
+		name := strings.TrimSuffix(path.Base(page), ".html")
+		if name == emailCommonName {
+			continue
+		}
+
+		html, err := htmlTemplate.New("base.html").Funcs(htmlTemplate.FuncMap(funcs)).ParseFS(fsys,
+			path.Join(emailLayoutDir, "base.html"),
+			path.Join(emailPartialDir, "*.html"),
+			path.Join(locale, emailCommonName+".html"),
+			page,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s/%s.html: %w", locale, name, err)
+		}
+
+		text, err := textTemplate.New("base.txt").Funcs(funcs).ParseFS(fsys,
+			path.Join(emailLayoutDir, "base.txt"),
+			path.Join(locale, emailCommonName+".txt"),
+			path.Join(locale, name+".txt"),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s/%s.txt: %w", locale, name, err)
+		}
+		if text.Lookup(emailSubjectName) == nil {
+			return nil, fmt.Errorf("email template %s/%s.txt does not define a subject", locale, name)
+		}
+
+		templates[name] = &emailTemplate{
+			html: html,
+			text: text,
+		}
+	}
+
+	return templates, nil
+}
+
+// emailTemplateFuncs returns the template functions for a locale
+func emailTemplateFuncs(locale string) textTemplate.FuncMap {
+	return textTemplate.FuncMap{
+		"formatDate": func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return formatDate(*t, locale)
+		},
+		"year": func() int {
+			return time.Now().Year()
+		},
+		"t": func(key string) string {
+			return i18n.Email(locale, key)
+		},
+	}
+}
+
+// formatDate formats a date as a long weekday and month name in the given locale
+func formatDate(t time.Time, locale string) string {
+	if locale == "id" {
+		return fmt.Sprintf("%s, %02d %s %d", indonesianDays[t.Weekday()], t.Day(), indonesianMonths[t.Month()-1], t.Year())
+	}
+	return t.Format("Monday, 02 January 2006")
+}
+
+// Render renders the subject and both bodies of a template in the requested
+// locale, falling back to the default locale when no variant exists
+func (r *TemplateRenderer) Render(name, locale string, data interface{}) (*RenderedEmail, error) {
+	tmpl, ok := r.lookup(name, locale)
+	if !ok {
+		return nil, fmt.Errorf("email template %q not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, emailSubjectName, data); err != nil {
+		return nil, fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text body of %s: %w", name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML body of %s: %w", name, err)
+	}
+
+	return &RenderedEmail{
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: html.String(),
+		TextBody: text.String(),
+	}, nil
+}
+
+// lookup finds a template by locale, accepting region tags such as "id-ID"
+func (r *TemplateRenderer) lookup(name, locale string) (*emailTemplate, bool) {
+	locale, _, _ = strings.Cut(strings.ToLower(locale), "-")
+	if tmpl, ok := r.templates[locale][name]; ok {
+		return tmpl, true
+	}
+	tmpl, ok := r.templates[r.defaultLocale][name]
+	return tmpl, ok
+}
+
+// Templates returns the names of all templates of the default locale
+func (r *TemplateRenderer) Templates() []string {
+	names := make([]string, 0, len(r.templates[r.defaultLocale]))
+	for name := range r.templates[r.defaultLocale] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Locales returns the names of all locales with templates
+func (r *TemplateRenderer) Locales() []string {
+	locales := make([]string, 0, len(r.templates))
+	for locale := range r.templates {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/config"
+	"github.com/mnizarzr/dot-test/template"
+)
+
+func newTestEmailService(t *testing.T) (*EmailService, *CaptureMailer) {
+	t.Helper()
+
+	renderer, err := NewTemplateRenderer(template.Email(), "en")
+	if err != nil {
+		t.Fatalf("NewTemplateRenderer: %v", err)
+	}
+
+	cfg := &config.Config{
+		AppName:   "Task Tracker",
+		AppUrl:    "https://tasks.example.com",
+		SmtpFrom:  "noreply@example.com",
+		JWTSecret: "secret",
+	}
+	mailer := NewCaptureMailer()
+	return NewEmailService(cfg, mailer, renderer), mailer
+}
+
+func TestSendTaskAssignedEmailAddsUnsubscribeHeaders(t *testing.T) {
+	service, mailer := newTestEmailService(t)
+
+	userID := uuid.New()
+	unsubscribeURL := service.UnsubscribeURL(userID, "assigned")
+	dueDate := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+	err := service.SendTaskAssignedEmail("jane@example.com", "Jane", "en", "Write report", "Apollo", &dueDate, unsubscribeURL)
+	if err != nil {
+		t.Fatalf("SendTaskAssignedEmail: %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("captured %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+
+	if msg.From != "noreply@example.com" || msg.To != "jane@example.com" {
+		t.Errorf("From/To = %q/%q", msg.From, msg.To)
+	}
+	if msg.Subject != "New task assigned: Write report" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+
+	if got, want := msg.Headers["List-Unsubscribe"], "<"+unsubscribeURL+">"; got != want {
+		t.Errorf("List-Unsubscribe = %q, want %q", got, want)
+	}
+	if got := msg.Headers["List-Unsubscribe-Post"]; got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got)
+	}
+
+	// The link in the header must turn off the event type the email was sent for
+	token := strings.TrimPrefix(unsubscribeURL, "https://tasks.example.com/api/v1/unsubscribe?token=")
+	gotUser, gotEvent, err := ParseUnsubscribeToken(token, "secret")
+	if err != nil || gotUser != userID || gotEvent != "assigned" {
+		t.Errorf("ParseUnsubscribeToken = %v, %q, %v", gotUser, gotEvent, err)
+	}
+
+	for name, body := range map[string]string{"text": msg.TextBody, "HTML": msg.HTMLBody} {
+		for _, want := range []string{"Jane", "Write report", "Apollo", "Monday, 02 March 2026"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s body does not contain %q", name, want)
+			}
+		}
+	}
+	if !strings.Contains(msg.TextBody, unsubscribeURL) {
+		t.Error("text body does not contain the unsubscribe link")
+	}
+}
+
+func TestSendTaskDueSoonEmailIsLocalized(t *testing.T) {
+	service, mailer := newTestEmailService(t)
+
+	dueDate := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	err := service.SendTaskDueSoonEmail("budi@example.com", "Budi", "id-ID", "Tulis laporan", "Apollo", dueDate, service.UnsubscribeURL(uuid.New(), "due_soon"))
+	if err != nil {
+		t.Fatalf("SendTaskDueSoonEmail: %v", err)
+	}
+
+	msg := mailer.Messages()[0]
+	if !strings.Contains(msg.TextBody, "Senin, 02 Maret 2026") {
+		t.Errorf("text body does not contain the Indonesian due date:\n%s", msg.TextBody)
+	}
+	if msg.Headers["List-Unsubscribe"] == "" {
+		t.Error("List-Unsubscribe header missing")
+	}
+}
+
+func TestTransactionalEmailsHaveNoUnsubscribeHeaders(t *testing.T) {
+	service, mailer := newTestEmailService(t)
+
+	if err := service.SendWelcomeEmail("jane@example.com", "Jane", "en", "member", "s3cret"); err != nil {
+		t.Fatalf("SendWelcomeEmail: %v", err)
+	}
+
+	msg := mailer.Messages()[0]
+	if _, ok := msg.Headers["List-Unsubscribe"]; ok {
+		t.Error("welcome email has a List-Unsubscribe header")
+	}
+	if !strings.Contains(msg.TextBody, "s3cret") {
+		t.Error("welcome email does not contain the password")
+	}
+
+	mailer.Reset()
+	if got := len(mailer.Messages()); got != 0 {
+		t.Errorf("captured %d messages after Reset, want 0", got)
+	}
+}
@@ -8,44 +8,53 @@ import (
 
 // JWTClaims represents the JWT claims
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// AccessTokenTTL is how long an access token is valid
+const AccessTokenTTL = 24 * time.Hour
+
 // GenerateJWT generates a new JWT token for a user
-func GenerateJWT(userID, email, role, secret string) (string, int64, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // 24 hours
-	expiresIn := int64(24 * 60 * 60)                 // 24 hours in seconds
+func GenerateJWT(userID, email, role, sessionID string, keys *JWTKeys) (string, int64, error) {
+	now := time.Now()
 
 	claims := &JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    keys.issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{keys.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", 0, err
 	}
 
-	return tokenString, expiresIn, nil
+	return tokenString, int64(AccessTokenTTL.Seconds()), nil
 }
 
 // ValidateJWT validates a JWT token and returns the claims
-func ValidateJWT(tokenString, secret string) (*JWTClaims, error) {
+func ValidateJWT(tokenString string, keys *JWTKeys) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.verificationKey,
+		jwt.WithValidMethods([]string{keys.algorithm}),
+		jwt.WithIssuer(keys.issuer),
+		jwt.WithAudience(keys.audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -57,3 +66,51 @@ func ValidateJWT(tokenString, secret string) (*JWTClaims, error) {
 
 	return claims, nil
 }
+
+// MFAChallengeTTL is how long a user has to enter their second factor after
+// the password was accepted
+const MFAChallengeTTL = 5 * time.Minute
+
+// mfaChallengeAudience is the audience of MFA challenge tokens. It differs
+// from the access token audience, so neither is accepted as the other.
+func (k *JWTKeys) mfaChallengeAudience() string {
+	return k.audience + ":mfa"
+}
+
+// GenerateMFAChallengeToken issues a short-lived token proving that the
+// password of a user was verified. It is signed like access tokens, with
+// its own audience.
+func GenerateMFAChallengeToken(userID string, keys *JWTKeys) (string, error) {
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Issuer:    keys.issuer,
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{keys.mfaChallengeAudience()},
+		ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	return keys.sign(claims)
+}
+
+// ValidateMFAChallengeToken validates an MFA challenge token and returns the user ID
+func ValidateMFAChallengeToken(tokenString string, keys *JWTKeys) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.verificationKey,
+		jwt.WithValidMethods([]string{keys.algorithm}),
+		jwt.WithIssuer(keys.issuer),
+		jwt.WithAudience(keys.mfaChallengeAudience()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid || claims.Subject == "" {
+		return "", jwt.ErrTokenMalformed
+	}
+
+	return claims.Subject, nil
+}
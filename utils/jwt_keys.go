@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mnizarzr/dot-test/config"
+)
+
+// JWT signing algorithms
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	// jwtKeyReloadInterval is how often the key directory is read again, so
+	// keys added by `dtt keys rotate` are picked up without a restart
+	jwtKeyReloadInterval = time.Minute
+
+	// jwtKeyIDTimeLayout starts every key ID, so IDs sort by creation time
+	jwtKeyIDTimeLayout = "20060102T150405Z"
+
+	jwtRSAKeyBits = 2048
+)
+
+// jwtKey is a key from the key directory. Files holding only a public key
+// verify tokens signed elsewhere but are never used for signing.
+type jwtKey struct {
+	id         string
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+// JWTKeys signs and verifies access tokens. With HS256 it uses the shared
+// JWT_SECRET; with RS256 or EdDSA it signs with the newest private key in
+// JWT_KEYS_DIR and accepts tokens signed by any key in it.
+type JWTKeys struct {
+	algorithm string
+	secret    []byte
+	dir       string
+	issuer    string
+	audience  string
+
+	mu         sync.Mutex
+	keys       map[string]*jwtKey
+	signingKey *jwtKey
+	loadedAt   time.Time
+}
+
+// NewJWTKeys creates the key set for the configured algorithm and loads the
+// key directory
+func NewJWTKeys(cfg *config.Config) (*JWTKeys, error) {
+	issuer := cfg.JWTIssuer
+	if issuer == "" {
+		issuer = cfg.AppUrl
+	}
+	audience := cfg.JWTAudience
+	if audience == "" {
+		audience = cfg.AppName
+	}
+
+	k := &JWTKeys{
+		algorithm: cfg.JWTAlgorithm,
+		secret:    []byte(cfg.JWTSecret),
+		dir:       cfg.JWTKeysDir,
+		issuer:    issuer,
+		audience:  audience,
+	}
+
+	switch k.algorithm {
+	case JWTAlgorithmHS256:
+		return k, nil
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", k.algorithm)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	if k.signingKey == nil {
+		return nil, fmt.Errorf("no %s signing key in %s, run `dtt keys rotate` to create one", k.algorithm, k.dir)
+	}
+	return k, nil
+}
+
+// sign signs claims with the current signing key
+func (k *JWTKeys) sign(claims jwt.Claims) (string, error) {
+	if k.algorithm == JWTAlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	k.mu.Lock()
+	k.reloadIfStale()
+	key := k.signingKey
+	k.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.algorithm), claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.privateKey)
+}
+
+// verificationKey returns the key a token was signed with
+func (k *JWTKeys) verificationKey(token *jwt.Token) (interface{}, error) {
+	if k.algorithm == JWTAlgorithmHS256 {
+		return k.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, ok := k.keys[kid]
+	if !ok {
+		// Another instance may have rotated already
+		k.reloadIfStale()
+		key, ok = k.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key.publicKey, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set. It is
+// empty with HS256, whose secret must never be published.
+func (k *JWTKeys) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	if k.algorithm == JWTAlgorithmHS256 {
+		return set
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.reloadIfStale()
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if jwk, ok := publicJWK(id, k.keys[id].publicKey); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// reloadIfStale reads the key directory again when it was last read more
+// than jwtKeyReloadInterval ago. A failed reload keeps the loaded keys.
+// The caller must hold k.mu.
+func (k *JWTKeys) reloadIfStale() {
+	if time.Since(k.loadedAt) < jwtKeyReloadInterval {
+		return
+	}
+	_ = k.load()
+}
+
+// load reads every key in the key directory. The caller must hold k.mu.
+func (k *JWTKeys) load() error {
+	k.loadedAt = time.Now()
+
+	keys, err := readJWTKeys(k.dir, k.algorithm)
+	if err != nil {
+		return err
+	}
+
+	var signingKey *jwtKey
+	for _, key := range keys {
+		if key.privateKey != nil && (signingKey == nil || key.id > signingKey.id) {
+			signingKey = key
+		}
+	}
+	if signingKey == nil && k.signingKey != nil {
+		return errors.New("key directory has no signing key")
+	}
+
+	k.keys = keys
+	k.signingKey = signingKey
+	return nil
+}
+
+// readJWTKeys reads the PEM keys of an algorithm from a directory, keyed by
+// their ID, which is the file name without the .pem extension
+func readJWTKeys(dir, algorithm string) (map[string]*jwtKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*jwtKey, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseJWTKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if jwtKeyAlgorithm(key.publicKey) != algorithm {
+			continue
+		}
+
+		key.id = strings.TrimSuffix(filepath.Base(path), ".pem")
+		keys[key.id] = key
+	}
+	return keys, nil
+}
+
+// parseJWTKey parses a PKCS #8 private key or PKIX public key
+func parseJWTKey(data []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		return &jwtKey{privateKey: signer, publicKey: signer.Public()}, nil
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &jwtKey{publicKey: parsed}, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// jwtKeyAlgorithm returns the signing algorithm a public key is used with
+func jwtKeyAlgorithm(publicKey crypto.PublicKey) string {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWTAlgorithmRS256
+	case ed25519.PublicKey:
+		return JWTAlgorithmEdDSA
+	default:
+		return ""
+	}
+}
+
+// GenerateJWTKey creates a new private key for the algorithm in the key
+// directory and returns its ID
+func GenerateJWTKey(dir, algorithm string, now time.Time) (string, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, jwtRSAKeyBits)
+	case JWTAlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("cannot generate keys for JWT_ALGORITHM %q, use %s or %s", algorithm, JWTAlgorithmRS256, JWTAlgorithmEdDSA)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	id := now.UTC().Format(jwtKeyIDTimeLayout) + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// PruneJWTKeys removes the private keys of an algorithm that were replaced
+// by a newer key more than retain ago, so no unexpired token can still be
+// signed by them. Public-only keys are left alone. It returns the removed IDs.
+func PruneJWTKeys(dir, algorithm string, retain time.Duration, now time.Time) ([]string, error) {
+	keys, err := readJWTKeys(dir, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for id, key := range keys {
+		if key.privateKey != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var removed []string
+	for i := 0; i < len(ids)-1; i++ {
+		replacedAt, err := time.Parse(jwtKeyIDTimeLayout, strings.SplitN(ids[i+1], "-", 2)[0])
+		if err != nil || now.Sub(replacedAt) < retain {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, ids[i]+".pem")); err != nil {
+			return removed, err
+		}
+		removed = append(removed, ids[i])
+	}
+	return removed, nil
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// publicJWK converts a public key to its JWK
+func publicJWK(id string, publicKey crypto.PublicKey) (JWK, bool) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: id,
+			Use: "sig",
+			Alg: JWTAlgorithmRS256,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: id,
+			Use: "sig",
+			Alg: JWTAlgorithmEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
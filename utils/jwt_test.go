@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mnizarzr/dot-test/config"
+)
+
+func newTestJWTKeys(t *testing.T, algorithm, secret string) *JWTKeys {
+	t.Helper()
+
+	cfg := &config.Config{
+		AppUrl:       "https://tasks.example.com",
+		AppName:      "Task Tracker",
+		JWTAlgorithm: algorithm,
+		JWTSecret:    secret,
+		JWTKeysDir:   t.TempDir(),
+	}
+	if algorithm != JWTAlgorithmHS256 {
+		if _, err := GenerateJWTKey(cfg.JWTKeysDir, algorithm, time.Now()); err != nil {
+			t.Fatalf("GenerateJWTKey: %v", err)
+		}
+	}
+
+	keys, err := NewJWTKeys(cfg)
+	if err != nil {
+		t.Fatalf("NewJWTKeys: %v", err)
+	}
+	return keys
+}
+
+func TestMFAChallengeTokenRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmEdDSA} {
+		keys := newTestJWTKeys(t, algorithm, "secret")
+
+		token, err := GenerateMFAChallengeToken("user-1", keys)
+		if err != nil {
+			t.Fatalf("%s: GenerateMFAChallengeToken: %v", algorithm, err)
+		}
+		userID, err := ValidateMFAChallengeToken(token, keys)
+		if err != nil || userID != "user-1" {
+			t.Errorf("%s: ValidateMFAChallengeToken = %q, %v", algorithm, userID, err)
+		}
+	}
+}
+
+func TestMFAChallengeAndAccessTokensAreNotInterchangeable(t *testing.T) {
+	for _, algorithm := range []string{JWTAlgorithmHS256, JWTAlgorithmEdDSA} {
+		keys := newTestJWTKeys(t, algorithm, "secret")
+
+		mfaToken, err := GenerateMFAChallengeToken("user-1", keys)
+		if err != nil {
+			t.Fatalf("GenerateMFAChallengeToken: %v", err)
+		}
+		if _, err := ValidateJWT(mfaToken, keys); err == nil {
+			t.Errorf("%s: an MFA challenge token was accepted as access token", algorithm)
+		}
+
+		accessToken, _, err := GenerateJWT("user-1", "jane@example.com", "admin", "session-1", keys)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		if _, err := ValidateMFAChallengeToken(accessToken, keys); err == nil {
+			t.Errorf("%s: an access token was accepted as MFA challenge token", algorithm)
+		}
+	}
+}
+
+func TestMFAChallengeTokenCannotBeForgedWithoutSecret(t *testing.T) {
+	// Without JWT_SECRET, tokens HMAC-signed with a key derived from it
+	// could be forged by anyone
+	keys := newTestJWTKeys(t, JWTAlgorithmEdDSA, "")
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    keys.issuer,
+		Subject:   "victim",
+		Audience:  jwt.ClaimStrings{keys.mfaChallengeAudience()},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	for _, key := range []string{"mfa:", ""} {
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		if _, err := ValidateMFAChallengeToken(forged, keys); err == nil {
+			t.Errorf("accepted an MFA challenge token signed with %q", key)
+		}
+	}
+}
+
+func TestMFAChallengeTokenRequiresAudienceAndExpiry(t *testing.T) {
+	keys := newTestJWTKeys(t, JWTAlgorithmHS256, "secret")
+	now := time.Now()
+
+	tests := map[string]jwt.RegisteredClaims{
+		"no audience":    {Issuer: keys.issuer, Subject: "user-1", ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))},
+		"other issuer":   {Issuer: "https://other.example.com", Subject: "user-1", Audience: jwt.ClaimStrings{keys.mfaChallengeAudience()}, ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))},
+		"no expiry":      {Issuer: keys.issuer, Subject: "user-1", Audience: jwt.ClaimStrings{keys.mfaChallengeAudience()}},
+		"expired":        {Issuer: keys.issuer, Subject: "user-1", Audience: jwt.ClaimStrings{keys.mfaChallengeAudience()}, ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute))},
+		"missing sub":    {Issuer: keys.issuer, Audience: jwt.ClaimStrings{keys.mfaChallengeAudience()}, ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))},
+		"other audience": {Issuer: keys.issuer, Subject: "user-1", Audience: jwt.ClaimStrings{"other"}, ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))},
+	}
+
+	for name, claims := range tests {
+		token, err := keys.sign(claims)
+		if err != nil {
+			t.Fatalf("%s: sign: %v", name, err)
+		}
+		if _, err := ValidateMFAChallengeToken(token, keys); err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mnizarzr/dot-test/config"
+	"gopkg.in/gomail.v2"
+)
+
+// Mail driver constants
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverFile = "file"
+	MailDriverLog  = "log"
+)
+
+// Message represents an outgoing email independent of the transport
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+	Headers  map[string]string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg *Message) error
+	Close() error
+}
+
+// NewMailer creates the mailer selected by the MAIL_DRIVER setting
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case MailDriverSMTP, "":
+		return NewSMTPMailer(cfg), nil
+	case MailDriverFile:
+		return NewFileMailer(cfg.MailDir)
+	case MailDriverLog:
+		return NewLogMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.MailDriver)
+	}
+}
+
+// toGomail converts a message to a multipart gomail message
+func (msg *Message) toGomail() *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	for name, value := range msg.Headers {
+		m.SetHeader(name, value)
+	}
+	m.SetBody("text/plain", msg.TextBody)
+	m.AddAlternative("text/html", msg.HTMLBody)
+	return m
+}
+
+// SMTPMailer sends messages over SMTP, reusing open connections between sends
+type SMTPMailer struct {
+	dialer      *gomail.Dialer
+	idleTimeout time.Duration
+	idle        chan *smtpConn
+	slots       chan struct{}
+}
+
+// smtpConn is a pooled SMTP connection
+type smtpConn struct {
+	gomail.SendCloser
+	lastUsed time.Time
+}
+
+// NewSMTPMailer creates an SMTP mailer keeping up to SMTP_POOL_SIZE connections alive
+func NewSMTPMailer(cfg *config.Config) *SMTPMailer {
+	poolSize := cfg.SmtpPoolSize
+	if poolSize < 1 {
+		poolSize = 1
+	}
+
+	return &SMTPMailer{
+		dialer:      gomail.NewDialer(cfg.SmtpHost, cfg.SmtpPort, cfg.SmtpUser, cfg.SmtpPassword),
+		idleTimeout: time.Duration(cfg.SmtpIdleTimeout) * time.Second,
+		idle:        make(chan *smtpConn, poolSize),
+		slots:       make(chan struct{}, poolSize),
+	}
+}
+
+// Send delivers a message, redialing once if a pooled connection was dropped by the server
+func (s *SMTPMailer) Send(msg *Message) error {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	m := msg.toGomail()
+
+	if conn := s.take(); conn != nil {
+		if err := gomail.Send(conn, m); err == nil {
+			s.put(conn)
+			return nil
+		}
+		conn.Close()
+	}
+
+	sc, err := s.dialer.Dial()
+	if err != nil {
+		return err
+	}
+
+	conn := &smtpConn{SendCloser: sc}
+	if err := gomail.Send(conn, m); err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.put(conn)
+	return nil
+}
+
+// Close closes all idle connections
+func (s *SMTPMailer) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// take returns an idle connection that has not timed out, or nil
+func (s *SMTPMailer) take() *smtpConn {
+	for {
+		select {
+		case conn := <-s.idle:
+			if time.Since(conn.lastUsed) > s.idleTimeout {
+				conn.Close()
+				continue
+			}
+			return conn
+		default:
+			return nil
+		}
+	}
+}
+
+// put returns a connection to the idle pool
+func (s *SMTPMailer) put(conn *smtpConn) {
+	conn.lastUsed = time.Now()
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// FileMailer writes each message as an .eml file into a directory
+type FileMailer struct {
+	dir string
+}
+
+// NewFileMailer creates a file mailer, creating the directory if needed
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &FileMailer{
+		dir: dir,
+	}, nil
+}
+
+// Send writes the message to <dir>/<timestamp>-<id>.eml
+func (f *FileMailer) Send(msg *Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), uuid.New().String()[:8])
+
+	file, err := os.Create(filepath.Join(f.dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = msg.toGomail().WriteTo(file)
+	return err
+}
+
+// Close is a no-op for the file mailer
+func (f *FileMailer) Close() error {
+	return nil
+}
+
+// LogMailer only logs messages instead of sending them
+type LogMailer struct{}
+
+// NewLogMailer creates a log mailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the recipient, subject and plain text body
+func (l *LogMailer) Send(msg *Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.TextBody)
+	return nil
+}
+
+// Close is a no-op for the log mailer
+func (l *LogMailer) Close() error {
+	return nil
+}
+
+// CaptureMailer keeps sent messages in memory so tests can assert on them
+type CaptureMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewCaptureMailer creates a capture mailer
+func NewCaptureMailer() *CaptureMailer {
+	return &CaptureMailer{}
+}
+
+// Send records the message
+func (c *CaptureMailer) Send(msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, *msg)
+	return nil
+}
+
+// Messages returns a copy of all captured messages
+func (c *CaptureMailer) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.messages...)
+}
+
+// Reset discards all captured messages
+func (c *CaptureMailer) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = nil
+}
+
+// Close is a no-op for the capture mailer
+func (c *CaptureMailer) Close() error {
+	return nil
+}
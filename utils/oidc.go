@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcJWKSRefreshInterval limits how often an unknown key ID triggers a new
+// JWKS download, so forged tokens cannot make us hammer the provider
+const oidcJWKSRefreshInterval = time.Minute
+
+// ErrOIDCTokenInvalid is returned for ID tokens that fail verification
+var ErrOIDCTokenInvalid = errors.New("invalid OIDC ID token")
+
+// OIDCDiscovery is the part of the provider metadata the login flow needs
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims are the verified identity claims of an ID token
+type OIDCClaims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// OIDCClient runs the authorization code flow with PKCE against an OpenID
+// Connect provider. Provider metadata and signing keys are fetched lazily
+// and cached.
+type OIDCClient struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	groupsClaim  string
+	httpClient   *http.Client
+
+	mu            sync.Mutex
+	discovery     *OIDCDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewOIDCClient creates a client for the provider at issuer
+func NewOIDCClient(issuer, clientID, clientSecret, redirectURL string, scopes []string, groupsClaim string) *OIDCClient {
+	return &OIDCClient{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		groupsClaim:  groupsClaim,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Discover fetches and caches the provider metadata
+func (c *OIDCClient) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	var discovery OIDCDiscovery
+	if err := c.getJSON(ctx, c.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	// The metadata must be for the configured issuer, or tokens could be
+	// verified against keys of another provider
+	if strings.TrimSuffix(discovery.Issuer, "/") != c.issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", discovery.Issuer, c.issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	c.discovery = &discovery
+	return c.discovery, nil
+}
+
+// AuthCodeURL returns the provider URL to send the user to for logging in
+func (c *OIDCClient) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.clientID)
+	query.Set("redirect_uri", c.redirectURL)
+	query.Set("scope", strings.Join(c.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token
+func (c *OIDCClient) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	discovery, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if c.clientSecret == "" {
+		form.Set("client_id", c.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange OIDC code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC token endpoint returned %s: %s", resp.Status, body)
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode OIDC token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("OIDC token response has no id_token")
+	}
+
+	return token.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token and returns its identity claims
+func (c *OIDCClient) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCClaims, error) {
+	discovery, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.signingKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(c.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCTokenInvalid)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrOIDCTokenInvalid)
+	}
+
+	result := &OIDCClaims{
+		Issuer:        discovery.Issuer,
+		Subject:       subject,
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		Name:          stringClaim(claims, "name"),
+		Groups:        stringsClaim(claims, c.groupsClaim),
+	}
+	return result, nil
+}
+
+// signingKey returns the provider key with the given ID, downloading the
+// JWKS again when the provider has rotated its keys
+func (c *OIDCClient) signingKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	discovery, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.lookupKey(kid); ok {
+		return key, nil
+	}
+	if c.keys != nil && time.Since(c.keysFetchedAt) < oidcJWKSRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := c.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	c.keys = keys
+	c.keysFetchedAt = time.Now()
+
+	if key, ok := c.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key. Tokens without a key ID are accepted when
+// the provider publishes a single key.
+func (c *OIDCClient) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, endpoint string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dest)
+}
+
+// jsonWebKey is an RSA or EC public key from a JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// GenerateOIDCState generates a random value for the state, nonce or PKCE
+// code verifier of a login
+func GenerateOIDCState() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// PKCEChallenge derives the S256 code challenge of a code verifier
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim also accepts "true", which some providers send for email_verified
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
+
+// stringsClaim reads a claim holding a list of strings or a single string
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	case string:
+		return []string{value}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,458 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID     = "dtt-client"
+	testOIDCClientSecret = "dtt-secret"
+	testOIDCRedirectURL  = "https://tasks.example.com/api/v1/auth/oidc/callback"
+)
+
+// mockOIDCProvider is an OpenID Connect provider serving discovery, JWKS and
+// token endpoints, signing ID tokens with keys it can rotate
+type mockOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu             sync.Mutex
+	issuer         string
+	keys           map[string]interface{}
+	jwksRequests   int
+	idToken        string
+	expectVerifier string
+	omitKeyID      bool
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	p := &mockOIDCProvider{t: t, keys: make(map[string]interface{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	p.issuer = p.server.URL
+	t.Cleanup(p.server.Close)
+
+	p.addRSAKey("key-1")
+	return p
+}
+
+func (p *mockOIDCProvider) client() *OIDCClient {
+	return NewOIDCClient(p.server.URL, testOIDCClientID, testOIDCClientSecret, testOIDCRedirectURL, []string{"openid", "email", "profile"}, "groups")
+}
+
+func (p *mockOIDCProvider) addRSAKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		p.t.Fatalf("generate RSA key: %v", err)
+	}
+	p.mu.Lock()
+	p.keys[kid] = key
+	p.mu.Unlock()
+}
+
+func (p *mockOIDCProvider) addECKey(kid string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatalf("generate EC key: %v", err)
+	}
+	p.mu.Lock()
+	p.keys[kid] = key
+	p.mu.Unlock()
+}
+
+func (p *mockOIDCProvider) removeKey(kid string) {
+	p.mu.Lock()
+	delete(p.keys, kid)
+	p.mu.Unlock()
+}
+
+func (p *mockOIDCProvider) jwksFetches() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksRequests
+}
+
+func (p *mockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	issuer := p.issuer
+	p.mu.Unlock()
+
+	writeTestJSON(w, OIDCDiscovery{
+		Issuer:                issuer,
+		AuthorizationEndpoint: p.server.URL + "/authorize",
+		TokenEndpoint:         p.server.URL + "/token",
+		JWKSURI:               p.server.URL + "/jwks",
+	})
+}
+
+func (p *mockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jwksRequests++
+
+	keys := make([]map[string]string, 0, len(p.keys))
+	for kid, key := range p.keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "EC",
+				"kid": kid,
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	writeTestJSON(w, map[string]interface{}{"keys": keys})
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	p.mu.Lock()
+	expectVerifier, idToken := p.expectVerifier, p.idToken
+	p.mu.Unlock()
+
+	if !ok || clientID != testOIDCClientID || secret != testOIDCClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "auth-code" ||
+		r.PostForm.Get("redirect_uri") != testOIDCRedirectURL || r.PostForm.Get("code_verifier") != expectVerifier {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	writeTestJSON(w, map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+}
+
+// sign creates an ID token signed with the key kid. The claims default to a
+// valid token for the test client, and overrides replace or, when nil,
+// remove them.
+func (p *mockOIDCProvider) sign(kid string, overrides jwt.MapClaims) string {
+	p.t.Helper()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-123",
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          "nonce-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+		"groups":         []string{"engineering", "admins"},
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+
+	p.mu.Lock()
+	key := p.keys[kid]
+	p.mu.Unlock()
+
+	var token *jwt.Token
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		token = jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	default:
+		token = jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	}
+	if !p.omitKeyID {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		p.t.Fatalf("sign ID token: %v", err)
+	}
+	return signed
+}
+
+func writeTestJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func TestOIDCDiscoverRejectsOtherIssuer(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.issuer = "https://evil.example.com"
+
+	if _, err := provider.client().Discover(context.Background()); err == nil {
+		t.Fatal("Discover accepted metadata for another issuer")
+	}
+}
+
+func TestOIDCAuthCodeURLUsesPKCE(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+
+	authURL, err := provider.client().AuthCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", authURL, err)
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != provider.server.URL+"/authorize" {
+		t.Errorf("endpoint = %s", got)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testOIDCClientID,
+		"redirect_uri":          testOIDCRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        PKCEChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestPKCEChallengeMatchesRFC7636(t *testing.T) {
+	// RFC 7636 appendix B
+	got := PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("PKCEChallenge = %s, want %s", got, want)
+	}
+}
+
+func TestOIDCExchangeAndVerify(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.expectVerifier = "verifier-1"
+	provider.idToken = provider.sign("key-1", nil)
+	client := provider.client()
+	ctx := context.Background()
+
+	rawIDToken, err := client.Exchange(ctx, "auth-code", "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := client.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	if claims.Issuer != provider.server.URL || claims.Subject != "user-123" || claims.Email != "jane@example.com" ||
+		!claims.EmailVerified || claims.Name != "Jane Doe" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if len(claims.Groups) != 2 || claims.Groups[0] != "engineering" || claims.Groups[1] != "admins" {
+		t.Errorf("groups = %v", claims.Groups)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.expectVerifier = "verifier-1"
+	provider.idToken = provider.sign("key-1", nil)
+
+	if _, err := provider.client().Exchange(context.Background(), "auth-code", "other-verifier"); err == nil {
+		t.Fatal("Exchange succeeded with the wrong code verifier")
+	}
+}
+
+func TestOIDCVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		overrides jwt.MapClaims
+		nonce     string
+	}{
+		{"nonce mismatch", nil, "other-nonce"},
+		{"missing nonce", jwt.MapClaims{"nonce": nil}, "nonce-1"},
+		{"other issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, "nonce-1"},
+		{"other audience", jwt.MapClaims{"aud": "other-client"}, "nonce-1"},
+		{"expired", jwt.MapClaims{"exp": now.Add(-5 * time.Minute).Unix()}, "nonce-1"},
+		{"no expiry", jwt.MapClaims{"exp": nil}, "nonce-1"},
+		{"issued in the future", jwt.MapClaims{"iat": now.Add(10 * time.Minute).Unix()}, "nonce-1"},
+		{"missing subject", jwt.MapClaims{"sub": nil}, "nonce-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.client().VerifyIDToken(context.Background(), provider.sign("key-1", tt.overrides), tt.nonce)
+			if !errors.Is(err, ErrOIDCTokenInvalid) {
+				t.Errorf("VerifyIDToken error = %v, want ErrOIDCTokenInvalid", err)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyIDTokenRejectsForgedSignature(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	client := provider.client()
+
+	// Signed with a key that has the published key ID but is not published
+	forger := newMockOIDCProvider(t)
+	forged := forger.sign("key-1", jwt.MapClaims{"iss": provider.server.URL})
+
+	if _, err := client.VerifyIDToken(context.Background(), forged, "nonce-1"); !errors.Is(err, ErrOIDCTokenInvalid) {
+		t.Errorf("VerifyIDToken error = %v, want ErrOIDCTokenInvalid", err)
+	}
+}
+
+func TestOIDCVerifyIDTokenRejectsHMAC(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": provider.server.URL, "sub": "user-123", "aud": testOIDCClientID, "nonce": "nonce-1",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString([]byte(testOIDCClientSecret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	if _, err := provider.client().VerifyIDToken(context.Background(), signed, "nonce-1"); !errors.Is(err, ErrOIDCTokenInvalid) {
+		t.Errorf("VerifyIDToken error = %v, want ErrOIDCTokenInvalid", err)
+	}
+}
+
+func TestOIDCVerifyIDTokenReadsEmailVerified(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{"true", true, true},
+		{"string true", "true", true},
+		{"false", false, false},
+		{"string false", "false", false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.client().VerifyIDToken(context.Background(), provider.sign("key-1", jwt.MapClaims{"email_verified": tt.value}), "nonce-1")
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if claims.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", claims.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyIDTokenAcceptsECKeys(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.addECKey("ec-1")
+
+	if _, err := provider.client().VerifyIDToken(context.Background(), provider.sign("ec-1", nil), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+}
+
+func TestOIDCSigningKeyRotation(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	client := provider.client()
+	ctx := context.Background()
+
+	if _, err := client.VerifyIDToken(ctx, provider.sign("key-1", nil), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken with the first key: %v", err)
+	}
+	if provider.jwksFetches() != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", provider.jwksFetches())
+	}
+
+	// Known keys come from the cache
+	if _, err := client.VerifyIDToken(ctx, provider.sign("key-1", nil), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken again: %v", err)
+	}
+	if provider.jwksFetches() != 1 {
+		t.Fatalf("JWKS fetched %d times for a cached key, want 1", provider.jwksFetches())
+	}
+
+	provider.addRSAKey("key-2")
+	provider.removeKey("key-1")
+
+	// Unknown key IDs right after a fetch do not fetch again
+	if _, err := client.VerifyIDToken(ctx, provider.sign("key-2", nil), "nonce-1"); !errors.Is(err, ErrOIDCTokenInvalid) {
+		t.Fatalf("VerifyIDToken error = %v, want ErrOIDCTokenInvalid within the refresh interval", err)
+	}
+	if provider.jwksFetches() != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", provider.jwksFetches())
+	}
+
+	// Once the interval passed, the rotated key is fetched
+	client.mu.Lock()
+	client.keysFetchedAt = time.Now().Add(-2 * oidcJWKSRefreshInterval)
+	client.mu.Unlock()
+
+	if _, err := client.VerifyIDToken(ctx, provider.sign("key-2", nil), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken with the rotated key: %v", err)
+	}
+	if provider.jwksFetches() != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", provider.jwksFetches())
+	}
+}
+
+func TestOIDCVerifyIDTokenWithoutKeyID(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.omitKeyID = true
+
+	// Tokens without a key ID are accepted while the provider has one key
+	if _, err := provider.client().VerifyIDToken(context.Background(), provider.sign("key-1", nil), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken with a single key: %v", err)
+	}
+
+	provider.addRSAKey("key-2")
+	if _, err := provider.client().VerifyIDToken(context.Background(), provider.sign("key-1", nil), "nonce-1"); !errors.Is(err, ErrOIDCTokenInvalid) {
+		t.Errorf("VerifyIDToken error = %v, want ErrOIDCTokenInvalid with several keys", err)
+	}
+}
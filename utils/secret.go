@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidCiphertext is returned when an encrypted secret can't be decrypted
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// EncryptSecret encrypts a value at rest with AES-256-GCM under a key derived
+// from the given key material
+func EncryptSecret(plaintext, key string) (string, error) {
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a value encrypted by EncryptSecret
+func DecryptSecret(ciphertext, key string) (string, error) {
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+// newSecretCipher derives the AES key, keeping it distinct from other uses of the key material
+func newSecretCipher(key string) (cipher.AEAD, error) {
+	derived := sha256.Sum256([]byte("secret:" + key))
+
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B lists 8 digit codes; 6 digit codes are their last six
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		step := TOTPStep(time.Unix(vector.unix, 0))
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode at %d: %v", vector.unix, err)
+		}
+		if code != vector.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowercaseSecret(t *testing.T) {
+	code, err := TOTPCode(strings.ToLower(rfc6238Secret), TOTPStep(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("TOTPCode = %s, %v, want 287082", code, err)
+	}
+}
+
+func TestTOTPCodeRejectsInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTPSkewWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -1, true},
+		{"next step", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfc6238Secret, current+tt.offset)
+			if err != nil {
+				t.Fatalf("TOTPCode: %v", err)
+			}
+
+			step, ok := ValidateTOTP(rfc6238Secret, code, now)
+			if ok != tt.valid {
+				t.Fatalf("ValidateTOTP = %v, want %v", ok, tt.valid)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("matched step %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "abcdef", "000000"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("ValidateTOTP accepted %q", code)
+		}
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, " 287082 ", now); !ok {
+		t.Error("ValidateTOTP rejected a code with surrounding spaces")
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde-fghij "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the canonical form", code)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidUnsubscribeToken is returned when an unsubscribe token is malformed or forged
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// GenerateUnsubscribeToken creates a signed token that opts a user out of one event type
+func GenerateUnsubscribeToken(userID uuid.UUID, eventType, secret string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID.String() + ":" + eventType))
+	return payload + "." + signUnsubscribePayload(payload, secret)
+}
+
+// ParseUnsubscribeToken verifies an unsubscribe token and returns the user ID and event type
+func ParseUnsubscribeToken(token, secret string) (uuid.UUID, string, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, "", ErrInvalidUnsubscribeToken
+	}
+
+	expected := signUnsubscribePayload(payload, secret)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return uuid.Nil, "", ErrInvalidUnsubscribeToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidUnsubscribeToken
+	}
+
+	userIDStr, eventType, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return uuid.Nil, "", ErrInvalidUnsubscribeToken
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidUnsubscribeToken
+	}
+
+	return userID, eventType, nil
+}
+
+// signUnsubscribePayload signs the payload with a key derived from the secret,
+// so an unsubscribe token can never be replayed as a JWT signature
+func signUnsubscribePayload(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}